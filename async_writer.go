@@ -0,0 +1,216 @@
+package diag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// AsyncWriterPolicy defines what happens if the async writer buffer is full
+type AsyncWriterPolicy int
+
+const (
+	// AsyncWriterBlock makes Write wait until there is a room in the buffer
+	AsyncWriterBlock AsyncWriterPolicy = iota
+
+	// AsyncWriterDropNewest drops the entry being written
+	AsyncWriterDropNewest
+
+	// AsyncWriterDropOldest drops the oldest buffered entry to make a room for the new one
+	AsyncWriterDropOldest
+)
+
+// ErrAsyncWriterClosed is returned when writing to a closed async writer
+var ErrAsyncWriterClosed = errors.New("async writer is closed")
+
+// asyncWriterEntry is either a log entry or a flush marker that is closed once written
+type asyncWriterEntry struct {
+	data    []byte
+	flushed chan struct{}
+}
+
+// AsyncWriter writes log entries to the target writer on a background goroutine.
+// Entries are buffered in a bounded queue, see AsyncWriterPolicy for the overflow behavior.
+type AsyncWriter struct {
+	out     io.Writer
+	policy  AsyncWriterPolicy
+	entries chan asyncWriterEntry
+	done    chan struct{}
+	dropped atomic.Uint64
+
+	// closing is closed first by Close to release writes blocked on the full buffer,
+	// so that Close can take mu without waiting for room in the buffer
+	closing   chan struct{}
+	closeOnce sync.Once
+
+	// mu is held for reading while writing to entries, Close takes it for writing to close entries
+	mu     sync.RWMutex
+	closed bool
+}
+
+type asyncWriterCfg struct {
+	bufferSize int
+	policy     AsyncWriterPolicy
+}
+
+// AsyncWriterOpt is a functional option for configuring the async writer
+type AsyncWriterOpt func(cfg *asyncWriterCfg)
+
+// WithAsyncWriterBufferSize sets max number of buffered entries, default is 1024
+func WithAsyncWriterBufferSize(size int) AsyncWriterOpt {
+	return func(cfg *asyncWriterCfg) {
+		cfg.bufferSize = size
+	}
+}
+
+// WithAsyncWriterPolicy sets the buffer overflow policy, default is AsyncWriterBlock
+func WithAsyncWriterPolicy(policy AsyncWriterPolicy) AsyncWriterOpt {
+	return func(cfg *asyncWriterCfg) {
+		cfg.policy = policy
+	}
+}
+
+// NewAsyncWriter creates an async writer and starts its background goroutine.
+// Use Close to write the buffered entries and stop the goroutine.
+func NewAsyncWriter(out io.Writer, opts ...AsyncWriterOpt) *AsyncWriter {
+	cfg := asyncWriterCfg{
+		bufferSize: 1024,
+		policy:     AsyncWriterBlock,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.bufferSize <= 0 {
+		panic(fmt.Errorf("async writer buffer size must be positive, got %d", cfg.bufferSize))
+	}
+	w := &AsyncWriter{
+		out:     out,
+		policy:  cfg.policy,
+		entries: make(chan asyncWriterEntry, cfg.bufferSize),
+		done:    make(chan struct{}),
+		closing: make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *AsyncWriter) run() {
+	defer close(w.done)
+	for entry := range w.entries {
+		if entry.flushed != nil {
+			close(entry.flushed)
+			continue
+		}
+		if _, err := w.out.Write(entry.data); err != nil {
+			fmt.Fprintf(os.Stderr, "diag: could not write log entry: %v\n", err)
+		}
+	}
+}
+
+// Write buffers a copy of the entry, never returns an error unless the writer is closed
+func (w *AsyncWriter) Write(p []byte) (int, error) {
+	data := make([]byte, len(p))
+	copy(data, p)
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return 0, ErrAsyncWriterClosed
+	}
+	entry := asyncWriterEntry{data: data}
+	switch w.policy {
+	case AsyncWriterDropNewest:
+		select {
+		case w.entries <- entry:
+		default:
+			w.dropped.Add(1)
+		}
+	case AsyncWriterDropOldest:
+		w.pushDroppingOldest(entry)
+	default:
+		select {
+		case w.entries <- entry:
+		case <-w.closing:
+			return 0, ErrAsyncWriterClosed
+		}
+	}
+	return len(p), nil
+}
+
+func (w *AsyncWriter) pushDroppingOldest(entry asyncWriterEntry) {
+	for {
+		select {
+		case w.entries <- entry:
+			return
+		default:
+		}
+		select {
+		case oldest := <-w.entries:
+			if oldest.flushed != nil {
+				// entries written before the flush marker are written or dropped at this point
+				close(oldest.flushed)
+			} else {
+				w.dropped.Add(1)
+			}
+		default:
+		}
+	}
+}
+
+// Dropped returns number of entries dropped due to the buffer overflow
+func (w *AsyncWriter) Dropped() uint64 {
+	return w.dropped.Load()
+}
+
+// Flush waits until the entries buffered before the call are written or ctx is done
+func (w *AsyncWriter) Flush(ctx context.Context) error {
+	flushed := make(chan struct{})
+	if err := w.enqueueFlush(ctx, flushed); err != nil {
+		return err
+	}
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *AsyncWriter) enqueueFlush(ctx context.Context, flushed chan struct{}) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		return ErrAsyncWriterClosed
+	}
+	select {
+	case w.entries <- asyncWriterEntry{flushed: flushed}:
+		return nil
+	case <-w.closing:
+		return ErrAsyncWriterClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close writes the buffered entries and stops the background goroutine.
+// Writes blocked on the full buffer are rejected with ErrAsyncWriterClosed.
+// Returns ctx error if ctx is done before all the entries are written.
+func (w *AsyncWriter) Close(ctx context.Context) error {
+	w.closeOnce.Do(func() { close(w.closing) })
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.entries)
+	}
+	w.mu.Unlock()
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,174 @@
+package diag
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// gatedWriter blocks writes until the gate is opened
+type gatedWriter struct {
+	gate    chan struct{}
+	started chan struct{}
+	once    sync.Once
+
+	mu  sync.Mutex
+	out bytes.Buffer
+}
+
+func newGatedWriter() *gatedWriter {
+	return &gatedWriter{gate: make(chan struct{}), started: make(chan struct{})}
+}
+
+func (w *gatedWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() { close(w.started) })
+	<-w.gate
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.out.Write(p)
+}
+
+func (w *gatedWriter) lines() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return strings.Fields(w.out.String())
+}
+
+func TestAsyncWriter(t *testing.T) {
+	ctx := context.Background()
+
+	// fillBuffer writes the first entry that is held by the gated writer and then the rest
+	fillBuffer := func(t *testing.T, writer *AsyncWriter, out *gatedWriter, entries ...string) {
+		_, err := writer.Write([]byte(entries[0] + "\n"))
+		assert.NoError(t, err)
+		<-out.started
+		for _, entry := range entries[1:] {
+			_, err := writer.Write([]byte(entry + "\n"))
+			assert.NoError(t, err)
+		}
+	}
+
+	t.Run("should write entries on background", func(t *testing.T) {
+		out := &bytes.Buffer{}
+		writer := NewAsyncWriter(out)
+		buf := []byte("entry 1\n")
+		n, err := writer.Write(buf)
+		assert.NoError(t, err)
+		assert.Equal(t, len(buf), n)
+		copy(buf, "reused!\n")
+		_, err = writer.Write([]byte("entry 2\n"))
+		assert.NoError(t, err)
+
+		assert.NoError(t, writer.Flush(ctx))
+		assert.Equal(t, "entry 1\nentry 2\n", out.String())
+		assert.NoError(t, writer.Close(ctx))
+	})
+
+	t.Run("should drop newest entries on overflow", func(t *testing.T) {
+		out := newGatedWriter()
+		writer := NewAsyncWriter(out, WithAsyncWriterBufferSize(2), WithAsyncWriterPolicy(AsyncWriterDropNewest))
+		fillBuffer(t, writer, out, "1", "2", "3", "4", "5")
+		close(out.gate)
+		assert.NoError(t, writer.Close(ctx))
+		assert.Equal(t, []string{"1", "2", "3"}, out.lines())
+		assert.Equal(t, uint64(2), writer.Dropped())
+	})
+
+	t.Run("should drop oldest entries on overflow", func(t *testing.T) {
+		out := newGatedWriter()
+		writer := NewAsyncWriter(out, WithAsyncWriterBufferSize(2), WithAsyncWriterPolicy(AsyncWriterDropOldest))
+		fillBuffer(t, writer, out, "1", "2", "3", "4", "5")
+		close(out.gate)
+		assert.NoError(t, writer.Close(ctx))
+		assert.Equal(t, []string{"1", "4", "5"}, out.lines())
+		assert.Equal(t, uint64(2), writer.Dropped())
+	})
+
+	t.Run("should block on overflow by default", func(t *testing.T) {
+		out := newGatedWriter()
+		writer := NewAsyncWriter(out, WithAsyncWriterBufferSize(1))
+		fillBuffer(t, writer, out, "1", "2")
+		written := make(chan struct{})
+		go func() {
+			_, err := writer.Write([]byte("3\n"))
+			assert.NoError(t, err)
+			close(written)
+		}()
+		select {
+		case <-written:
+			assert.Fail(t, "write should block while the buffer is full")
+		case <-time.After(10 * time.Millisecond):
+		}
+		close(out.gate)
+		<-written
+		assert.NoError(t, writer.Close(ctx))
+		assert.Equal(t, []string{"1", "2", "3"}, out.lines())
+		assert.Zero(t, writer.Dropped())
+	})
+
+	t.Run("should respect ctx deadline on flush and close", func(t *testing.T) {
+		out := newGatedWriter()
+		writer := NewAsyncWriter(out)
+		fillBuffer(t, writer, out, "1")
+		timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, writer.Flush(timeoutCtx), context.DeadlineExceeded)
+		assert.ErrorIs(t, writer.Close(timeoutCtx), context.DeadlineExceeded)
+
+		_, err := writer.Write([]byte("2\n"))
+		assert.ErrorIs(t, err, ErrAsyncWriterClosed)
+		assert.ErrorIs(t, writer.Flush(ctx), ErrAsyncWriterClosed)
+
+		close(out.gate)
+		assert.NoError(t, writer.Close(ctx))
+		assert.Equal(t, []string{"1"}, out.lines())
+	})
+
+	t.Run("should reject blocked writes on close", func(t *testing.T) {
+		out := newGatedWriter()
+		writer := NewAsyncWriter(out, WithAsyncWriterBufferSize(1))
+		fillBuffer(t, writer, out, "1", "2")
+		writeErr := make(chan error)
+		go func() {
+			_, err := writer.Write([]byte("3\n"))
+			writeErr <- err
+		}()
+		timeoutCtx, cancel := context.WithTimeout(ctx, 5*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, writer.Close(timeoutCtx), context.DeadlineExceeded)
+		assert.ErrorIs(t, <-writeErr, ErrAsyncWriterClosed)
+
+		close(out.gate)
+		assert.NoError(t, writer.Close(ctx))
+		assert.Equal(t, []string{"1", "2"}, out.lines())
+	})
+
+	t.Run("should be closed by root context shutdown", func(t *testing.T) {
+		out := newGatedWriter()
+		close(out.gate)
+		rootCtx, shutdown := RootContextWithShutdown(NewRootContextParams().
+			WithLogLevel(LogLevelInfoValue).
+			WithAsyncWriter(NewAsyncWriter(out)))
+		log := Log(rootCtx)
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				log.Info().Msg("message")
+			}()
+		}
+		wg.Wait()
+		assert.NoError(t, shutdown(ctx))
+		assert.Len(t, readLogEntries(t, bytes.NewBuffer(out.out.Bytes())), 11, "10 messages and shutdown entry")
+	})
+
+	t.Run("should panic on invalid buffer size", func(t *testing.T) {
+		assert.Panics(t, func() { NewAsyncWriter(&bytes.Buffer{}, WithAsyncWriterBufferSize(0)) })
+	})
+}
@@ -1,14 +1,104 @@
 package diag
 
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
 type logFieldAppender interface {
 	Str(key string, val string)
+	Int(key string, val int)
+	Bool(key string, val bool)
+	Dict(key string, appendFields func(target logFieldAppender))
 }
 
 type cloudPlatformAdapter interface {
 	appendLevelData(level LogLevel, target logFieldAppender)
+	appendContextData(diagData ContextDiagData, target logFieldAppender)
+	appendHTTPRequestData(info HTTPRequestInfo, target logFieldAppender)
+}
+
+// builtinFieldsReplacer is implemented by adapters that write level and timestamp
+// fields in the platform format, loggers omit their builtin level and time fields in this case
+type builtinFieldsReplacer interface {
+	replaceBuiltinFields()
 }
 
-type gcpAdapter struct{}
+func replacesBuiltinFields(adapter cloudPlatformAdapter) bool {
+	_, ok := adapter.(builtinFieldsReplacer)
+	return ok
+}
+
+// correlationIDWriter is implemented by adapters that may write the correlation id in the platform
+// format, loggers omit the correlationId context field if the adapter writes it for the diag data
+type correlationIDWriter interface {
+	writesCorrelationID(diagData ContextDiagData) bool
+}
+
+func writesCorrelationID(adapter cloudPlatformAdapter, diagData ContextDiagData) bool {
+	writer, ok := adapter.(correlationIDWriter)
+	return ok && writer.writesCorrelationID(diagData)
+}
+
+// HTTPRequestInfo describes a completed http request. Cloud adapters write it
+// in the platform format, e.g. as httpRequest field of GCP log entries.
+type HTTPRequestInfo struct {
+	Method       string
+	URL          string
+	Status       int
+	RequestSize  int64
+	ResponseSize int64
+	UserAgent    string
+	RemoteIP     string
+	Referer      string
+	Protocol     string
+	Latency      time.Duration
+}
+
+// HTTPRequestEvent is implemented by events of the builtin loggers, see WithHTTPRequest
+type HTTPRequestEvent interface {
+	WithHTTPRequest(info HTTPRequestInfo) LogLevelEvent
+}
+
+// WithHTTPRequest adds the http request info to the event if the logger has a cloud adapter
+// that supports it. The event is returned as is if it does not implement HTTPRequestEvent.
+func WithHTTPRequest(evt LogLevelEvent, info HTTPRequestInfo) LogLevelEvent {
+	if httpRequestEvent, ok := evt.(HTTPRequestEvent); ok {
+		return httpRequestEvent.WithHTTPRequest(info)
+	}
+	return evt
+}
+
+// gcpProjectEnv is set by Cloud Run functions and can be set explicitly for other environments
+const gcpProjectEnv = "GOOGLE_CLOUD_PROJECT"
+
+type gcpAdapter struct {
+	// projectID is used to format the trace field, trace fields are not written without it
+	projectID string
+}
+
+// GCPCloudAdapterOpt is a functional option for configuring the GCP cloud adapter
+type GCPCloudAdapterOpt func(adapter *gcpAdapter)
+
+// WithGCPProjectID sets the project id used to link log entries with Cloud Trace traces.
+// Default is the GOOGLE_CLOUD_PROJECT environment variable.
+func WithGCPProjectID(projectID string) GCPCloudAdapterOpt {
+	return func(adapter *gcpAdapter) {
+		adapter.projectID = projectID
+	}
+}
+
+func newGCPAdapter(opts ...GCPCloudAdapterOpt) gcpAdapter {
+	adapter := gcpAdapter{projectID: os.Getenv(gcpProjectEnv)}
+	for _, opt := range opts {
+		opt(&adapter)
+	}
+	return adapter
+}
 
 // appendLevelData appends GCP-specific log data to the given target.
 // GCP log severity levels can be found here:
@@ -30,4 +120,196 @@ func (gcpAdapter) appendLevelData(level LogLevel, target logFieldAppender) {
 	}
 }
 
+// appendContextData appends the special fields recognized by Cloud Logging:
+// trace and span of the request, source location of the log call and labels from the diag entries.
+// https://cloud.google.com/logging/docs/structured-logging#special-payload-fields
+func (a gcpAdapter) appendContextData(diagData ContextDiagData, target logFieldAppender) {
+	if trace := diagData.Trace; trace.IsValid() && a.projectID != "" {
+		target.Str("logging.googleapis.com/trace", "projects/"+a.projectID+"/traces/"+trace.TraceID)
+		target.Str("logging.googleapis.com/spanId", trace.SpanID)
+		target.Bool("logging.googleapis.com/trace_sampled", trace.Sampled)
+	}
+	if frame, ok := callerFrame(); ok {
+		target.Dict("logging.googleapis.com/sourceLocation", func(location logFieldAppender) {
+			location.Str("file", frame.File)
+			location.Str("line", strconv.Itoa(frame.Line))
+			location.Str("function", frame.Function)
+		})
+	}
+	if len(diagData.Entries) > 0 {
+		target.Dict("logging.googleapis.com/labels", func(labels logFieldAppender) {
+			for k, v := range diagData.Entries {
+				labels.Str(k, v)
+			}
+		})
+	}
+}
+
+// appendHTTPRequestData appends the httpRequest field rendered by Cloud Console as a request.
+// https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#httprequest
+func (gcpAdapter) appendHTTPRequestData(info HTTPRequestInfo, target logFieldAppender) {
+	target.Dict("httpRequest", func(request logFieldAppender) {
+		request.Str("requestMethod", info.Method)
+		request.Str("requestUrl", info.URL)
+		request.Int("status", info.Status)
+		if info.RequestSize > 0 {
+			request.Str("requestSize", strconv.FormatInt(info.RequestSize, 10))
+		}
+		request.Str("responseSize", strconv.FormatInt(info.ResponseSize, 10))
+		if info.UserAgent != "" {
+			request.Str("userAgent", info.UserAgent)
+		}
+		if info.RemoteIP != "" {
+			request.Str("remoteIp", info.RemoteIP)
+		}
+		if info.Referer != "" {
+			request.Str("referer", info.Referer)
+		}
+		if info.Protocol != "" {
+			request.Str("protocol", info.Protocol)
+		}
+		request.Str("latency", fmt.Sprintf("%.9fs", info.Latency.Seconds()))
+	})
+}
+
 var _ cloudPlatformAdapter = gcpAdapter{}
+
+// diagPackageFunctionPrefix is a prefix of functions of this package, frames of subpackages such
+// as http/server are not skipped by callerFrame so the middleware entries point to the middleware
+const diagPackageFunctionPrefix = "github.com/gocombo/diag."
+
+// callerFrame returns the first frame outside of the logger implementation
+func callerFrame() (runtime.Frame, bool) {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		isLoggerFrame := (strings.HasPrefix(frame.Function, diagPackageFunctionPrefix) && !strings.HasSuffix(frame.File, "_test.go")) ||
+			strings.HasPrefix(frame.Function, "log/slog.")
+		if !isLoggerFrame {
+			return frame, frame.Function != ""
+		}
+		if !more {
+			return runtime.Frame{}, false
+		}
+	}
+}
+
+type azureAdapter struct{}
+
+// appendLevelData appends Application Insights severity level.
+// Severity levels can be found here:
+// https://learn.microsoft.com/en-us/azure/azure-monitor/app/data-model-complete#trace
+func (azureAdapter) appendLevelData(level LogLevel, target logFieldAppender) {
+	switch level {
+	case LogLevelTraceValue, LogLevelDebugValue:
+		target.Str("severityLevel", "Verbose")
+	case LogLevelInfoValue:
+		target.Str("severityLevel", "Information")
+	case LogLevelWarnValue:
+		target.Str("severityLevel", "Warning")
+	case LogLevelErrorValue:
+		target.Str("severityLevel", "Error")
+	default:
+		target.Str("severityLevel", "Information")
+	}
+}
+
+// appendContextData appends Application Insights operation fields. Operation id is the W3C
+// trace id or the correlation id if there is no trace context, operation parent id is
+// the span id of the request and is added only if the request carried the trace context.
+func (azureAdapter) appendContextData(diagData ContextDiagData, target logFieldAppender) {
+	target.Str("operation_Id", azureOperationID(diagData))
+	if diagData.Trace.IsValid() && diagData.Trace.ParentSpanID != "" {
+		target.Str("operation_ParentId", diagData.Trace.SpanID)
+	}
+}
+
+// writesCorrelationID returns true if the operation id is the correlation id
+func (azureAdapter) writesCorrelationID(diagData ContextDiagData) bool {
+	return azureOperationID(diagData) == diagData.CorrelationID
+}
+
+func azureOperationID(diagData ContextDiagData) string {
+	if !diagData.Trace.IsValid() {
+		return diagData.CorrelationID
+	}
+	return diagData.Trace.TraceID
+}
+
+func (azureAdapter) appendHTTPRequestData(HTTPRequestInfo, logFieldAppender) {}
+
+var _ cloudPlatformAdapter = azureAdapter{}
+var _ correlationIDWriter = azureAdapter{}
+
+// AWSRequestIDEntry is a diag entry key of the AWS Lambda request id, see WithAWSRequestID
+const AWSRequestIDEntry = "awsRequestId"
+
+// AWSXRayTraceIDEntry is a diag entry key of the X-Ray trace id of the incoming request,
+// see WithAWSXRayTraceHeader
+const AWSXRayTraceIDEntry = "awsXRayTraceId"
+
+// awsXRayTraceEnv is set by the Lambda runtime for each invocation, e.g.
+// Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1
+const awsXRayTraceEnv = "_X_AMZN_TRACE_ID"
+
+type awsAdapter struct{}
+
+// appendLevelData appends level and timestamp fields in the format of Lambda JSON logs,
+// they are used by Lambda log level filtering and CloudWatch Logs Insights.
+// https://docs.aws.amazon.com/lambda/latest/dg/monitoring-cloudwatchlogs-advanced.html
+func (awsAdapter) appendLevelData(level LogLevel, target logFieldAppender) {
+	switch level {
+	case LogLevelTraceValue:
+		target.Str("level", "TRACE")
+	case LogLevelDebugValue:
+		target.Str("level", "DEBUG")
+	case LogLevelInfoValue:
+		target.Str("level", "INFO")
+	case LogLevelWarnValue:
+		target.Str("level", "WARN")
+	case LogLevelErrorValue:
+		target.Str("level", "ERROR")
+	default:
+		target.Str("level", "INFO")
+	}
+	target.Str("timestamp", time.Now().UTC().Format(time.RFC3339Nano))
+}
+
+// appendContextData appends the Lambda request id and X-Ray trace id. The trace id is taken
+// from the X-Amzn-Trace-Id header of the request, from the Lambda invocation environment or
+// from the W3C trace context of the diag data if it was propagated by the caller.
+func (awsAdapter) appendContextData(diagData ContextDiagData, target logFieldAppender) {
+	if requestID := diagData.Entries[AWSRequestIDEntry]; requestID != "" {
+		target.Str("requestId", requestID)
+	}
+	if traceID := diagData.Entries[AWSXRayTraceIDEntry]; traceID != "" {
+		target.Str("xrayTraceId", traceID)
+	} else if traceID = awsXRayTraceRoot(os.Getenv(awsXRayTraceEnv)); traceID != "" {
+		target.Str("xrayTraceId", traceID)
+	} else if diagData.Trace.IsValid() && diagData.Trace.ParentSpanID != "" {
+		target.Str("xrayTraceId", awsXRayTraceID(diagData.Trace.TraceID))
+	}
+}
+
+func (awsAdapter) appendHTTPRequestData(HTTPRequestInfo, logFieldAppender) {}
+
+func (awsAdapter) replaceBuiltinFields() {}
+
+var _ cloudPlatformAdapter = awsAdapter{}
+var _ builtinFieldsReplacer = awsAdapter{}
+
+// awsXRayTraceID converts W3C trace id to X-Ray format, first 8 hex digits are the X-Ray epoch part
+func awsXRayTraceID(traceID string) string {
+	return "1-" + traceID[:8] + "-" + traceID[8:]
+}
+
+// awsXRayTraceRoot returns the trace id of the X-Ray trace header, e.g. Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=1
+func awsXRayTraceRoot(traceHeader string) string {
+	for _, part := range strings.Split(traceHeader, ";") {
+		if root, ok := strings.CutPrefix(part, "Root="); ok {
+			return root
+		}
+	}
+	return ""
+}
@@ -1,9 +1,15 @@
 package diag
 
 import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGCPAdapter(t *testing.T) {
@@ -57,6 +63,119 @@ func TestGCPAdapter(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("appendContextData", func(t *testing.T) {
+		projectID := fake.Lorem().Word()
+		trace := NewTraceContext()
+		labelValue := fake.Lorem().Word()
+		diagData := ContextDiagData{
+			CorrelationID: fake.UUID().V4(),
+			Trace:         trace,
+			Entries:       map[string]string{"label1": labelValue},
+		}
+
+		t.Run("should append trace, source location and labels", func(t *testing.T) {
+			fields := make(map[string]string)
+			newGCPAdapter(WithGCPProjectID(projectID)).appendContextData(diagData, mockLogFieldAppender(fields))
+			assert.Equal(t, "projects/"+projectID+"/traces/"+trace.TraceID, fields["logging.googleapis.com/trace"])
+			assert.Equal(t, trace.SpanID, fields["logging.googleapis.com/spanId"])
+			assert.Equal(t, strconv.FormatBool(trace.Sampled), fields["logging.googleapis.com/trace_sampled"])
+			assert.Equal(t, labelValue, fields["logging.googleapis.com/labels.label1"])
+			assert.True(t, strings.HasSuffix(fields["logging.googleapis.com/sourceLocation.file"], "cloud_platforms_test.go"))
+			assert.NotEmpty(t, fields["logging.googleapis.com/sourceLocation.line"])
+			assert.Contains(t, fields["logging.googleapis.com/sourceLocation.function"], "TestGCPAdapter")
+		})
+
+		t.Run("should use project id from environment", func(t *testing.T) {
+			t.Setenv(gcpProjectEnv, projectID)
+			fields := make(map[string]string)
+			newGCPAdapter().appendContextData(diagData, mockLogFieldAppender(fields))
+			assert.Equal(t, "projects/"+projectID+"/traces/"+trace.TraceID, fields["logging.googleapis.com/trace"])
+		})
+
+		t.Run("should not append trace without project id or trace context", func(t *testing.T) {
+			t.Setenv(gcpProjectEnv, "")
+			for _, tt := range []struct {
+				adapter  gcpAdapter
+				diagData ContextDiagData
+			}{
+				{adapter: newGCPAdapter(), diagData: diagData},
+				{adapter: newGCPAdapter(WithGCPProjectID(projectID)), diagData: ContextDiagData{}},
+			} {
+				fields := make(map[string]string)
+				tt.adapter.appendContextData(tt.diagData, mockLogFieldAppender(fields))
+				assert.NotContains(t, fields, "logging.googleapis.com/trace")
+				assert.NotContains(t, fields, "logging.googleapis.com/spanId")
+			}
+		})
+	})
+
+	t.Run("appendHTTPRequestData", func(t *testing.T) {
+		fields := make(map[string]string)
+		gcpAdapter{}.appendHTTPRequestData(HTTPRequestInfo{
+			Method:       "POST",
+			URL:          "/v1/items?limit=10",
+			Status:       201,
+			RequestSize:  120,
+			ResponseSize: 340,
+			UserAgent:    "test-agent",
+			RemoteIP:     "10.0.0.1",
+			Protocol:     "HTTP/1.1",
+			Latency:      1500 * time.Millisecond,
+		}, mockLogFieldAppender(fields))
+		assert.Equal(t, map[string]string{
+			"httpRequest.requestMethod": "POST",
+			"httpRequest.requestUrl":    "/v1/items?limit=10",
+			"httpRequest.status":        "201",
+			"httpRequest.requestSize":   "120",
+			"httpRequest.responseSize":  "340",
+			"httpRequest.userAgent":     "test-agent",
+			"httpRequest.remoteIp":      "10.0.0.1",
+			"httpRequest.protocol":      "HTTP/1.1",
+			"httpRequest.latency":       "1.500000000s",
+		}, fields)
+	})
+
+	t.Run("should add structured fields to log entries", func(t *testing.T) {
+		factories := map[string]LoggerFactory{
+			"zerolog": zerologLoggerFactory{},
+			"slog":    NewSlogLoggerFactory(),
+		}
+		for name, factory := range factories {
+			t.Run(name, func(t *testing.T) {
+				projectID := fake.Lorem().Word()
+				output := &bytes.Buffer{}
+				rootCtx := RootContext(NewRootContextParams().
+					WithLoggerFactory(factory).
+					WithGCPCloudAdapter(WithGCPProjectID(projectID)).
+					WithOutput(output))
+				trace := NewTraceContext()
+				ctx := DiagifyContext(context.Background(), rootCtx,
+					WithTraceContext(trace),
+					WithAppendDiagEntries(map[string]string{"tenant": "tenant-1"}))
+				WithHTTPRequest(Log(ctx).Info(), HTTPRequestInfo{Method: "GET", URL: "/items", Status: 200}).Msg("END REQ")
+
+				entries := readLogEntries(t, output)
+				require.Len(t, entries, 1)
+				entry := entries[0]
+				assert.Equal(t, "INFO", entry["severity"])
+				assert.Equal(t, "projects/"+projectID+"/traces/"+trace.TraceID, entry["logging.googleapis.com/trace"])
+				assert.Equal(t, trace.SpanID, entry["logging.googleapis.com/spanId"])
+				assert.Equal(t, map[string]interface{}{"tenant": "tenant-1"}, entry["logging.googleapis.com/labels"])
+				sourceLocation, ok := entry["logging.googleapis.com/sourceLocation"].(map[string]interface{})
+				if assert.True(t, ok) {
+					assert.True(t, strings.HasSuffix(sourceLocation["file"].(string), "cloud_platforms_test.go"))
+					assert.Contains(t, sourceLocation["function"], "TestGCPAdapter")
+				}
+				httpRequest, ok := entry["httpRequest"].(map[string]interface{})
+				if assert.True(t, ok) {
+					assert.Equal(t, "GET", httpRequest["requestMethod"])
+					assert.Equal(t, "/items", httpRequest["requestUrl"])
+					assert.Equal(t, float64(200), httpRequest["status"])
+				}
+			})
+		}
+	})
 }
 
 type mockLogFieldAppender map[string]string
@@ -64,3 +183,230 @@ type mockLogFieldAppender map[string]string
 func (m mockLogFieldAppender) Str(key, value string) {
 	m[key] = value
 }
+
+func (m mockLogFieldAppender) Int(key string, value int) {
+	m[key] = strconv.Itoa(value)
+}
+
+func (m mockLogFieldAppender) Bool(key string, value bool) {
+	m[key] = strconv.FormatBool(value)
+}
+
+// Dict flattens the nested fields to <key>.<nested key>
+func (m mockLogFieldAppender) Dict(key string, appendFields func(target logFieldAppender)) {
+	nested := mockLogFieldAppender{}
+	appendFields(nested)
+	for k, v := range nested {
+		m[key+"."+k] = v
+	}
+}
+
+func TestAWSAdapter(t *testing.T) {
+	t.Run("appendLevelData", func(t *testing.T) {
+		tests := []struct {
+			level     LogLevel
+			wantLevel string
+		}{
+			{level: LogLevelTraceValue, wantLevel: "TRACE"},
+			{level: LogLevelDebugValue, wantLevel: "DEBUG"},
+			{level: LogLevelInfoValue, wantLevel: "INFO"},
+			{level: LogLevelWarnValue, wantLevel: "WARN"},
+			{level: LogLevelErrorValue, wantLevel: "ERROR"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.level.String()+" level", func(t *testing.T) {
+				fields := make(map[string]string)
+				awsAdapter{}.appendLevelData(tt.level, mockLogFieldAppender(fields))
+				assert.Equal(t, tt.wantLevel, fields["level"])
+				_, err := time.Parse(time.RFC3339Nano, fields["timestamp"])
+				assert.NoError(t, err)
+			})
+		}
+	})
+
+	t.Run("appendContextData", func(t *testing.T) {
+		xrayTraceEnv := "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1"
+
+		t.Run("should append request id and trace id of the request header", func(t *testing.T) {
+			t.Setenv(awsXRayTraceEnv, xrayTraceEnv)
+			opts := DiagOpts{DiagData: ContextDiagData{Entries: map[string]string{}}}
+			WithAWSRequestID("request-1")(&opts)
+			WithAWSXRayTraceHeader("Root=1-67891233-abcdef012345678912345678;Parent=463ac35c9f6413ad;Sampled=1")(&opts)
+			opts.DiagData.Trace = NewTraceContext().ChildSpan()
+			fields := make(map[string]string)
+			awsAdapter{}.appendContextData(opts.DiagData, mockLogFieldAppender(fields))
+			assert.Equal(t, map[string]string{
+				"requestId":   "request-1",
+				"xrayTraceId": "1-67891233-abcdef012345678912345678",
+			}, fields)
+		})
+
+		t.Run("should append trace id from lambda environment", func(t *testing.T) {
+			t.Setenv(awsXRayTraceEnv, xrayTraceEnv)
+			fields := make(map[string]string)
+			awsAdapter{}.appendContextData(ContextDiagData{Trace: NewTraceContext().ChildSpan()}, mockLogFieldAppender(fields))
+			assert.Equal(t, map[string]string{"xrayTraceId": "1-5759e988-bd862e3fe1be46a994272793"}, fields)
+		})
+
+		t.Run("should append propagated W3C trace id", func(t *testing.T) {
+			t.Setenv(awsXRayTraceEnv, "")
+			trace := NewTraceContext().ChildSpan()
+			fields := make(map[string]string)
+			awsAdapter{}.appendContextData(ContextDiagData{Trace: trace}, mockLogFieldAppender(fields))
+			assert.Equal(t, map[string]string{"xrayTraceId": "1-" + trace.TraceID[:8] + "-" + trace.TraceID[8:]}, fields)
+		})
+
+		t.Run("should not append W3C trace id started by us", func(t *testing.T) {
+			t.Setenv(awsXRayTraceEnv, "")
+			fields := make(map[string]string)
+			awsAdapter{}.appendContextData(ContextDiagData{Trace: NewTraceContext()}, mockLogFieldAppender(fields))
+			assert.Empty(t, fields)
+		})
+
+		t.Run("should append nothing if not available", func(t *testing.T) {
+			t.Setenv(awsXRayTraceEnv, "")
+			fields := make(map[string]string)
+			awsAdapter{}.appendContextData(ContextDiagData{}, mockLogFieldAppender(fields))
+			assert.Empty(t, fields)
+		})
+	})
+
+	t.Run("should replace builtin level and time fields", func(t *testing.T) {
+		factories := map[string]LoggerFactory{
+			"zerolog": zerologLoggerFactory{},
+			"slog":    NewSlogLoggerFactory(),
+		}
+		for name, factory := range factories {
+			t.Run(name, func(t *testing.T) {
+				t.Setenv(awsXRayTraceEnv, "")
+				output := &bytes.Buffer{}
+				rootCtx := RootContext(NewRootContextParams().
+					WithLoggerFactory(factory).
+					WithLogLevel(LogLevelInfoValue).
+					WithAWSCloudAdapter().
+					WithOutput(output))
+				ctx := DiagifyContext(context.Background(), rootCtx, WithAWSRequestID("request-1"))
+				Log(ctx).Debug().Msg("debug")
+				Log(ctx).Warn().Msg("warn")
+
+				assert.Equal(t, 1, strings.Count(output.String(), `"level"`))
+				entries := readLogEntries(t, output)
+				if !assert.Len(t, entries, 1) {
+					return
+				}
+				assert.Equal(t, "WARN", entries[0]["level"])
+				assert.Equal(t, "request-1", entries[0]["requestId"])
+				assert.Equal(t, "request-1", entries[0]["context"].(map[string]interface{})[AWSRequestIDEntry])
+				assert.Contains(t, entries[0], "timestamp")
+				assert.NotContains(t, entries[0], "time")
+			})
+		}
+	})
+}
+
+func TestAzureAdapter(t *testing.T) {
+	t.Run("appendLevelData", func(t *testing.T) {
+		tests := []struct {
+			level        LogLevel
+			wantSeverity string
+		}{
+			{level: LogLevelTraceValue, wantSeverity: "Verbose"},
+			{level: LogLevelDebugValue, wantSeverity: "Verbose"},
+			{level: LogLevelInfoValue, wantSeverity: "Information"},
+			{level: LogLevelWarnValue, wantSeverity: "Warning"},
+			{level: LogLevelErrorValue, wantSeverity: "Error"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.level.String()+" level", func(t *testing.T) {
+				fields := make(map[string]string)
+				azureAdapter{}.appendLevelData(tt.level, mockLogFieldAppender(fields))
+				assert.Equal(t, map[string]string{"severityLevel": tt.wantSeverity}, fields)
+			})
+		}
+	})
+
+	t.Run("appendContextData", func(t *testing.T) {
+		correlationID := fake.UUID().V4()
+		started := NewTraceContext()
+		carried := started.ChildSpan()
+		tests := []struct {
+			name       string
+			diagData   ContextDiagData
+			wantFields map[string]string
+		}{
+			{
+				name:       "no trace context",
+				diagData:   ContextDiagData{CorrelationID: correlationID},
+				wantFields: map[string]string{"operation_Id": correlationID},
+			},
+			{
+				name:       "started trace context",
+				diagData:   ContextDiagData{CorrelationID: correlationID, Trace: started},
+				wantFields: map[string]string{"operation_Id": started.TraceID},
+			},
+			{
+				name:     "carried trace context",
+				diagData: ContextDiagData{CorrelationID: correlationID, Trace: carried},
+				wantFields: map[string]string{
+					"operation_Id":       carried.TraceID,
+					"operation_ParentId": carried.SpanID,
+				},
+			},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				fields := make(map[string]string)
+				azureAdapter{}.appendContextData(tt.diagData, mockLogFieldAppender(fields))
+				assert.Equal(t, tt.wantFields, fields)
+			})
+		}
+	})
+
+	t.Run("should add fields to log entries", func(t *testing.T) {
+		output := &bytes.Buffer{}
+		rootCtx := RootContext(NewRootContextParams().
+			WithAzureCloudAdapter().
+			WithOutput(output))
+		trace := NewTraceContext().ChildSpan()
+		Log(DiagifyContext(context.Background(), rootCtx, WithTraceContext(trace))).Warn().Msg("warn")
+
+		entries := readLogEntries(t, output)
+		if assert.Len(t, entries, 1) {
+			assert.Equal(t, "warn", entries[0]["level"])
+			assert.Equal(t, "Warning", entries[0]["severityLevel"])
+			assert.Equal(t, trace.TraceID, entries[0]["operation_Id"])
+			assert.Equal(t, trace.SpanID, entries[0]["operation_ParentId"])
+		}
+	})
+
+	t.Run("should not duplicate correlation id written as operation id", func(t *testing.T) {
+		factories := map[string]LoggerFactory{
+			"zerolog": zerologLoggerFactory{},
+			"slog":    NewSlogLoggerFactory(),
+		}
+		for name, factory := range factories {
+			t.Run(name, func(t *testing.T) {
+				output := &bytes.Buffer{}
+				rootCtx := RootContext(NewRootContextParams().
+					WithLoggerFactory(factory).
+					WithAzureCloudAdapter().
+					WithOutput(output))
+				correlationID := fake.UUID().V4()
+				trace := NewTraceContext()
+				Log(DiagifyContext(context.Background(), rootCtx, WithCorrelationID(correlationID))).Info().Msg("no trace")
+				Log(DiagifyContext(context.Background(), rootCtx,
+					WithCorrelationID(correlationID), WithTraceContext(trace))).Info().Msg("trace")
+
+				entries := readLogEntries(t, output)
+				if !assert.Len(t, entries, 2) {
+					return
+				}
+				assert.Equal(t, correlationID, entries[0]["operation_Id"])
+				noTraceContext, _ := entries[0]["context"].(map[string]interface{})
+				assert.NotContains(t, noTraceContext, "correlationId")
+				assert.Equal(t, trace.TraceID, entries[1]["operation_Id"])
+				assert.Equal(t, correlationID, entries[1]["context"].(map[string]interface{})["correlationId"])
+			})
+		}
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"time"
 
 	"github.com/gofrs/uuid"
 )
@@ -15,6 +16,9 @@ const (
 	contextKeyLogger        = contextKey("gocombo.diag.context-key.root-logger")
 	contextKeyDiagData      = contextKey("gocombo.diag.context-key.diag-data")
 	contextKeyLoggerFactory = contextKey("gocombo.diag.context-key.logger-factory")
+	contextKeyLevelControl  = contextKey("gocombo.diag.context-key.level-control")
+	contextKeyLevelOverride = contextKey("gocombo.diag.context-key.level-override")
+	contextKeyTailBuffer    = contextKey("gocombo.diag.context-key.tail-buffer")
 )
 
 type LoggerFactory interface {
@@ -29,6 +33,17 @@ type rootContextParams struct {
 	LoggerFactory LoggerFactory
 	DiagData      ContextDiagData
 	cloudPlatformAdapter
+
+	// levelControl is created by RootContext and shared by all the derived loggers
+	levelControl *LogLevelControl
+
+	// hasShutdown is set if the root context is created with a shutdown function,
+	// logger factories may start background goroutines only if it is set
+	hasShutdown bool
+
+	piiScrubber *PIIScrubber
+	logSampler  *LogSampler
+	sinks       []logSink
 }
 
 // ContextDiagData is a structure that can be used to hold various
@@ -39,6 +54,9 @@ type ContextDiagData struct {
 
 	// Additional application specific entries to include with the log
 	Entries map[string]string
+
+	// Trace holds W3C trace context of the current request, if any
+	Trace TraceContext
 }
 
 func NewRootContextParams() *rootContextParams {
@@ -55,12 +73,49 @@ func NewRootContextParams() *rootContextParams {
 	}
 }
 
+// RootContext creates the root context. Use RootContextWithShutdown to write the pending entries
+// and release resources on exit. Options that need background goroutines fall back to a foreground
+// mode without the shutdown function, e.g. the log sampler summary is logged along with the entries.
 func RootContext(p *rootContextParams) context.Context {
-	logger := p.LoggerFactory.NewLogger(p)
+	ctx, _ := newRootContext(p, false)
+	return ctx
+}
+
+// RootContextWithShutdown creates the root context and returns a function that should be
+// called on exit. The shutdown function logs the uptime, shuts down the logger factory,
+// closes the outputs implementing OutputCloser and flushes the outputs that have Flush method (e.g. bufio.Writer).
+func RootContextWithShutdown(p *rootContextParams) (context.Context, ShutdownFunc) {
+	return newRootContext(p, true)
+}
+
+func newRootContext(p *rootContextParams, hasShutdown bool) (context.Context, ShutdownFunc) {
+	startedAt := time.Now()
+	params := *p
+	params.hasShutdown = hasShutdown
+	params.levelControl = newLogLevelControl(p.LogLevel)
+	if len(params.sinks) > 0 {
+		params.LoggerFactory = multiSinkLoggerFactory{target: params.LoggerFactory, sinks: params.sinks}
+	}
+	if params.piiScrubber != nil {
+		params.LoggerFactory = piiScrubbingLoggerFactory{target: params.LoggerFactory, scrubber: params.piiScrubber}
+	}
+	if params.logSampler != nil {
+		params.LoggerFactory = samplingLoggerFactory{target: params.LoggerFactory, sampler: params.logSampler}
+	}
+	logger := params.LoggerFactory.NewLogger(&params)
+	params.levelControl.logger = logger
+	setOutputLogger(params.Out, logger)
+	for _, sink := range params.sinks {
+		setOutputLogger(sink.out, logger)
+	}
+
 	ctx := context.WithValue(context.Background(), contextKeyLogger, logger)
 	ctx = context.WithValue(ctx, contextKeyDiagData, p.DiagData)
-	ctx = context.WithValue(ctx, contextKeyLoggerFactory, p.LoggerFactory)
-	return ctx
+	ctx = context.WithValue(ctx, contextKeyLoggerFactory, params.LoggerFactory)
+	ctx = context.WithValue(ctx, contextKeyLevelControl, params.levelControl)
+
+	shutdown := &rootShutdown{startedAt: startedAt, logger: logger, params: &params}
+	return ctx, shutdown.shutdown
 }
 
 // WithCorrelationID allows setting a predefined root correlation id
@@ -100,9 +155,58 @@ func (c *rootContextParams) WithLoggerFactory(factory LoggerFactory) *rootContex
 	return c
 }
 
-// WithGCPCloudAdapter will add GCP specific log entries such as severity
-func (c *rootContextParams) WithGCPCloudAdapter() *rootContextParams {
-	c.cloudPlatformAdapter = gcpAdapter{}
+// WithPIIScrubber will mask values found by the scrubber detectors (emails, card numbers etc.)
+// in messages, errors and string data of all the log entries
+func (c *rootContextParams) WithPIIScrubber(scrubber *PIIScrubber) *rootContextParams {
+	c.piiScrubber = scrubber
+	return c
+}
+
+// WithLogSampler will drop entries according to the sampler ratios and bursts
+func (c *rootContextParams) WithLogSampler(sampler *LogSampler) *rootContextParams {
+	c.logSampler = sampler
+	return c
+}
+
+// WithSink will write entries to an additional output with its own min level,
+// format and cloud adapter. Out, Pretty and cloud adapter of the root params define the primary output.
+// Sinks are written by the logger factory, so factories that ignore Out (e.g. slog with a custom handler)
+// will write all the sinks to the same destination.
+func (c *rootContextParams) WithSink(out io.Writer, opts ...LogSinkOpt) *rootContextParams {
+	sink := logSink{out: out, level: LogLevelTraceValue}
+	for _, opt := range opts {
+		opt(&sink)
+	}
+	c.sinks = append(c.sinks, sink)
+	return c
+}
+
+// WithAsyncWriter will write the log entries via the async writer so slow outputs
+// do not block the caller. The writer is closed by the root context shutdown function.
+// Overrides the output set by WithOutput.
+func (c *rootContextParams) WithAsyncWriter(writer *AsyncWriter) *rootContextParams {
+	c.Out = writer
+	return c
+}
+
+// WithGCPCloudAdapter will add GCP specific log entries such as severity, trace, source location,
+// labels and http request so the entries are rendered natively by Cloud Logging
+func (c *rootContextParams) WithGCPCloudAdapter(opts ...GCPCloudAdapterOpt) *rootContextParams {
+	c.cloudPlatformAdapter = newGCPAdapter(opts...)
+	return c
+}
+
+// WithAzureCloudAdapter will add Application Insights severity level and operation id
+// fields so the log entries are correlated with requests in Azure Monitor
+func (c *rootContextParams) WithAzureCloudAdapter() *rootContextParams {
+	c.cloudPlatformAdapter = azureAdapter{}
+	return c
+}
+
+// WithAWSCloudAdapter will write level and timestamp in the format of AWS Lambda JSON logs
+// and add Lambda request id (see WithAWSRequestID) and X-Ray trace id to the log entries
+func (c *rootContextParams) WithAWSCloudAdapter() *rootContextParams {
+	c.cloudPlatformAdapter = awsAdapter{}
 	return c
 }
 
@@ -131,6 +235,30 @@ func DiagData(ctx context.Context) ContextDiagData {
 	return diagData
 }
 
+// LevelControl returns the log level control of the root context.
+// Changing the level affects all the loggers derived from the root context.
+func LevelControl(ctx context.Context) *LogLevelControl {
+	levelControl, ok := ctx.Value(contextKeyLevelControl).(*LogLevelControl)
+	if !ok {
+		panic(fmt.Errorf("context does not contain a log level control"))
+	}
+	return levelControl
+}
+
+// LogLevelOverride returns the level set via WithLogLevel option for the context
+// or any of the diag contexts it was derived from. Returns false if the level was not set.
+func LogLevelOverride(ctx context.Context) (LogLevel, bool) {
+	level, ok := ctx.Value(contextKeyLevelOverride).(LogLevel)
+	return level, ok
+}
+
+// TailBuffer returns the tail buffer set via WithTailBuffer option for the context
+// or any of the diag contexts it was derived from. Returns false if the buffer was not set.
+func TailBuffer(ctx context.Context) (*LogTailBuffer, bool) {
+	buffer, ok := ctx.Value(contextKeyTailBuffer).(*LogTailBuffer)
+	return buffer, ok
+}
+
 func getLoggerFactory(ctx context.Context) LoggerFactory {
 	loggerFactory, ok := ctx.Value(contextKeyLoggerFactory).(LoggerFactory)
 	if !ok {
@@ -143,6 +271,9 @@ type DiagOpts struct {
 	Level *LogLevel
 
 	DiagData ContextDiagData
+
+	// TailBuffer holds entries below the logger level, inherited by the derived contexts
+	TailBuffer *LogTailBuffer
 }
 
 type DiagContextOption func(opts *DiagOpts)
@@ -159,6 +290,39 @@ func WithCorrelationID(correlationID string) DiagContextOption {
 	}
 }
 
+// WithTraceContext sets the W3C trace context of the diag context
+func WithTraceContext(trace TraceContext) DiagContextOption {
+	return func(opts *DiagOpts) {
+		opts.DiagData.Trace = trace
+	}
+}
+
+// WithTailBuffer makes the context logger pass entries below its level to the buffer
+// instead of dropping them. The buffer is shared with the contexts derived from the context.
+func WithTailBuffer(buffer *LogTailBuffer) DiagContextOption {
+	return func(opts *DiagOpts) {
+		opts.TailBuffer = buffer
+	}
+}
+
+// WithAWSRequestID adds the AWS Lambda request id to the diag entries,
+// it is also written as requestId by the AWS cloud adapter
+func WithAWSRequestID(requestID string) DiagContextOption {
+	return func(opts *DiagOpts) {
+		opts.DiagData.Entries[AWSRequestIDEntry] = requestID
+	}
+}
+
+// WithAWSXRayTraceHeader adds the X-Ray trace id of the X-Amzn-Trace-Id header value to the diag entries,
+// the AWS cloud adapter writes it as xrayTraceId. Header values without a trace id are ignored.
+func WithAWSXRayTraceHeader(traceHeader string) DiagContextOption {
+	return func(opts *DiagOpts) {
+		if traceID := awsXRayTraceRoot(traceHeader); traceID != "" {
+			opts.DiagData.Entries[AWSXRayTraceIDEntry] = traceID
+		}
+	}
+}
+
 func WithAppendDiagEntries(entries map[string]string) DiagContextOption {
 	return func(opts *DiagOpts) {
 		for k, v := range entries {
@@ -184,10 +348,30 @@ func DiagifyContext(
 
 	loggerFactory := getLoggerFactory(diagContext)
 	log := loggerFactory.ChildLogger(Log(diagContext), diagOpts)
+	if diagOpts.TailBuffer != nil && diagOpts.TailBuffer.logger == nil {
+		diagOpts.TailBuffer.logger = log
+	}
 
 	resultCtx := context.WithValue(parentCtx, contextKeyLogger, log)
 	resultCtx = context.WithValue(resultCtx, contextKeyDiagData, diagOpts.DiagData)
 	resultCtx = context.WithValue(resultCtx, contextKeyLoggerFactory, loggerFactory)
+	if levelControl, ok := diagContext.Value(contextKeyLevelControl).(*LogLevelControl); ok {
+		resultCtx = context.WithValue(resultCtx, contextKeyLevelControl, levelControl)
+	}
+	levelOverride, hasLevelOverride := LogLevelOverride(diagContext)
+	if diagOpts.Level != nil {
+		levelOverride, hasLevelOverride = ParseLogLevel(diagOpts.Level.String())
+	}
+	if hasLevelOverride {
+		resultCtx = context.WithValue(resultCtx, contextKeyLevelOverride, levelOverride)
+	}
+	tailBuffer, hasTailBuffer := TailBuffer(diagContext)
+	if diagOpts.TailBuffer != nil {
+		tailBuffer, hasTailBuffer = diagOpts.TailBuffer, true
+	}
+	if hasTailBuffer {
+		resultCtx = context.WithValue(resultCtx, contextKeyTailBuffer, tailBuffer)
+	}
 
 	return resultCtx
 }
@@ -154,6 +154,31 @@ func TestContext_DiagifyContext(t *testing.T) {
 
 		assert.Len(t, forkedDiagData.Entries, len(wantEntries)+len(rootEntries))
 	})
+	t.Run("propagates the level override", func(t *testing.T) {
+		diagContext := RootContext(NewRootContextParams())
+		_, ok := LogLevelOverride(diagContext)
+		assert.False(t, ok)
+
+		diagifiedCtx := DiagifyContext(context.Background(), diagContext, WithLogLevel(LogLevelTraceValue))
+		level, ok := LogLevelOverride(diagifiedCtx)
+		assert.True(t, ok)
+		assert.Equal(t, LogLevelTraceValue, level)
+
+		level, ok = LogLevelOverride(ForkContext(diagifiedCtx))
+		assert.True(t, ok)
+		assert.Equal(t, LogLevelTraceValue, level)
+
+		level, ok = LogLevelOverride(ForkContext(diagifiedCtx, WithLogLevel(LogLevelWarnValue)))
+		assert.True(t, ok)
+		assert.Equal(t, LogLevelWarnValue, level)
+	})
+	t.Run("ignores invalid level override", func(t *testing.T) {
+		diagContext := RootContext(NewRootContextParams().WithOutput(io.Discard))
+		diagifiedCtx := DiagifyContext(context.Background(), diagContext,
+			WithLogLevel(LogLevel("bad-"+fake.Lorem().Word())))
+		_, ok := LogLevelOverride(diagifiedCtx)
+		assert.False(t, ok)
+	})
 }
 
 func TestContext_ForkContext(t *testing.T) {
@@ -0,0 +1,111 @@
+// Package diagtest provides a logger factory that records log entries
+// in memory so tests can assert on them without parsing the log output.
+package diagtest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gocombo/diag"
+)
+
+// LoggerFactory is a diag.LoggerFactory that records all log entries in memory.
+// Entries of all the loggers created by the factory (root and child loggers) are recorded.
+type LoggerFactory struct {
+	diag.LoggerFactory
+	recorder *recorder
+}
+
+// NewLoggerFactory creates a new recording logger factory.
+// Use it with diag.NewRootContextParams().WithLoggerFactory
+func NewLoggerFactory() *LoggerFactory {
+	r := newRecorder()
+	return &LoggerFactory{
+		LoggerFactory: diag.NewSlogLoggerFactory(diag.WithSlogHandler(r)),
+		recorder:      r,
+	}
+}
+
+// NewRootContext creates a diag root context with trace level and
+// a recording logger factory
+func NewRootContext() (context.Context, *LoggerFactory) {
+	factory := NewLoggerFactory()
+	ctx := diag.RootContext(
+		diag.NewRootContextParams().
+			WithLogLevel(diag.LogLevelTraceValue).
+			WithLoggerFactory(factory),
+	)
+	return ctx, factory
+}
+
+// Entries returns all recorded entries in the order they were logged
+func (f *LoggerFactory) Entries() []Entry {
+	return f.recorder.entries()
+}
+
+// Reset removes all recorded entries
+func (f *LoggerFactory) Reset() {
+	f.recorder.reset()
+}
+
+// Filter returns entries that match all the matchers
+func (f *LoggerFactory) Filter(matchers ...Matcher) []Entry {
+	var result []Entry
+	for _, entry := range f.Entries() {
+		if matchAll(entry, matchers) {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// FilterByLevel returns entries of a given level
+func (f *LoggerFactory) FilterByLevel(level diag.LogLevel) []Entry {
+	return f.Filter(Level(level))
+}
+
+// Find returns the first entry that matches all the matchers
+func (f *LoggerFactory) Find(matchers ...Matcher) (Entry, bool) {
+	for _, entry := range f.Entries() {
+		if matchAll(entry, matchers) {
+			return entry, true
+		}
+	}
+	return Entry{}, false
+}
+
+// FindByMessage returns the first entry with a given message
+func (f *LoggerFactory) FindByMessage(msg string) (Entry, bool) {
+	return f.Find(Message(msg))
+}
+
+// RequireLogged fails the test if there is no entry matching all the matchers.
+// Returns the first matching entry.
+func (f *LoggerFactory) RequireLogged(t testing.TB, matchers ...Matcher) Entry {
+	t.Helper()
+	entry, ok := f.Find(matchers...)
+	if !ok {
+		t.Fatalf("no log entry matching %s, recorded entries:\n%s", describe(matchers), formatEntries(f.Entries()))
+	}
+	return entry
+}
+
+// RequireNotLogged fails the test if there is an entry matching all the matchers
+func (f *LoggerFactory) RequireNotLogged(t testing.TB, matchers ...Matcher) {
+	t.Helper()
+	if entry, ok := f.Find(matchers...); ok {
+		t.Fatalf("unexpected log entry matching %s:\n%s", describe(matchers), formatEntries([]Entry{entry}))
+	}
+}
+
+func formatEntries(entries []Entry) string {
+	if len(entries) == 0 {
+		return "  <none>"
+	}
+	lines := make([]string, len(entries))
+	for i, entry := range entries {
+		lines[i] = "  " + entry.String()
+	}
+	return strings.Join(lines, "\n")
+}
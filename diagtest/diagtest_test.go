@@ -0,0 +1,176 @@
+package diagtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/gocombo/diag"
+	"github.com/jaswdr/faker"
+	"github.com/stretchr/testify/assert"
+)
+
+var fake = faker.New()
+
+type mockTB struct {
+	testing.TB
+	failures []string
+}
+
+func (m *mockTB) Helper() {}
+
+func (m *mockTB) Fatalf(format string, args ...any) {
+	m.failures = append(m.failures, fmt.Sprintf(format, args...))
+}
+
+func TestLoggerFactory(t *testing.T) {
+	t.Run("records root and child entries", func(t *testing.T) {
+		rootCtx, factory := NewRootContext()
+
+		rootMsg := fake.Lorem().Sentence(3)
+		diag.Log(rootCtx).Info().Msg(rootMsg)
+
+		wantCorrelationID := fake.UUID().V4()
+		wantEntryValue := fake.Lorem().Word()
+		wantErr := errors.New(fake.Lorem().Sentence(3))
+		childCtx := diag.DiagifyContext(context.Background(), rootCtx,
+			diag.WithCorrelationID(wantCorrelationID),
+			diag.WithAppendDiagEntries(map[string]string{"entry1": wantEntryValue}),
+		)
+		childMsg := fake.Lorem().Sentence(3)
+		diag.Log(childCtx).Warn().
+			WithError(wantErr).
+			WithDataFn(func(data diag.MsgData) {
+				data.Str("str", "value")
+				data.Int("int", 10)
+				data.Dict("nested", diag.Log(childCtx).NewData().Bool("bool", true))
+			}).
+			Msg(childMsg)
+
+		entries := factory.Entries()
+		if !assert.Len(t, entries, 2) {
+			return
+		}
+		assert.Equal(t, diag.LogLevelInfoValue, entries[0].Level)
+		assert.Equal(t, rootMsg, entries[0].Msg)
+		assert.Equal(t, diag.DiagData(rootCtx).CorrelationID, entries[0].CorrelationID)
+		assert.Nil(t, entries[0].Data)
+		assert.Nil(t, entries[0].Err)
+		assert.NotZero(t, entries[0].Time)
+
+		child := entries[1]
+		assert.Equal(t, diag.LogLevelWarnValue, child.Level)
+		assert.Equal(t, childMsg, child.Msg)
+		assert.Equal(t, wantCorrelationID, child.CorrelationID)
+		assert.Equal(t, wantEntryValue, child.Context["entry1"])
+		assert.Equal(t, wantErr, child.Err)
+		assert.Equal(t, map[string]interface{}{
+			"str":    "value",
+			"int":    float64(10),
+			"nested": map[string]interface{}{"bool": true},
+		}, child.Data)
+		assert.Empty(t, child.Fields)
+	})
+
+	t.Run("records cloud adapter fields", func(t *testing.T) {
+		factory := NewLoggerFactory()
+		ctx := diag.RootContext(diag.NewRootContextParams().
+			WithLoggerFactory(factory).
+			WithGCPCloudAdapter())
+		diag.Log(ctx).Error().Msg(fake.Lorem().Sentence(3))
+		entry := factory.RequireLogged(t, Level(diag.LogLevelErrorValue))
+		assert.Equal(t, "ERROR", entry.Fields["severity"])
+		sourceLocation, ok := entry.Fields["logging.googleapis.com/sourceLocation"].(map[string]interface{})
+		if assert.True(t, ok) {
+			assert.Contains(t, sourceLocation["file"], "diagtest_test.go")
+		}
+	})
+
+	t.Run("respects root log level", func(t *testing.T) {
+		factory := NewLoggerFactory()
+		ctx := diag.RootContext(diag.NewRootContextParams().
+			WithLogLevel(diag.LogLevelInfoValue).
+			WithLoggerFactory(factory))
+		diag.Log(ctx).Debug().Msg(fake.Lorem().Sentence(3))
+		assert.Empty(t, factory.Entries())
+	})
+
+	t.Run("query helpers", func(t *testing.T) {
+		ctx, factory := NewRootContext()
+		log := diag.Log(ctx)
+		log.Info().Msg("info 1")
+		log.Debug().Msg("debug 1")
+		log.Info().WithData(log.NewData().Str("key", "value")).Msg("info 2")
+
+		assert.Len(t, factory.FilterByLevel(diag.LogLevelInfoValue), 2)
+		assert.Len(t, factory.FilterByLevel(diag.LogLevelErrorValue), 0)
+		assert.Len(t, factory.Filter(MessageContains("info"), HasData("key")), 1)
+
+		entry, ok := factory.FindByMessage("debug 1")
+		assert.True(t, ok)
+		assert.Equal(t, diag.LogLevelDebugValue, entry.Level)
+
+		_, ok = factory.FindByMessage("missing")
+		assert.False(t, ok)
+
+		factory.Reset()
+		assert.Empty(t, factory.Entries())
+	})
+
+	t.Run("records entries of slog handler with attrs and groups", func(t *testing.T) {
+		factory := NewLoggerFactory()
+		handler := factory.recorder.WithAttrs([]slog.Attr{slog.String("attr", "value")}).WithGroup("group")
+		slog.New(handler).Info("msg", "key", "value")
+		entry := factory.RequireLogged(t, Message("msg"))
+		assert.Equal(t, map[string]interface{}{
+			"attr":  "value",
+			"group": map[string]interface{}{"key": "value"},
+		}, entry.Fields)
+	})
+
+	t.Run("RequireLogged", func(t *testing.T) {
+		ctx, factory := NewRootContext()
+		wantErr := errors.New(fake.Lorem().Sentence(3))
+		log := diag.Log(ctx)
+		log.Error().
+			WithError(fmt.Errorf("wrapped: %w", wantErr)).
+			WithData(log.NewData().
+				Int("count", 3).
+				Dict("nested", log.NewData().Str("key", "value"))).
+			Msg("failed to process")
+
+		entry := factory.RequireLogged(t,
+			Level(diag.LogLevelErrorValue),
+			Message("failed to process"),
+			CorrelationID(diag.DiagData(ctx).CorrelationID),
+			Error(wantErr),
+			DataValue("count", 3),
+			DataValue("nested.key", "value"),
+		)
+		assert.Equal(t, "failed to process", entry.Msg)
+		factory.RequireNotLogged(t, Level(diag.LogLevelInfoValue))
+
+		mock := &mockTB{TB: t}
+		factory.RequireLogged(mock, Message("other message"), DataValue("nested.key.missing", "value"))
+		if assert.Len(t, mock.failures, 1) {
+			assert.Contains(t, mock.failures[0], `no log entry matching msg="other message", data.nested.key.missing=value`)
+			assert.Contains(t, mock.failures[0], `[error] "failed to process"`)
+			assert.Contains(t, mock.failures[0], "wrapped: ")
+		}
+
+		mock = &mockTB{TB: t}
+		factory.RequireNotLogged(mock)
+		if assert.Len(t, mock.failures, 1) {
+			assert.Contains(t, mock.failures[0], "unexpected log entry matching <any>")
+		}
+
+		factory.Reset()
+		mock = &mockTB{TB: t}
+		factory.RequireLogged(mock, ContextEntry("key", "value"))
+		if assert.Len(t, mock.failures, 1) {
+			assert.Contains(t, mock.failures[0], "<none>")
+		}
+	})
+}
@@ -0,0 +1,138 @@
+package diagtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/gocombo/diag"
+)
+
+// Matcher is a condition a recorded entry should satisfy
+type Matcher struct {
+	description string
+	matches     func(entry Entry) bool
+}
+
+// String returns the matcher description
+func (m Matcher) String() string {
+	return m.description
+}
+
+// Matches returns true if the entry satisfies the matcher
+func (m Matcher) Matches(entry Entry) bool {
+	return m.matches(entry)
+}
+
+// NewMatcher creates a custom matcher
+func NewMatcher(description string, matches func(entry Entry) bool) Matcher {
+	return Matcher{description: description, matches: matches}
+}
+
+// Level matches entries of a given level
+func Level(level diag.LogLevel) Matcher {
+	return NewMatcher(fmt.Sprintf("level=%s", level), func(entry Entry) bool {
+		return entry.Level == level
+	})
+}
+
+// Message matches entries with exactly a given message
+func Message(msg string) Matcher {
+	return NewMatcher(fmt.Sprintf("msg=%q", msg), func(entry Entry) bool {
+		return entry.Msg == msg
+	})
+}
+
+// MessageContains matches entries with a message that contains a given substring
+func MessageContains(substr string) Matcher {
+	return NewMatcher(fmt.Sprintf("msg contains %q", substr), func(entry Entry) bool {
+		return strings.Contains(entry.Msg, substr)
+	})
+}
+
+// CorrelationID matches entries logged with a given correlation id
+func CorrelationID(correlationID string) Matcher {
+	return NewMatcher(fmt.Sprintf("correlationId=%q", correlationID), func(entry Entry) bool {
+		return entry.CorrelationID == correlationID
+	})
+}
+
+// Error matches entries with an error that matches target via errors.Is
+func Error(target error) Matcher {
+	return NewMatcher(fmt.Sprintf("error=%v", target), func(entry Entry) bool {
+		return errors.Is(entry.Err, target)
+	})
+}
+
+// ContextEntry matches entries with a given diag entry in the context
+func ContextEntry(key, value string) Matcher {
+	return NewMatcher(fmt.Sprintf("context.%s=%q", key, value), func(entry Entry) bool {
+		return entry.Context[key] == value
+	})
+}
+
+// DataValue matches entries with a given data value. The value is
+// compared in its JSON form so e.g. int and float64 values are equal.
+// Use dot separated key to match nested dictionaries values.
+func DataValue(key string, value interface{}) Matcher {
+	want := jsonify(value)
+	return NewMatcher(fmt.Sprintf("data.%s=%v", key, value), func(entry Entry) bool {
+		got, ok := lookup(entry.Data, strings.Split(key, "."))
+		return ok && reflect.DeepEqual(want, got)
+	})
+}
+
+// HasData matches entries that have a given data key.
+// Use dot separated key to match nested dictionaries keys.
+func HasData(key string) Matcher {
+	return NewMatcher(fmt.Sprintf("has data.%s", key), func(entry Entry) bool {
+		_, ok := lookup(entry.Data, strings.Split(key, "."))
+		return ok
+	})
+}
+
+func lookup(data map[string]interface{}, path []string) (interface{}, bool) {
+	value, ok := data[path[0]]
+	if !ok || len(path) == 1 {
+		return value, ok
+	}
+	nested, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	return lookup(nested, path[1:])
+}
+
+func jsonify(value interface{}) interface{} {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var result interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return value
+	}
+	return result
+}
+
+func matchAll(entry Entry, matchers []Matcher) bool {
+	for _, matcher := range matchers {
+		if !matcher.Matches(entry) {
+			return false
+		}
+	}
+	return true
+}
+
+func describe(matchers []Matcher) string {
+	if len(matchers) == 0 {
+		return "<any>"
+	}
+	descriptions := make([]string, len(matchers))
+	for i, matcher := range matchers {
+		descriptions[i] = matcher.String()
+	}
+	return strings.Join(descriptions, ", ")
+}
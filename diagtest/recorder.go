@@ -0,0 +1,153 @@
+package diagtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gocombo/diag"
+)
+
+// Entry is a log entry recorded by the LoggerFactory.
+// Data, Context and Fields values are in the same form as they
+// would be after unmarshaling of the JSON log output (e.g. numbers are float64)
+type Entry struct {
+	Time  time.Time
+	Level diag.LogLevel
+	Msg   string
+
+	// Err is an error attached to the entry via WithError
+	Err error
+
+	// CorrelationID of the context the entry was logged with
+	CorrelationID string
+
+	// Context holds all the context fields including correlation id and diag entries
+	Context map[string]interface{}
+
+	// Data holds the entry data added via WithData or WithDataFn
+	Data map[string]interface{}
+
+	// Fields holds all other top level fields, e.g. added by cloud platform adapters
+	Fields map[string]interface{}
+}
+
+// String formats the entry for test failure messages
+func (e Entry) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[%s] %q correlationId=%q", e.Level, e.Msg, e.CorrelationID)
+	if e.Err != nil {
+		fmt.Fprintf(&sb, " error=%q", e.Err)
+	}
+	if len(e.Data) > 0 {
+		fmt.Fprintf(&sb, " data=%v", e.Data)
+	}
+	return sb.String()
+}
+
+type recorderStore struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	entries []Entry
+}
+
+// recorder is a slog.Handler that records entries in memory.
+// Records are first rendered by the JSON handler so recorded values
+// match the real output.
+type recorder struct {
+	store       *recorderStore
+	jsonHandler slog.Handler
+}
+
+func dropBuiltinAttrs(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 {
+		switch a.Key {
+		case slog.TimeKey, slog.LevelKey, slog.MessageKey:
+			return slog.Attr{}
+		}
+	}
+	return a
+}
+
+func newRecorder() *recorder {
+	store := &recorderStore{}
+	return &recorder{
+		store: store,
+		jsonHandler: slog.NewJSONHandler(&store.buf, &slog.HandlerOptions{
+			Level:       diag.SlogLevelTrace,
+			ReplaceAttr: dropBuiltinAttrs,
+		}),
+	}
+}
+
+func (r *recorder) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (r *recorder) Handle(ctx context.Context, record slog.Record) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.buf.Reset()
+	if err := r.jsonHandler.Handle(ctx, record); err != nil {
+		return err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(r.store.buf.Bytes(), &fields); err != nil {
+		return fmt.Errorf("failed to unmarshal recorded entry: %w", err)
+	}
+
+	entry := Entry{
+		Time:  record.Time,
+		Level: diag.LogLevelFromSlog(record.Level),
+		Msg:   record.Message,
+	}
+	record.Attrs(func(attr slog.Attr) bool {
+		if err, ok := attr.Value.Any().(error); ok && attr.Key == "error" {
+			entry.Err = err
+			return false
+		}
+		return true
+	})
+	if contextFields, ok := fields["context"].(map[string]interface{}); ok {
+		entry.Context = contextFields
+		if correlationID, ok := contextFields["correlationId"].(string); ok {
+			entry.CorrelationID = correlationID
+		}
+		delete(fields, "context")
+	}
+	if data, ok := fields["data"].(map[string]interface{}); ok {
+		entry.Data = data
+		delete(fields, "data")
+	}
+	delete(fields, "error")
+	entry.Fields = fields
+
+	r.store.entries = append(r.store.entries, entry)
+	return nil
+}
+
+func (r *recorder) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &recorder{store: r.store, jsonHandler: r.jsonHandler.WithAttrs(attrs)}
+}
+
+func (r *recorder) WithGroup(name string) slog.Handler {
+	return &recorder{store: r.store, jsonHandler: r.jsonHandler.WithGroup(name)}
+}
+
+func (r *recorder) entries() []Entry {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	return append([]Entry{}, r.store.entries...)
+}
+
+func (r *recorder) reset() {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.entries = nil
+}
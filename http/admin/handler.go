@@ -0,0 +1,227 @@
+// Package admin provides an http handler to inspect and change diagnostics settings at runtime.
+// The handler has no authentication so it should only be exposed on an internal port.
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/gocombo/diag"
+	"github.com/gocombo/diag/http/internal"
+)
+
+const maxRequestBodySize = 1024
+
+type handlerCfg struct {
+	readOnly    bool
+	changedByFn func(req *http.Request) string
+}
+
+type HandlerOpt func(cfg *handlerCfg)
+
+// WithReadOnly rejects all the requests that change settings
+func WithReadOnly() HandlerOpt {
+	return func(cfg *handlerCfg) {
+		cfg.readOnly = true
+	}
+}
+
+// WithChangedBy sets a function that describes who initiated the change.
+// The value is logged and reported with the level. Default is "admin-api <remote addr>"
+func WithChangedBy(changedByFn func(req *http.Request) string) HandlerOpt {
+	return func(cfg *handlerCfg) {
+		cfg.changedByFn = changedByFn
+	}
+}
+
+type levelRequest struct {
+	Level diag.LogLevel `json:"level"`
+}
+
+type levelResponse struct {
+	Level      diag.LogLevel     `json:"level"`
+	LastChange *diag.LevelChange `json:"lastChange,omitempty"`
+}
+
+type overridesResponse struct {
+	Overrides []diag.CorrelationLevel `json:"overrides"`
+}
+
+type statsResponse struct {
+	RuntimeMemMb float64 `json:"runtimeMemMb"`
+	Goroutines   int     `json:"goroutines"`
+	NumGC        uint32  `json:"numGC"`
+	GoVersion    string  `json:"goVersion"`
+	UptimeSec    float64 `json:"uptimeSec"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+type handler struct {
+	logger    diag.LevelLogger
+	control   *diag.LogLevelControl
+	cfg       handlerCfg
+	startedAt time.Time
+}
+
+// NewHandler creates a handler that serves the following routes relative to the mount point
+// (use http.StripPrefix when mounting on a sub path):
+//
+//	GET /level - current level of the root logger and its last change
+//	PUT /level - change the level, body: {"level": "debug"}
+//	GET /overrides - per correlation id levels
+//	PUT /overrides/{correlationId} - set a level of a correlation id, body: {"level": "debug"}
+//	DELETE /overrides/{correlationId} - remove a level of a correlation id
+//	GET /stats - basic runtime stats
+//
+// All the responses are JSON.
+func NewHandler(rootCtx context.Context, opts ...HandlerOpt) http.Handler {
+	cfg := handlerCfg{
+		changedByFn: func(req *http.Request) string {
+			return "admin-api " + req.RemoteAddr
+		},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &handler{
+		logger:    diag.Log(rootCtx),
+		control:   diag.LevelControl(rootCtx),
+		cfg:       cfg,
+		startedAt: time.Now(),
+	}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	path := strings.Trim(req.URL.Path, "/")
+	switch {
+	case path == "level":
+		h.route(w, req, map[string]http.HandlerFunc{
+			http.MethodGet: h.getLevel,
+			http.MethodPut: h.putLevel,
+		})
+	case path == "overrides":
+		h.route(w, req, map[string]http.HandlerFunc{
+			http.MethodGet: h.getOverrides,
+		})
+	case strings.HasPrefix(path, "overrides/") && len(path) > len("overrides/"):
+		h.route(w, req, map[string]http.HandlerFunc{
+			http.MethodPut:    h.putOverride,
+			http.MethodDelete: h.deleteOverride,
+		})
+	case path == "stats":
+		h.route(w, req, map[string]http.HandlerFunc{
+			http.MethodGet: h.getStats,
+		})
+	default:
+		h.writeJSON(w, http.StatusNotFound, errorResponse{Error: fmt.Sprintf("%s not found", req.URL.Path)})
+	}
+}
+
+func (h *handler) route(w http.ResponseWriter, req *http.Request, handlers map[string]http.HandlerFunc) {
+	handlerFn, ok := handlers[req.Method]
+	if !ok {
+		allowed := make([]string, 0, len(handlers))
+		for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodDelete} {
+			if _, ok := handlers[method]; ok {
+				allowed = append(allowed, method)
+			}
+		}
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		h.writeJSON(w, http.StatusMethodNotAllowed, errorResponse{Error: fmt.Sprintf("method %s not allowed", req.Method)})
+		return
+	}
+	if req.Method != http.MethodGet && h.cfg.readOnly {
+		h.writeJSON(w, http.StatusForbidden, errorResponse{Error: "settings are read only"})
+		return
+	}
+	handlerFn(w, req)
+}
+
+func (h *handler) getLevel(w http.ResponseWriter, _ *http.Request) {
+	res := levelResponse{Level: h.control.Level()}
+	if lastChange, ok := h.control.LastChange(); ok {
+		res.LastChange = &lastChange
+	}
+	h.writeJSON(w, http.StatusOK, res)
+}
+
+func (h *handler) putLevel(w http.ResponseWriter, req *http.Request) {
+	level, ok := h.readLevel(w, req)
+	if !ok {
+		return
+	}
+	if err := h.control.SetLevel(level, h.cfg.changedByFn(req)); err != nil {
+		h.writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	h.getLevel(w, req)
+}
+
+func (h *handler) getOverrides(w http.ResponseWriter, _ *http.Request) {
+	h.writeJSON(w, http.StatusOK, overridesResponse{Overrides: h.control.CorrelationLevels()})
+}
+
+func (h *handler) putOverride(w http.ResponseWriter, req *http.Request) {
+	level, ok := h.readLevel(w, req)
+	if !ok {
+		return
+	}
+	correlationID := strings.TrimPrefix(strings.Trim(req.URL.Path, "/"), "overrides/")
+	if err := h.control.SetCorrelationLevel(correlationID, level, h.cfg.changedByFn(req)); err != nil {
+		h.writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	h.getOverrides(w, req)
+}
+
+func (h *handler) deleteOverride(w http.ResponseWriter, req *http.Request) {
+	correlationID := strings.TrimPrefix(strings.Trim(req.URL.Path, "/"), "overrides/")
+	if !h.control.RemoveCorrelationLevel(correlationID, h.cfg.changedByFn(req)) {
+		h.writeJSON(w, http.StatusNotFound, errorResponse{Error: fmt.Sprintf("no level for %s", correlationID)})
+		return
+	}
+	h.getOverrides(w, req)
+}
+
+func (h *handler) getStats(w http.ResponseWriter, _ *http.Request) {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	h.writeJSON(w, http.StatusOK, statsResponse{
+		RuntimeMemMb: internal.RuntimeMemMb(),
+		Goroutines:   runtime.NumGoroutine(),
+		NumGC:        memStats.NumGC,
+		GoVersion:    runtime.Version(),
+		UptimeSec:    time.Since(h.startedAt).Seconds(),
+	})
+}
+
+// readLevel reads the level from the request body, the level is validated by the level control.
+// Error response is written if the level can not be read
+func (h *handler) readLevel(w http.ResponseWriter, req *http.Request) (diag.LogLevel, bool) {
+	var body levelRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, req.Body, maxRequestBodySize))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&body); err != nil {
+		h.writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid request body: %v", err)})
+		return "", false
+	}
+	return body.Level, true
+}
+
+func (h *handler) writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		h.logger.Warn().WithError(err).Msg("Failed to write admin response")
+	}
+}
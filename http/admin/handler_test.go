@@ -0,0 +1,170 @@
+package admin
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gocombo/diag"
+	"github.com/jaswdr/faker"
+	"github.com/stretchr/testify/assert"
+)
+
+var fake = faker.New()
+
+func newHandler() (h http.Handler, control *diag.LogLevelControl) {
+	rootCtx := diag.RootContext(diag.NewRootContextParams().
+		WithLogLevel(diag.LogLevelInfoValue).
+		WithOutput(io.Discard))
+	return NewHandler(rootCtx), diag.LevelControl(rootCtx)
+}
+
+func serve(h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, req)
+	return res
+}
+
+func decode[T any](t *testing.T, res *httptest.ResponseRecorder) T {
+	var body T
+	assert.Equal(t, "application/json", res.Header().Get("Content-Type"))
+	assert.Equal(t, "no-store", res.Header().Get("Cache-Control"))
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+	return body
+}
+
+func TestHandler(t *testing.T) {
+	t.Run("GET /level", func(t *testing.T) {
+		h, _ := newHandler()
+		res := serve(h, http.MethodGet, "/level", "")
+		assert.Equal(t, http.StatusOK, res.Code)
+		body := decode[levelResponse](t, res)
+		assert.Equal(t, diag.LogLevelInfoValue, body.Level)
+		assert.Nil(t, body.LastChange)
+	})
+	t.Run("PUT /level", func(t *testing.T) {
+		t.Run("changes the level", func(t *testing.T) {
+			h, control := newHandler()
+			res := serve(h, http.MethodPut, "/level", `{"level":"debug"}`)
+			assert.Equal(t, http.StatusOK, res.Code)
+			body := decode[levelResponse](t, res)
+			assert.Equal(t, diag.LogLevelDebugValue, body.Level)
+			assert.Equal(t, diag.LogLevelDebugValue, control.Level())
+			if assert.NotNil(t, body.LastChange) {
+				assert.Equal(t, diag.LogLevelInfoValue, body.LastChange.PreviousLevel)
+				assert.Equal(t, "admin-api 192.0.2.1:1234", body.LastChange.ChangedBy)
+			}
+		})
+		t.Run("uses custom changedBy", func(t *testing.T) {
+			rootCtx := diag.RootContext(diag.NewRootContextParams().WithOutput(io.Discard))
+			wantChangedBy := fake.Lorem().Word()
+			h := NewHandler(rootCtx, WithChangedBy(func(req *http.Request) string {
+				return wantChangedBy
+			}))
+			res := serve(h, http.MethodPut, "/level", `{"level":"warn"}`)
+			assert.Equal(t, http.StatusOK, res.Code)
+			change, _ := diag.LevelControl(rootCtx).LastChange()
+			assert.Equal(t, wantChangedBy, change.ChangedBy)
+		})
+		t.Run("rejects invalid level", func(t *testing.T) {
+			h, control := newHandler()
+			badLevel := "bad-" + fake.Lorem().Word()
+			res := serve(h, http.MethodPut, "/level", `{"level":"`+badLevel+`"}`)
+			assert.Equal(t, http.StatusBadRequest, res.Code)
+			assert.Equal(t, "invalid log level "+badLevel, decode[errorResponse](t, res).Error)
+			assert.Equal(t, diag.LogLevelInfoValue, control.Level())
+		})
+		t.Run("rejects invalid body", func(t *testing.T) {
+			h, _ := newHandler()
+			for _, body := range []string{
+				"",
+				`{"level":`,
+				`{"level":"debug","other":1}`,
+				`{"level":"` + strings.Repeat("a", maxRequestBodySize) + `"}`,
+			} {
+				res := serve(h, http.MethodPut, "/level", body)
+				assert.Equal(t, http.StatusBadRequest, res.Code)
+				assert.Contains(t, decode[errorResponse](t, res).Error, "invalid request body")
+			}
+		})
+	})
+	t.Run("overrides", func(t *testing.T) {
+		h, control := newHandler()
+		correlationID := fake.UUID().V4()
+
+		res := serve(h, http.MethodGet, "/overrides", "")
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Empty(t, decode[overridesResponse](t, res).Overrides)
+
+		res = serve(h, http.MethodPut, "/overrides/"+correlationID, `{"level":"trace"}`)
+		assert.Equal(t, http.StatusOK, res.Code)
+		overrides := decode[overridesResponse](t, res).Overrides
+		if assert.Len(t, overrides, 1) {
+			assert.Equal(t, correlationID, overrides[0].CorrelationID)
+			assert.Equal(t, diag.LogLevelTraceValue, overrides[0].Level)
+		}
+		assert.Equal(t, overrides[0].CorrelationID, control.CorrelationLevels()[0].CorrelationID)
+
+		res = serve(h, http.MethodGet, "/overrides/", "")
+		assert.Len(t, decode[overridesResponse](t, res).Overrides, 1)
+
+		res = serve(h, http.MethodPut, "/overrides/"+correlationID, `{"level":"bad"}`)
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+		res = serve(h, http.MethodPut, "/overrides/"+correlationID, `{`)
+		assert.Equal(t, http.StatusBadRequest, res.Code)
+
+		res = serve(h, http.MethodDelete, "/overrides/"+correlationID, "")
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Empty(t, decode[overridesResponse](t, res).Overrides)
+
+		res = serve(h, http.MethodDelete, "/overrides/"+correlationID, "")
+		assert.Equal(t, http.StatusNotFound, res.Code)
+		assert.Equal(t, "no level for "+correlationID, decode[errorResponse](t, res).Error)
+	})
+	t.Run("GET /stats", func(t *testing.T) {
+		h, _ := newHandler()
+		res := serve(h, http.MethodGet, "/stats", "")
+		assert.Equal(t, http.StatusOK, res.Code)
+		body := decode[statsResponse](t, res)
+		assert.Greater(t, body.RuntimeMemMb, 0.0)
+		assert.Greater(t, body.Goroutines, 0)
+		assert.NotEmpty(t, body.GoVersion)
+		assert.GreaterOrEqual(t, body.UptimeSec, 0.0)
+	})
+	t.Run("not found", func(t *testing.T) {
+		h, _ := newHandler()
+		path := "/" + fake.Lorem().Word()
+		res := serve(h, http.MethodGet, path, "")
+		assert.Equal(t, http.StatusNotFound, res.Code)
+		assert.Equal(t, path+" not found", decode[errorResponse](t, res).Error)
+	})
+	t.Run("method not allowed", func(t *testing.T) {
+		h, _ := newHandler()
+		res := serve(h, http.MethodPost, "/level", "")
+		assert.Equal(t, http.StatusMethodNotAllowed, res.Code)
+		assert.Equal(t, "GET, PUT", res.Header().Get("Allow"))
+		assert.Equal(t, "method POST not allowed", decode[errorResponse](t, res).Error)
+	})
+	t.Run("read only", func(t *testing.T) {
+		rootCtx := diag.RootContext(diag.NewRootContextParams().WithOutput(io.Discard))
+		h := NewHandler(rootCtx, WithReadOnly())
+		res := serve(h, http.MethodPut, "/level", `{"level":"error"}`)
+		assert.Equal(t, http.StatusForbidden, res.Code)
+		assert.Equal(t, "settings are read only", decode[errorResponse](t, res).Error)
+		assert.Equal(t, diag.LogLevelDebugValue, diag.LevelControl(rootCtx).Level())
+
+		res = serve(h, http.MethodGet, "/level", "")
+		assert.Equal(t, http.StatusOK, res.Code)
+	})
+	t.Run("works with StripPrefix", func(t *testing.T) {
+		h, _ := newHandler()
+		mux := http.NewServeMux()
+		mux.Handle("/admin/", http.StripPrefix("/admin", h))
+		res := serve(mux, http.MethodGet, "/admin/level", "")
+		assert.Equal(t, http.StatusOK, res.Code)
+	})
+}
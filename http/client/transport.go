@@ -7,6 +7,8 @@ import (
 
 	"github.com/gocombo/diag"
 	"github.com/gocombo/diag/http/internal"
+	"github.com/gocombo/diag/http/redact"
+	"golang.org/x/exp/slices"
 )
 
 type roundTripperFn func(*http.Request) (*http.Response, error)
@@ -15,12 +17,30 @@ func (fn roundTripperFn) RoundTrip(req *http.Request) (*http.Response, error) {
 	return fn(req)
 }
 
+// bodyCaptures holds request and response bodies captured for logging
+type bodyCaptures struct {
+	bodyLog *internal.BodyLogConfig
+	req     *internal.BodyCapture
+	res     *internal.BodyCapture
+}
+
+func (b bodyCaptures) appendLogData(data diag.MsgData) {
+	if b.req != nil {
+		b.req.AppendLogData(b.bodyLog, data, "requestBody")
+	}
+	if b.res != nil {
+		b.res.AppendLogData(b.bodyLog, data, "responseBody")
+	}
+}
+
 func writeLogEndMessage(
 	log diag.LevelLogger,
 	durationSec float64,
-	req *http.Request,
+	logURL string,
 	res *http.Response,
 	obfuscateHeaders []string,
+	redactor *redact.Redactor,
+	bodies bodyCaptures,
 ) {
 	var levelLog diag.LogLevelEvent
 	var resCode int
@@ -41,16 +61,33 @@ func writeLogEndMessage(
 		Float64("durationSec", durationSec).
 		Int("statusCode", resCode)
 	if res != nil {
-		logData = logData.Interface("headers", internal.FlattenAndObfuscate(res.Header, obfuscateHeaders))
+		logData = logData.Interface("headers", redactor.Map(internal.FlattenAndObfuscate(res.Header, obfuscateHeaders)))
 	}
+	bodies.appendLogData(logData)
 
 	levelLog.
 		WithData(logData).
-		Msgf("COMPLETE SENDING REQ: %d - %v", resCode, req.URL.String())
+		Msgf("COMPLETE SENDING REQ: %d - %v", resCode, logURL)
 }
 
 type transportCfg struct {
-	obfuscateHeaders []string
+	obfuscateHeaders     []string
+	obfuscateQueryParams []string
+
+	correlationIDHeader  string
+	propagateTraceParent bool
+	propagatedEntries    map[string]string
+	noPropagationHosts   []string
+
+	levelOverrideHeader string
+	levelSecretHeader   string
+	levelSecret         string
+	levelSecretHosts    []string
+
+	bodyMaxBytes      int
+	bodyContentTypes  []string
+	redactor          *redact.Redactor
+	redactedBodyPaths []string
 }
 
 // TransportOption is a functional option for configuring the transport
@@ -67,28 +104,182 @@ func WithObfuscateHeaders(headers ...string) TransportOption {
 	}
 }
 
+// WithObfuscateQueryParams sets additional query params that should be obfuscated in the logs
+// Default obfuscated query params are defined in internal.DefaultObfuscatedQueryParams
+func WithObfuscateQueryParams(params ...string) TransportOption {
+	return func(cfg *transportCfg) {
+		for _, param := range params {
+			cfg.obfuscateQueryParams = append(cfg.obfuscateQueryParams, strings.ToLower(param))
+		}
+	}
+}
+
+// WithCorrelationIDHeader sets the header used to propagate the correlation id.
+// Default is x-correlation-id, empty name disables correlation id propagation
+func WithCorrelationIDHeader(name string) TransportOption {
+	return func(cfg *transportCfg) {
+		cfg.correlationIDHeader = name
+	}
+}
+
+// WithTraceParentPropagation enables propagation of the W3C traceparent and tracestate
+// headers. Each request is sent with a new child span id of the current trace.
+func WithTraceParentPropagation() TransportOption {
+	return func(cfg *transportCfg) {
+		cfg.propagateTraceParent = true
+	}
+}
+
+// WithPropagatedEntries will send diag entries as request headers.
+// The entries map is a diag entry key to header name map
+func WithPropagatedEntries(entries map[string]string) TransportOption {
+	return func(cfg *transportCfg) {
+		for entryKey, header := range entries {
+			cfg.propagatedEntries[entryKey] = header
+		}
+	}
+}
+
+// WithLevelOverridePropagation will send the level override of the request context
+// (see diag.LogLevelOverride) in a given header, so downstream services configured
+// with server.WithLevelOverrideHeader log the request at the same level
+func WithLevelOverridePropagation(header string) TransportOption {
+	return func(cfg *transportCfg) {
+		cfg.levelOverrideHeader = header
+	}
+}
+
+// WithLevelOverrideSecret will send the secret in the secretHeader along with the level override
+// to given hosts only, so the secret is not leaked to other services or third party APIs.
+// Hosts are matched without port. The secret header is obfuscated in the logs
+func WithLevelOverrideSecret(secretHeader, secret string, hosts ...string) TransportOption {
+	return func(cfg *transportCfg) {
+		cfg.levelSecretHeader = secretHeader
+		cfg.levelSecret = secret
+		for _, host := range hosts {
+			cfg.levelSecretHosts = append(cfg.levelSecretHosts, strings.ToLower(host))
+		}
+		cfg.obfuscateHeaders = append(cfg.obfuscateHeaders, strings.ToLower(secretHeader))
+	}
+}
+
+// WithBodyLogging enables logging of request and response bodies of given content types
+// (e.g. application/json, text/*), application/json is used if no content types provided.
+// Up to maxBytes of each body is logged with the COMPLETE SENDING REQ entry. The response body
+// is read up to maxBytes before the response is returned, the caller still gets the whole body.
+func WithBodyLogging(maxBytes int, contentTypes ...string) TransportOption {
+	return func(cfg *transportCfg) {
+		cfg.bodyMaxBytes = maxBytes
+		cfg.bodyContentTypes = contentTypes
+	}
+}
+
+// WithRedactedBodyFields will mask values of JSON and form bodies at given dot separated
+// key paths (e.g. user.password). Bodies that can not be redacted (e.g. text or truncated) are not logged.
+func WithRedactedBodyFields(paths ...string) TransportOption {
+	return func(cfg *transportCfg) {
+		cfg.redactedBodyPaths = append(cfg.redactedBodyPaths, paths...)
+	}
+}
+
+// WithRedactor applies the redactor to the logged headers and bodies.
+// Obfuscated headers are still obfuscated.
+func WithRedactor(redactor *redact.Redactor) TransportOption {
+	return func(cfg *transportCfg) {
+		cfg.redactor = redactor
+	}
+}
+
+// WithoutPropagationForHosts disables diag headers propagation for given hosts
+// (e.g. third party APIs). Hosts are matched without port
+func WithoutPropagationForHosts(hosts ...string) TransportOption {
+	return func(cfg *transportCfg) {
+		for _, host := range hosts {
+			cfg.noPropagationHosts = append(cfg.noPropagationHosts, strings.ToLower(host))
+		}
+	}
+}
+
+// withPropagationHeaders returns a copy of the request with diag headers added.
+// Headers already set by the caller are not overwritten.
+func (cfg *transportCfg) withPropagationHeaders(req *http.Request) *http.Request {
+	host := strings.ToLower(req.URL.Hostname())
+	if slices.Contains(cfg.noPropagationHosts, host) {
+		return req
+	}
+
+	diagData := diag.DiagData(req.Context())
+	outReq := req.Clone(req.Context())
+	setHeader := func(name, value string) {
+		if value != "" && outReq.Header.Get(name) == "" {
+			outReq.Header.Set(name, value)
+		}
+	}
+	if cfg.correlationIDHeader != "" {
+		setHeader(cfg.correlationIDHeader, diagData.CorrelationID)
+	}
+	if cfg.propagateTraceParent && diagData.Trace.IsValid() && outReq.Header.Get("traceparent") == "" {
+		span := diagData.Trace.ChildSpan()
+		outReq.Header.Set("traceparent", span.TraceParent())
+		setHeader("tracestate", span.TraceState)
+	}
+	for entryKey, header := range cfg.propagatedEntries {
+		setHeader(header, diagData.Entries[entryKey])
+	}
+	if level, ok := diag.LogLevelOverride(req.Context()); ok && cfg.levelOverrideHeader != "" {
+		setHeader(cfg.levelOverrideHeader, level.String())
+		if cfg.levelSecretHeader != "" && slices.Contains(cfg.levelSecretHosts, host) {
+			setHeader(cfg.levelSecretHeader, cfg.levelSecret)
+		}
+	}
+	return outReq
+}
+
 // NewTransport returns a wrapped http.RoundTripper that will produce
 // diag like logs for each request
-// TODO: Unit test this
 func NewTransport(target http.RoundTripper, opts ...TransportOption) http.RoundTripper {
 	cfg := &transportCfg{
-		obfuscateHeaders: internal.DefaultObfuscatedHeaders,
+		obfuscateHeaders:     internal.DefaultObfuscatedHeaders,
+		obfuscateQueryParams: internal.DefaultObfuscatedQueryParams,
+		correlationIDHeader:  "x-correlation-id",
+		propagatedEntries:    map[string]string{},
 	}
 	for _, opt := range opts {
 		opt(cfg)
 	}
+	redactor := internal.WithRedactedPaths(cfg.redactor, cfg.redactedBodyPaths)
+	var bodyLog *internal.BodyLogConfig
+	if cfg.bodyMaxBytes > 0 {
+		bodyLog = internal.NewBodyLogConfig(cfg.bodyMaxBytes, cfg.bodyContentTypes, redactor)
+	}
 	return roundTripperFn(func(req *http.Request) (*http.Response, error) {
 		log := diag.Log(req.Context())
+		req = cfg.withPropagationHeaders(req)
+		bodies := bodyCaptures{bodyLog: bodyLog}
+		if req.Body != nil {
+			bodies.req = bodyLog.NewCapture(req.Header.Get("Content-Type"))
+		}
+		if bodies.req != nil {
+			req = req.WithContext(req.Context())
+			req.Body = internal.TeeBody(req.Body, bodies.req)
+		}
+		logURL := internal.ObfuscateURL(req.URL, cfg.obfuscateQueryParams, redactor).String()
 		log.Info().WithData(
 			log.NewData().
-				Interface("headers", internal.FlattenAndObfuscate(req.Header, cfg.obfuscateHeaders)).
+				Interface("headers", redactor.Map(internal.FlattenAndObfuscate(req.Header, cfg.obfuscateHeaders))).
 				Str("method", req.Method).
-				Str("url", req.URL.String()),
-		).Msgf("START SENDING REQ: %s %s", strings.ToUpper(req.Method), req.URL)
+				Str("url", logURL),
+		).Msgf("START SENDING REQ: %s %s", strings.ToUpper(req.Method), logURL)
 		startedAt := time.Now()
 		res, err := target.RoundTrip(req)
 		reqDuration := time.Since(startedAt).Seconds()
-		writeLogEndMessage(log, reqDuration, req, res, cfg.obfuscateHeaders)
+		if res != nil && res.Body != nil {
+			bodies.res = bodyLog.NewCapture(res.Header.Get("Content-Type"))
+		}
+		if bodies.res != nil {
+			res.Body = internal.PeekBody(res.Body, bodies.res)
+		}
+		writeLogEndMessage(log, reqDuration, logURL, res, cfg.obfuscateHeaders, redactor, bodies)
 		return res, err
 	})
 }
@@ -3,10 +3,13 @@ package client
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
@@ -15,6 +18,7 @@ import (
 	"github.com/gocombo/diag/http/internal"
 	"github.com/gocombo/diag/http/internal/testing/httptst"
 	"github.com/gocombo/diag/http/internal/testing/testrand"
+	"github.com/gocombo/diag/http/redact"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -158,6 +162,44 @@ func TestTransport(t *testing.T) {
 			assert.Contains(t, gotEndHeaders[v], "*obfuscated, length=")
 		}
 	})
+	t.Run("should obfuscate query params", func(t *testing.T) {
+		var output bytes.Buffer
+		outputWriter := bufio.NewWriter(&output)
+
+		rootCtx := diag.RootContext(
+			diag.NewRootContextParams().WithOutput(outputWriter),
+		)
+		token := fake.Lorem().Word()
+		custom := fake.Lorem().Word()
+		page := fake.Lorem().Word()
+		req := httptest.NewRequest(
+			"GET",
+			"https://example.com/items?api_key="+token+"&X-Custom="+custom+"&page="+page,
+			http.NoBody,
+		).WithContext(rootCtx)
+
+		transport := NewTransport(roundTripperFn(func(r *http.Request) (*http.Response, error) {
+			assert.Equal(t, token, r.URL.Query().Get("api_key"))
+			return &http.Response{StatusCode: 200, Body: http.NoBody, Request: r}, nil
+		}), WithObfuscateQueryParams("x-custom"))
+		res, err := transport.RoundTrip(req)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer res.Body.Close()
+
+		logLines, ok := unmarshalLogLines(t, outputWriter, &output)
+		if !ok || !assert.Len(t, logLines, 2) {
+			return
+		}
+		wantURL := fmt.Sprintf(
+			"https://example.com/items?api_key=*obfuscated, length=%d*&X-Custom=*obfuscated, length=%d*&page=%s",
+			len(token), len(custom), page,
+		)
+		assert.Equal(t, "START SENDING REQ: GET "+wantURL, logLines[0]["msg"])
+		assert.Equal(t, wantURL, logLines[0]["data"].(map[string]interface{})["url"])
+		assert.Equal(t, "COMPLETE SENDING REQ: 200 - "+wantURL, logLines[1]["msg"])
+	})
 	t.Run("should log non success responses with warn", func(t *testing.T) {
 		var output bytes.Buffer
 		outputWriter := bufio.NewWriter(&output)
@@ -237,4 +279,185 @@ func TestTransport(t *testing.T) {
 		reqEndData := reqEnd["data"].(map[string]interface{})
 		assert.NotZero(t, reqEndData["durationSec"])
 	})
+	t.Run("should propagate diag headers", func(t *testing.T) {
+		wantTrace := diag.NewTraceContext()
+		wantTrace.Sampled = true
+		wantTrace.TraceState = "rojo=00f067aa0ba902b7"
+		wantTenant := fake.Lorem().Word()
+		rootCtx := diag.RootContext(
+			diag.NewRootContextParams().
+				WithOutput(io.Discard).
+				WithDiagEntries(map[string]string{
+					"tenant": wantTenant,
+					"other":  fake.Lorem().Word(),
+				}),
+		)
+		ctx := diag.ForkContext(rootCtx, diag.WithTraceContext(wantTrace))
+		wantCorrelationID := diag.DiagData(ctx).CorrelationID
+
+		sendRequest := func(t *testing.T, req *http.Request, opts ...TransportOption) *http.Request {
+			var gotReq *http.Request
+			transport := NewTransport(roundTripperFn(func(r *http.Request) (*http.Response, error) {
+				gotReq = r
+				return &http.Response{StatusCode: 200, Body: http.NoBody, Request: r}, nil
+			}), opts...)
+			res, err := transport.RoundTrip(req)
+			if assert.NoError(t, err) {
+				res.Body.Close()
+			}
+			return gotReq
+		}
+
+		t.Run("correlation id by default", func(t *testing.T) {
+			req := httptst.RandomHttpReq(fake, ctx)
+			gotReq := sendRequest(t, req)
+			assert.Equal(t, wantCorrelationID, gotReq.Header.Get("x-correlation-id"))
+			assert.Empty(t, gotReq.Header.Get("traceparent"))
+			assert.Empty(t, req.Header.Get("x-correlation-id"), "original request should not be modified")
+		})
+		t.Run("custom correlation id header", func(t *testing.T) {
+			gotReq := sendRequest(t, httptst.RandomHttpReq(fake, ctx), WithCorrelationIDHeader("X-Request-Id"))
+			assert.Equal(t, wantCorrelationID, gotReq.Header.Get("x-request-id"))
+			assert.Empty(t, gotReq.Header.Get("x-correlation-id"))
+
+			gotReq = sendRequest(t, httptst.RandomHttpReq(fake, ctx), WithCorrelationIDHeader(""))
+			assert.Empty(t, gotReq.Header.Get("x-correlation-id"))
+		})
+		t.Run("keep headers set by caller", func(t *testing.T) {
+			wantHeader := fake.UUID().V4()
+			req := httptst.RandomHttpReq(fake, ctx)
+			req.Header.Set("x-correlation-id", wantHeader)
+			gotReq := sendRequest(t, req)
+			assert.Equal(t, wantHeader, gotReq.Header.Get("x-correlation-id"))
+		})
+		t.Run("traceparent with child span", func(t *testing.T) {
+			gotReq := sendRequest(t, httptst.RandomHttpReq(fake, ctx), WithTraceParentPropagation())
+			gotTrace, err := diag.ParseTraceParent(gotReq.Header.Get("traceparent"))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, wantTrace.TraceID, gotTrace.TraceID)
+			assert.NotEqual(t, wantTrace.SpanID, gotTrace.SpanID)
+			assert.True(t, gotTrace.Sampled)
+			assert.Equal(t, wantTrace.TraceState, gotReq.Header.Get("tracestate"))
+		})
+		t.Run("no traceparent without trace", func(t *testing.T) {
+			gotReq := sendRequest(t, httptst.RandomHttpReq(fake, rootCtx), WithTraceParentPropagation())
+			assert.Empty(t, gotReq.Header.Get("traceparent"))
+		})
+		t.Run("entries", func(t *testing.T) {
+			gotReq := sendRequest(t, httptst.RandomHttpReq(fake, ctx), WithPropagatedEntries(map[string]string{
+				"tenant":  "X-Tenant",
+				"missing": "X-Missing",
+			}))
+			assert.Equal(t, wantTenant, gotReq.Header.Get("x-tenant"))
+			assert.NotContains(t, gotReq.Header, "X-Missing")
+			assert.NotContains(t, gotReq.Header, "Other")
+		})
+		t.Run("level override", func(t *testing.T) {
+			levelCtx := diag.ForkContext(ctx, diag.WithLogLevel(diag.LogLevelTraceValue))
+			gotReq := sendRequest(t, httptst.RandomHttpReq(fake, levelCtx))
+			assert.Empty(t, gotReq.Header.Get("x-diag-level"))
+
+			gotReq = sendRequest(t, httptst.RandomHttpReq(fake, ctx), WithLevelOverridePropagation("x-diag-level"))
+			assert.Empty(t, gotReq.Header.Get("x-diag-level"))
+
+			gotReq = sendRequest(t, httptst.RandomHttpReq(fake, levelCtx), WithLevelOverridePropagation("x-diag-level"))
+			assert.Equal(t, "trace", gotReq.Header.Get("x-diag-level"))
+			assert.Empty(t, gotReq.Header.Get("x-diag-secret"))
+		})
+		t.Run("level override with secret", func(t *testing.T) {
+			secret := fake.Internet().Password()
+			output := &bytes.Buffer{}
+			levelCtx := diag.ForkContext(
+				diag.RootContext(diag.NewRootContextParams().WithOutput(output)),
+				diag.WithLogLevel(diag.LogLevelTraceValue),
+			)
+			opts := []TransportOption{
+				WithLevelOverridePropagation("x-diag-level"),
+				WithLevelOverrideSecret("X-Diag-Secret", secret, "Internal.example.com"),
+			}
+			newReq := func(ctx context.Context, host string) *http.Request {
+				req := httptst.RandomHttpReq(fake, ctx)
+				req.URL.Host = host
+				return req
+			}
+			gotReq := sendRequest(t, newReq(levelCtx, "internal.example.com:8443"), opts...)
+			assert.Equal(t, "trace", gotReq.Header.Get("x-diag-level"))
+			assert.Equal(t, secret, gotReq.Header.Get("x-diag-secret"))
+			assert.NotContains(t, output.String(), secret)
+
+			gotReq = sendRequest(t, newReq(ctx, "internal.example.com"), opts...)
+			assert.Empty(t, gotReq.Header.Get("x-diag-secret"))
+
+			gotReq = sendRequest(t, newReq(levelCtx, "api.example.com"), opts...)
+			assert.Equal(t, "trace", gotReq.Header.Get("x-diag-level"))
+			assert.Empty(t, gotReq.Header.Get("x-diag-secret"))
+		})
+		t.Run("opt out per host", func(t *testing.T) {
+			req := httptst.RandomHttpReq(fake, ctx)
+			req.URL.Host = "api.example.com:8443"
+			gotReq := sendRequest(t, req,
+				WithTraceParentPropagation(),
+				WithoutPropagationForHosts("API.example.com"),
+			)
+			assert.Same(t, req, gotReq)
+			assert.Empty(t, gotReq.Header.Get("x-correlation-id"))
+			assert.Empty(t, gotReq.Header.Get("traceparent"))
+		})
+	})
+	t.Run("should log bodies", func(t *testing.T) {
+		var output bytes.Buffer
+		outputWriter := bufio.NewWriter(&output)
+		rootCtx := diag.RootContext(diag.NewRootContextParams().WithOutput(outputWriter))
+
+		reqBody := `{"user":"` + fake.Person().Name() + `","password":"` + fake.Internet().Password() + `"}`
+		resBody := `{"items":[{"id":1,"secret":"s1"},{"id":2,"secret":"s2"}]}`
+		req := httptst.RandomHttpReq(fake, rootCtx)
+		req.Body = io.NopCloser(strings.NewReader(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+
+		var gotReqBody []byte
+		transport := NewTransport(roundTripperFn(func(r *http.Request) (*http.Response, error) {
+			var err error
+			if gotReqBody, err = io.ReadAll(r.Body); err != nil {
+				return nil, err
+			}
+			return &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       io.NopCloser(strings.NewReader(resBody)),
+				Request:    r,
+			}, nil
+		}),
+			WithBodyLogging(1024),
+			WithRedactedBodyFields("password", "items.secret"),
+			WithRedactor(redact.New(redact.WithRules(redact.Drop(redact.Key("content-type"))))),
+		)
+		res, err := transport.RoundTrip(req)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer res.Body.Close()
+		assert.Equal(t, reqBody, string(gotReqBody))
+		gotResBody, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, resBody, string(gotResBody))
+
+		logLines, ok := unmarshalLogLines(t, outputWriter, &output)
+		if !ok || !assert.Len(t, logLines, 2) {
+			return
+		}
+		assert.NotContains(t, logLines[0]["data"].(map[string]interface{})["headers"], "Content-Type")
+		reqEndData := logLines[1]["data"].(map[string]interface{})
+		assert.Empty(t, reqEndData["headers"])
+		gotLoggedReq, _ := reqEndData["requestBody"].(map[string]interface{})
+		assert.Regexp(t, `^\*obfuscated, length=\d+\*$`, gotLoggedReq["password"])
+		assert.Equal(t, map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{"id": float64(1), "secret": "*obfuscated, length=2*"},
+				map[string]interface{}{"id": float64(2), "secret": "*obfuscated, length=2*"},
+			},
+		}, reqEndData["responseBody"])
+	})
 }
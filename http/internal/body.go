@@ -0,0 +1,178 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+
+	"github.com/gocombo/diag"
+	"github.com/gocombo/diag/http/redact"
+)
+
+// DefaultBodyLogContentTypes are logged if body logging is enabled without content types
+var DefaultBodyLogContentTypes = []string{"application/json"}
+
+// BodyLogConfig defines which bodies are logged and how
+type BodyLogConfig struct {
+	// MaxBytes is the max number of body bytes logged, the rest is truncated
+	MaxBytes int
+
+	// ContentTypes are media types (e.g. application/json) or wildcards (e.g. text/*) to log
+	ContentTypes []string
+
+	// Redactor is applied to JSON and form bodies, nil if bodies are logged as is
+	Redactor *redact.Redactor
+}
+
+// NewBodyLogConfig creates a config with lowercased content types
+func NewBodyLogConfig(maxBytes int, contentTypes []string, redactor *redact.Redactor) *BodyLogConfig {
+	if len(contentTypes) == 0 {
+		contentTypes = DefaultBodyLogContentTypes
+	}
+	cfg := &BodyLogConfig{MaxBytes: maxBytes, Redactor: redactor}
+	for _, contentType := range contentTypes {
+		cfg.ContentTypes = append(cfg.ContentTypes, strings.ToLower(contentType))
+	}
+	return cfg
+}
+
+// WithRedactedPaths returns the redactor with additional rules that mask given
+// dot separated JSON paths. Returns the redactor as is if there are no paths.
+func WithRedactedPaths(redactor *redact.Redactor, paths []string) *redact.Redactor {
+	if len(paths) == 0 {
+		return redactor
+	}
+	rules := make([]redact.Rule, len(paths))
+	for i, path := range paths {
+		rules[i] = redact.Mask(redact.JSONPath(path))
+	}
+	return redactor.With(rules...)
+}
+
+// NewCapture returns a capture for a body with a given content type header,
+// nil if the body should not be logged
+func (cfg *BodyLogConfig) NewCapture(contentTypeHeader string) *BodyCapture {
+	if !cfg.ShouldLog(contentTypeHeader) {
+		return nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentTypeHeader)
+	if err != nil {
+		return nil // should not happen since ShouldLog parsed it
+	}
+	return &BodyCapture{limit: cfg.MaxBytes, mediaType: mediaType}
+}
+
+// ShouldLog returns true if a body with a given content type header should be logged
+func (cfg *BodyLogConfig) ShouldLog(contentTypeHeader string) bool {
+	if cfg == nil || contentTypeHeader == "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(contentTypeHeader)
+	if err != nil {
+		return false
+	}
+	for _, contentType := range cfg.ContentTypes {
+		if contentType == mediaType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(contentType, "*"); ok && strings.HasPrefix(mediaType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// BodyCapture keeps up to limit bytes written to it, the rest is discarded
+type BodyCapture struct {
+	limit     int
+	mediaType string
+	buf       bytes.Buffer
+	truncated bool
+}
+
+// Capture keeps the data up to the limit
+func (c *BodyCapture) Capture(p []byte) {
+	if remaining := c.limit - c.buf.Len(); remaining < len(p) {
+		c.truncated = true
+		if remaining > 0 {
+			c.buf.Write(p[:remaining])
+		}
+		return
+	}
+	c.buf.Write(p)
+}
+
+// Write implements io.Writer, never fails
+func (c *BodyCapture) Write(p []byte) (int, error) {
+	c.Capture(p)
+	return len(p), nil
+}
+
+// AppendLogData adds the captured body to the log data under a given key.
+// JSON bodies are logged as is, other bodies as strings. JSON and form bodies are redacted
+// if redactor is configured, other bodies and bodies that can not be redacted (e.g. truncated)
+// are not logged in this case.
+// If the body was truncated the <key>Truncated field is set.
+func (c *BodyCapture) AppendLogData(cfg *BodyLogConfig, data diag.MsgData, key string) {
+	body := c.buf.Bytes()
+	if c.truncated {
+		data.Bool(key+"Truncated", true)
+	}
+	if cfg.Redactor == nil {
+		if json.Valid(body) {
+			data.RawJSON(key, body)
+		} else {
+			data.Str(key, string(body))
+		}
+		return
+	}
+
+	isJSON := c.mediaType == "application/json" || strings.HasSuffix(c.mediaType, "+json")
+	switch {
+	case c.mediaType == "application/x-www-form-urlencoded":
+		if redacted, err := cfg.Redactor.Form(body); err == nil && !c.truncated {
+			data.Str(key, string(redacted))
+			return
+		}
+	case isJSON || json.Valid(body):
+		if redacted, err := cfg.Redactor.JSON(body); err == nil {
+			data.RawJSON(key, redacted)
+			return
+		}
+	}
+	data.Str(key, fmt.Sprint("*redacted, length=", len(body), "*"))
+}
+
+// teeReadCloser captures all the data read from the wrapped reader
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// TeeBody returns a body that writes everything read from it to the capture
+func TeeBody(body io.ReadCloser, capture *BodyCapture) io.ReadCloser {
+	return teeReadCloser{Reader: io.TeeReader(body, capture), Closer: body}
+}
+
+// PeekBody reads up to the capture limit from the body (plus a byte to detect truncation)
+// and returns a body that will return all the original data
+func PeekBody(body io.ReadCloser, capture *BodyCapture) io.ReadCloser {
+	peeked, err := io.ReadAll(io.LimitReader(body, int64(capture.limit)+1))
+	capture.Capture(peeked)
+	rest := io.Reader(body)
+	if err != nil {
+		rest = errReader{err: err}
+	}
+	return teeReadCloser{Reader: io.MultiReader(bytes.NewReader(peeked), rest), Closer: body}
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
@@ -0,0 +1,180 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/gocombo/diag"
+	"github.com/gocombo/diag/http/redact"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBodyLogConfig_ShouldLog(t *testing.T) {
+	var nilCfg *BodyLogConfig
+	assert.False(t, nilCfg.ShouldLog("application/json"))
+
+	assert.Nil(t, nilCfg.NewCapture("application/json"))
+
+	cfg := NewBodyLogConfig(fake.IntBetween(1, 100), nil, nil)
+	assert.Equal(t, DefaultBodyLogContentTypes, cfg.ContentTypes)
+
+	cfg = NewBodyLogConfig(fake.IntBetween(1, 100), []string{"Application/JSON", "text/*"}, nil)
+	tests := []struct {
+		contentType string
+		want        bool
+	}{
+		{contentType: "application/json", want: true},
+		{contentType: "application/json; charset=utf-8", want: true},
+		{contentType: "APPLICATION/JSON", want: true},
+		{contentType: "text/plain", want: true},
+		{contentType: "text/html; charset=utf-8", want: true},
+		{contentType: "application/xml", want: false},
+		{contentType: "", want: false},
+		{contentType: ";;", want: false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, cfg.ShouldLog(tt.contentType), tt.contentType)
+		assert.Equal(t, tt.want, cfg.NewCapture(tt.contentType) != nil, tt.contentType)
+	}
+}
+
+func TestBodyCapture(t *testing.T) {
+	t.Run("keeps up to the limit", func(t *testing.T) {
+		capture := NewBodyLogConfig(10, nil, nil).NewCapture("application/json")
+		n, err := capture.Write([]byte("12345"))
+		assert.NoError(t, err)
+		assert.Equal(t, 5, n)
+		assert.False(t, capture.truncated)
+		n, err = capture.Write([]byte("6789012"))
+		assert.NoError(t, err)
+		assert.Equal(t, 7, n)
+		assert.True(t, capture.truncated)
+		capture.Capture([]byte("345"))
+		assert.Equal(t, "1234567890", capture.buf.String())
+	})
+	t.Run("TeeBody", func(t *testing.T) {
+		body := fake.Lorem().Sentence(10)
+		capture := NewBodyLogConfig(len(body), nil, nil).NewCapture("application/json")
+		tee := TeeBody(io.NopCloser(strings.NewReader(body)), capture)
+		got, err := io.ReadAll(tee)
+		assert.NoError(t, err)
+		assert.NoError(t, tee.Close())
+		assert.Equal(t, body, string(got))
+		assert.Equal(t, body, capture.buf.String())
+		assert.False(t, capture.truncated)
+	})
+	t.Run("PeekBody", func(t *testing.T) {
+		body := fake.Lorem().Sentence(10)
+		capture := NewBodyLogConfig(5, nil, nil).NewCapture("application/json")
+		peeked := PeekBody(io.NopCloser(strings.NewReader(body)), capture)
+		assert.Equal(t, body[:5], capture.buf.String())
+		assert.True(t, capture.truncated)
+		got, err := io.ReadAll(peeked)
+		assert.NoError(t, err)
+		assert.NoError(t, peeked.Close())
+		assert.Equal(t, body, string(got))
+	})
+	t.Run("PeekBody read error", func(t *testing.T) {
+		wantErr := errors.New(fake.Lorem().Word())
+		capture := NewBodyLogConfig(5, nil, nil).NewCapture("application/json")
+		peeked := PeekBody(io.NopCloser(io.MultiReader(strings.NewReader("123"), errReader{err: wantErr})), capture)
+		assert.Equal(t, "123", capture.buf.String())
+		got, err := io.ReadAll(peeked)
+		assert.ErrorIs(t, err, wantErr)
+		assert.Equal(t, "123", string(got))
+	})
+}
+
+func appendBodyLogData(cfg *BodyLogConfig, contentType, body string) map[string]interface{} {
+	capture := cfg.NewCapture(contentType)
+	capture.Capture([]byte(body))
+
+	output := &bytes.Buffer{}
+	log := diag.Log(diag.RootContext(diag.NewRootContextParams().WithOutput(output)))
+	data := log.NewData()
+	capture.AppendLogData(cfg, data, "body")
+	log.Info().WithData(data).Msg(fake.Lorem().Word())
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(output.Bytes(), &entry); err != nil {
+		panic(err)
+	}
+	result, _ := entry["data"].(map[string]interface{})
+	return result
+}
+
+func TestBodyCapture_AppendLogData(t *testing.T) {
+	redactor := redact.New(redact.WithRules(redact.Mask(redact.Key("password"))))
+	tests := []struct {
+		name          string
+		maxBytes      int
+		redactor      *redact.Redactor
+		contentType   string
+		body          string
+		want          interface{}
+		wantTruncated bool
+	}{
+		{
+			name: "json", maxBytes: 100, contentType: "application/json",
+			body: `{"key":"value"}`, want: map[string]interface{}{"key": "value"},
+		},
+		{
+			name: "text", maxBytes: 100, contentType: "text/plain",
+			body: `some text`, want: "some text",
+		},
+		{
+			name: "truncated", maxBytes: 5, contentType: "application/json",
+			body: `{"key":"value"}`, want: `{"key`, wantTruncated: true,
+		},
+		{
+			name: "redacted json", maxBytes: 100, redactor: redactor, contentType: "application/vnd.api+json",
+			body: `{"user":"u1","password":"secret"}`,
+			want: map[string]interface{}{"user": "u1", "password": "*obfuscated, length=6*"},
+		},
+		{
+			name: "redacted form", maxBytes: 100, redactor: redactor, contentType: "application/x-www-form-urlencoded",
+			body: `user=u1&password=secret`, want: "password=%2Aobfuscated%2C+length%3D6%2A&user=u1",
+		},
+		{
+			name: "truncated json not redactable", maxBytes: 10, redactor: redactor, contentType: "application/json",
+			body: `{"password":"secret"}`, want: "*redacted, length=10*", wantTruncated: true,
+		},
+		{
+			name: "truncated form not redactable", maxBytes: 10, redactor: redactor, contentType: "application/x-www-form-urlencoded",
+			body: `password=secret`, want: "*redacted, length=10*", wantTruncated: true,
+		},
+		{
+			name: "text not redactable", maxBytes: 100, redactor: redactor, contentType: "text/plain",
+			body: `password=secret`, want: "*redacted, length=15*",
+		},
+		{
+			name: "json with trailing data not redactable", maxBytes: 100, redactor: redactor, contentType: "application/json",
+			body: `{"user":"u1"} password=secret`, want: "*redacted, length=29*",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewBodyLogConfig(tt.maxBytes, []string{tt.contentType}, tt.redactor)
+			got := appendBodyLogData(cfg, tt.contentType, tt.body)
+			assert.Equal(t, tt.want, got["body"])
+			if tt.wantTruncated {
+				assert.Equal(t, true, got["bodyTruncated"])
+			} else {
+				assert.NotContains(t, got, "bodyTruncated")
+			}
+		})
+	}
+}
+
+func TestWithRedactedPaths(t *testing.T) {
+	redactor := redact.New(redact.WithRules(redact.Drop(redact.Key("a"))))
+	assert.Same(t, redactor, WithRedactedPaths(redactor, nil))
+
+	got, err := WithRedactedPaths(redactor, []string{"b.c"}).JSON([]byte(`{"a":1,"b":{"c":"12"}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"b":{"c":"*obfuscated, length=2*"}}`, string(got))
+}
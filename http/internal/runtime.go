@@ -0,0 +1,13 @@
+package internal
+
+import (
+	"math"
+	"runtime"
+)
+
+// RuntimeMemMb returns currently allocated heap memory in megabytes
+func RuntimeMemMb() float64 {
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	return math.Round(float64(memStats.Alloc)/1024.0/1024.0*1000) / 1000
+}
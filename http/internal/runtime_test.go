@@ -0,0 +1,11 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntimeMemMb(t *testing.T) {
+	assert.Greater(t, RuntimeMemMb(), 0.0)
+}
@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/gocombo/diag/http/redact"
+	"golang.org/x/exp/slices"
+)
+
+var DefaultObfuscatedQueryParams = []string{
+	"access_token",
+	"refresh_token",
+	"id_token",
+	"token",
+	"api_key",
+	"apikey",
+	"client_secret",
+	"password",
+	"secret",
+	"signature",
+	"sig",
+}
+
+// ObfuscateURL returns a copy of the url with query values of obfuscatedKeys obfuscated
+// and the redactor applied to the rest of the query params. Query params order is preserved.
+// User password, if any, is obfuscated as well.
+func ObfuscateURL(u *url.URL, obfuscatedKeys []string, redactor *redact.Redactor) *url.URL {
+	result := *u
+	if password, ok := u.User.Password(); ok {
+		result.User = url.UserPassword(u.User.Username(), fmt.Sprint("*obfuscated, length=", len(password), "*"))
+	}
+	if u.RawQuery == "" {
+		return &result
+	}
+
+	pairs := strings.Split(u.RawQuery, "&")
+	obfuscatedPairs := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		rawKey, rawValue, _ := strings.Cut(pair, "=")
+		key, keyErr := url.QueryUnescape(rawKey)
+		value, valueErr := url.QueryUnescape(rawValue)
+		if keyErr != nil || valueErr != nil {
+			// Can not tell if the pair is sensitive so it is not logged
+			obfuscatedPairs = append(obfuscatedPairs, fmt.Sprint("*obfuscated, length=", len(pair), "*"))
+			continue
+		}
+		if slices.Contains(obfuscatedKeys, strings.ToLower(key)) {
+			obfuscatedPairs = append(obfuscatedPairs, rawKey+"="+fmt.Sprint("*obfuscated, length=", len(value), "*"))
+			continue
+		}
+		redacted, keep := redactor.Value(key, value)
+		switch {
+		case !keep:
+		case redacted == value:
+			obfuscatedPairs = append(obfuscatedPairs, pair)
+		default:
+			obfuscatedPairs = append(obfuscatedPairs, rawKey+"="+redacted)
+		}
+	}
+	result.RawQuery = strings.Join(obfuscatedPairs, "&")
+	return &result
+}
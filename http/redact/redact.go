@@ -0,0 +1,293 @@
+// Package redact provides a redaction engine for values logged by the http middleware and transport.
+// Rules select values by key name, key glob or regexp pattern or JSON path and either mask,
+// hash (keyed HMAC so equal values can be correlated) or drop them. The same rules apply
+// to headers, query params, form bodies and nested JSON.
+package redact
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Action defines what happens with the matched value
+type Action int
+
+const (
+	// ActionMask replaces the value with a placeholder that includes the value length
+	ActionMask Action = iota
+
+	// ActionHash replaces the value with a keyed HMAC of the value
+	ActionHash
+
+	// ActionDrop removes the value (and its key)
+	ActionDrop
+)
+
+// Matcher selects values to redact. Path holds keys of the enclosing
+// JSON objects and the key of the value itself, array indexes are not included.
+// Flat values (headers, query params, form fields) have a single element path.
+type Matcher func(path []string) bool
+
+// Key matches values by key name at any depth. Names are case insensitive
+func Key(names ...string) Matcher {
+	lowercaseNames := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		lowercaseNames[strings.ToLower(name)] = struct{}{}
+	}
+	return func(p []string) bool {
+		_, ok := lowercaseNames[strings.ToLower(p[len(p)-1])]
+		return ok
+	}
+}
+
+// KeyGlob matches values by key name at any depth using path.Match pattern (e.g. *token*).
+// Matching is case insensitive. Panics if the pattern is malformed.
+func KeyGlob(pattern string) Matcher {
+	pattern = strings.ToLower(pattern)
+	if _, err := path.Match(pattern, ""); err != nil {
+		panic(fmt.Errorf("invalid key glob %q: %w", pattern, err))
+	}
+	return func(p []string) bool {
+		matched, err := path.Match(pattern, strings.ToLower(p[len(p)-1]))
+		return err == nil && matched
+	}
+}
+
+// KeyRegexp matches values by key name at any depth
+func KeyRegexp(re *regexp.Regexp) Matcher {
+	return func(p []string) bool {
+		return re.MatchString(p[len(p)-1])
+	}
+}
+
+// JSONPath matches values by a dot separated path of keys from the root (e.g. user.password).
+// A * segment matches any key. Arrays are traversed transparently so items.secret
+// matches the secret key of each object of the items array.
+func JSONPath(jsonPath string) Matcher {
+	segments := strings.Split(jsonPath, ".")
+	return func(p []string) bool {
+		if len(p) != len(segments) {
+			return false
+		}
+		for i, segment := range segments {
+			if segment != "*" && segment != p[i] {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Rule is a matcher with an action to apply to the matched values
+type Rule struct {
+	Matcher Matcher
+	Action  Action
+}
+
+// Mask creates a rule that masks matched values
+func Mask(matcher Matcher) Rule {
+	return Rule{Matcher: matcher, Action: ActionMask}
+}
+
+// Hash creates a rule that replaces matched values with a keyed HMAC. Requires WithHashKey
+func Hash(matcher Matcher) Rule {
+	return Rule{Matcher: matcher, Action: ActionHash}
+}
+
+// Drop creates a rule that removes matched values
+func Drop(matcher Matcher) Rule {
+	return Rule{Matcher: matcher, Action: ActionDrop}
+}
+
+// Redactor applies redaction rules. Nil Redactor returns all the values as is.
+type Redactor struct {
+	rules   []Rule
+	hashKey []byte
+}
+
+type RedactorOpt func(r *Redactor)
+
+// WithRules adds redaction rules, first matching rule is applied
+func WithRules(rules ...Rule) RedactorOpt {
+	return func(r *Redactor) {
+		r.rules = append(r.rules, rules...)
+	}
+}
+
+// WithHashKey sets the HMAC key used by hash rules. The same key
+// produces the same hashes so values can be correlated across logs.
+func WithHashKey(key []byte) RedactorOpt {
+	return func(r *Redactor) {
+		r.hashKey = key
+	}
+}
+
+// New creates a Redactor. Panics if there are hash rules but no hash key.
+func New(opts ...RedactorOpt) *Redactor {
+	r := &Redactor{}
+	for _, opt := range opts {
+		opt(r)
+	}
+	for _, rule := range r.rules {
+		if rule.Action == ActionHash && len(r.hashKey) == 0 {
+			panic(fmt.Errorf("hash key is required for hash rules"))
+		}
+	}
+	return r
+}
+
+// With returns a copy of the redactor with additional rules, the additional rules take precedence.
+// Can be called on nil Redactor.
+func (r *Redactor) With(rules ...Rule) *Redactor {
+	result := &Redactor{}
+	result.rules = append(result.rules, rules...)
+	if r != nil {
+		result.rules = append(result.rules, r.rules...)
+		result.hashKey = r.hashKey
+	}
+	return New(WithRules(result.rules...), WithHashKey(result.hashKey))
+}
+
+func (r *Redactor) match(p []string) (Action, bool) {
+	if r == nil {
+		return 0, false
+	}
+	for _, rule := range r.rules {
+		if rule.Matcher(p) {
+			return rule.Action, true
+		}
+	}
+	return 0, false
+}
+
+func (r *Redactor) hash(value string) string {
+	mac := hmac.New(sha256.New, r.hashKey)
+	mac.Write([]byte(value))
+	return "*hmac:" + hex.EncodeToString(mac.Sum(nil)) + "*"
+}
+
+func mask(value string) string {
+	return fmt.Sprint("*obfuscated, length=", len(value), "*")
+}
+
+// Value redacts a single value, returns false if the value should be dropped
+func (r *Redactor) Value(key, value string) (string, bool) {
+	action, ok := r.match([]string{key})
+	if !ok {
+		return value, true
+	}
+	return r.apply(action, value)
+}
+
+func (r *Redactor) apply(action Action, value string) (string, bool) {
+	switch action {
+	case ActionHash:
+		return r.hash(value), true
+	case ActionDrop:
+		return "", false
+	default:
+		return mask(value), true
+	}
+}
+
+// Map returns a copy of flat values (e.g. flattened headers or query params) with values redacted
+func (r *Redactor) Map(values map[string]string) map[string]string {
+	result := make(map[string]string, len(values))
+	for key, value := range values {
+		if redacted, ok := r.Value(key, value); ok {
+			result[key] = redacted
+		}
+	}
+	return result
+}
+
+// Form redacts url encoded form body
+func (r *Redactor) Form(body []byte) ([]byte, error) {
+	values, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, err
+	}
+	for key, keyValues := range values {
+		action, ok := r.match([]string{key})
+		if !ok {
+			continue
+		}
+		if action == ActionDrop {
+			delete(values, key)
+			continue
+		}
+		for i, value := range keyValues {
+			keyValues[i], _ = r.apply(action, value)
+		}
+	}
+	return []byte(values.Encode()), nil
+}
+
+// JSON redacts values of a JSON document. Non string values are
+// masked or hashed using their JSON representation.
+// Returns an error if the body has data after the document.
+func (r *Redactor) JSON(body []byte) ([]byte, error) {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("unexpected data after JSON document")
+	}
+	if r == nil || len(r.rules) == 0 {
+		return json.Marshal(value)
+	}
+	return json.Marshal(r.redactJSON(value, nil))
+}
+
+func (r *Redactor) redactJSON(value interface{}, p []string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			childPath := append(p[:len(p):len(p)], key)
+			action, ok := r.match(childPath)
+			if !ok {
+				v[key] = r.redactJSON(child, childPath)
+				continue
+			}
+			redacted, keep := r.apply(action, jsonString(child))
+			if keep {
+				v[key] = redacted
+			} else {
+				delete(v, key)
+			}
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = r.redactJSON(item, p)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// jsonString returns the string value or JSON representation of other values
+func jsonString(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(b)
+}
@@ -0,0 +1,149 @@
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"testing"
+
+	"github.com/jaswdr/faker"
+	"github.com/stretchr/testify/assert"
+)
+
+var fake = faker.New()
+
+func TestMatchers(t *testing.T) {
+	tests := []struct {
+		name    string
+		matcher Matcher
+		path    []string
+		want    bool
+	}{
+		{name: "key", matcher: Key("Password", "token"), path: []string{"user", "PASSWORD"}, want: true},
+		{name: "key no match", matcher: Key("password"), path: []string{"password", "user"}, want: false},
+		{name: "glob", matcher: KeyGlob("*Token*"), path: []string{"accessToken"}, want: true},
+		{name: "glob no match", matcher: KeyGlob("*token"), path: []string{"tokens"}, want: false},
+		{name: "regexp", matcher: KeyRegexp(regexp.MustCompile(`^x-api-.*key$`)), path: []string{"x-api-secret-key"}, want: true},
+		{name: "regexp no match", matcher: KeyRegexp(regexp.MustCompile(`^key$`)), path: []string{"keys"}, want: false},
+		{name: "json path", matcher: JSONPath("user.password"), path: []string{"user", "password"}, want: true},
+		{name: "json path wildcard", matcher: JSONPath("*.password"), path: []string{"admin", "password"}, want: true},
+		{name: "json path other depth", matcher: JSONPath("user.password"), path: []string{"password"}, want: false},
+		{name: "json path other key", matcher: JSONPath("user.password"), path: []string{"user", "name"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.matcher(tt.path))
+		})
+	}
+	t.Run("invalid glob", func(t *testing.T) {
+		assert.Panics(t, func() {
+			KeyGlob("[")
+		})
+	})
+}
+
+func TestRedactor(t *testing.T) {
+	hashKey := []byte(fake.Internet().Password())
+	hash := func(value string) string {
+		mac := hmac.New(sha256.New, hashKey)
+		mac.Write([]byte(value))
+		return "*hmac:" + hex.EncodeToString(mac.Sum(nil)) + "*"
+	}
+	redactor := New(
+		WithHashKey(hashKey),
+		WithRules(
+			Mask(Key("password")),
+			Hash(KeyGlob("*email*")),
+			Drop(JSONPath("internal")),
+		),
+	)
+
+	t.Run("Value", func(t *testing.T) {
+		email := fake.Internet().Email()
+		got, ok := redactor.Value("userEmail", email)
+		assert.True(t, ok)
+		assert.Equal(t, hash(email), got)
+
+		got2, _ := redactor.Value("email", email)
+		assert.Equal(t, got, got2, "hashes should be joinable")
+
+		_, ok = redactor.Value("internal", fake.Lorem().Word())
+		assert.False(t, ok)
+
+		value := fake.Lorem().Word()
+		got, ok = redactor.Value("other", value)
+		assert.True(t, ok)
+		assert.Equal(t, value, got)
+	})
+	t.Run("nil redactor", func(t *testing.T) {
+		var nilRedactor *Redactor
+		value := fake.Lorem().Word()
+		got, ok := nilRedactor.Value("password", value)
+		assert.True(t, ok)
+		assert.Equal(t, value, got)
+		assert.Equal(t, map[string]string{"password": value}, nilRedactor.Map(map[string]string{"password": value}))
+
+		gotJSON, err := nilRedactor.JSON([]byte(`{"password": "x"}`))
+		assert.NoError(t, err)
+		assert.Equal(t, `{"password":"x"}`, string(gotJSON))
+	})
+	t.Run("Map", func(t *testing.T) {
+		got := redactor.Map(map[string]string{
+			"Password": "secret",
+			"internal": "value",
+			"other":    "value",
+		})
+		assert.Equal(t, map[string]string{
+			"Password": "*obfuscated, length=6*",
+			"other":    "value",
+		}, got)
+	})
+	t.Run("Form", func(t *testing.T) {
+		got, err := redactor.Form([]byte("password=secret&password=s2&internal=1&other=value"))
+		assert.NoError(t, err)
+		assert.Equal(t, "other=value&password=%2Aobfuscated%2C+length%3D6%2A&password=%2Aobfuscated%2C+length%3D2%2A", string(got))
+
+		_, err = redactor.Form([]byte("%zz"))
+		assert.Error(t, err)
+	})
+	t.Run("JSON", func(t *testing.T) {
+		got, err := redactor.JSON([]byte(`{
+			"user": {"password": 12345, "email": "a@b.c", "name": "n"},
+			"items": [{"password": {"nested": true}}, "password"],
+			"internal": {"any": "value"},
+			"nested": {"internal": "kept"},
+			"big": 12345678901234567890
+		}`))
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{
+			"user": {"password": "*obfuscated, length=5*", "email": "`+hash("a@b.c")+`", "name": "n"},
+			"items": [{"password": "*obfuscated, length=15*"}, "password"],
+			"nested": {"internal": "kept"},
+			"big": 12345678901234567890
+		}`, string(got))
+
+		_, err = redactor.JSON([]byte(`{"a":1} {"password":"secret"}`))
+		assert.Error(t, err)
+		_, err = redactor.JSON([]byte(`{"a":1} trailing`))
+		assert.Error(t, err)
+		_, err = redactor.JSON([]byte("{\"a\":1}\n"))
+		assert.NoError(t, err)
+		_, err = redactor.JSON([]byte(`{"a":`))
+		assert.Error(t, err)
+	})
+	t.Run("With", func(t *testing.T) {
+		extended := redactor.With(Drop(Key("password")))
+		got := extended.Map(map[string]string{"password": "secret", "email": "a@b.c"})
+		assert.Equal(t, map[string]string{"email": hash("a@b.c")}, got)
+
+		var nilRedactor *Redactor
+		got = nilRedactor.With(Mask(Key("a"))).Map(map[string]string{"a": "1"})
+		assert.Equal(t, map[string]string{"a": "*obfuscated, length=1*"}, got)
+	})
+	t.Run("hash key required", func(t *testing.T) {
+		assert.PanicsWithError(t, "hash key is required for hash rules", func() {
+			New(WithRules(Hash(Key("email"))))
+		})
+	})
+}
@@ -1,34 +1,26 @@
 package server
 
 import (
-	"math"
+	"context"
+	"net"
 	"net/http"
-	"runtime"
 	"strings"
 	"time"
 
 	"github.com/gocombo/diag"
 	"github.com/gocombo/diag/http/internal"
+	"github.com/gocombo/diag/http/redact"
 )
 
-type responseWrapper struct {
-	http.ResponseWriter
-	statusCode int
-}
-
-func (w *responseWrapper) WriteHeader(code int) {
-	w.statusCode = code
-	w.ResponseWriter.WriteHeader(code)
-}
-
-func runtimeMemMb() float64 {
-	var memStats runtime.MemStats
-	runtime.ReadMemStats(&memStats)
-	return math.Round(float64(memStats.Alloc)/1024.0/1024.0*1000) / 1000
-}
-
 type httpLogMiddlewareCfg struct {
-	obfuscatedHeaders []string
+	obfuscatedHeaders     []string
+	obfuscatedQueryParams []string
+	routeInMessage        bool
+
+	bodyMaxBytes      int
+	bodyContentTypes  []string
+	redactor          *redact.Redactor
+	redactedBodyPaths []string
 }
 
 type HttpLogMiddlewareOpt func(*httpLogMiddlewareCfg)
@@ -45,14 +37,66 @@ func WithHttpLogObfuscatedHeaders(headers ...string) HttpLogMiddlewareOpt {
 	}
 }
 
+// WithHttpLogObfuscatedQueryParams will obfuscate additional query params in the log output
+// Default obfuscated query params are defined in internal.DefaultObfuscatedQueryParams
+func WithHttpLogObfuscatedQueryParams(params ...string) HttpLogMiddlewareOpt {
+	return func(cfg *httpLogMiddlewareCfg) {
+		for _, param := range params {
+			cfg.obfuscatedQueryParams = append(cfg.obfuscatedQueryParams, strings.ToLower(param))
+		}
+	}
+}
+
+// WithHttpLogRouteInMessage will use the matched route (e.g. /users/{id}) instead of
+// the raw path in BEGIN REQ and END REQ messages if the route is known
+func WithHttpLogRouteInMessage() HttpLogMiddlewareOpt {
+	return func(cfg *httpLogMiddlewareCfg) {
+		cfg.routeInMessage = true
+	}
+}
+
+// WithHttpLogBodies enables logging of request and response bodies of given content types
+// (e.g. application/json, text/*), application/json is used if no content types provided.
+// Up to maxBytes of each body is logged with the END REQ entry, the request body is logged
+// as it was read by the handler.
+func WithHttpLogBodies(maxBytes int, contentTypes ...string) HttpLogMiddlewareOpt {
+	return func(cfg *httpLogMiddlewareCfg) {
+		cfg.bodyMaxBytes = maxBytes
+		cfg.bodyContentTypes = contentTypes
+	}
+}
+
+// WithHttpLogRedactedBodyFields will mask values of JSON and form bodies at given dot separated
+// key paths (e.g. user.password). Bodies that can not be redacted (e.g. text or truncated) are not logged.
+func WithHttpLogRedactedBodyFields(paths ...string) HttpLogMiddlewareOpt {
+	return func(cfg *httpLogMiddlewareCfg) {
+		cfg.redactedBodyPaths = append(cfg.redactedBodyPaths, paths...)
+	}
+}
+
+// WithHttpLogRedactor applies the redactor to the logged headers, query params and bodies.
+// Obfuscated headers are still obfuscated.
+func WithHttpLogRedactor(redactor *redact.Redactor) HttpLogMiddlewareOpt {
+	return func(cfg *httpLogMiddlewareCfg) {
+		cfg.redactor = redactor
+	}
+}
+
 // WithHTTPLog log web transaction, it should be placed last in the middleware chain, to measure the latency of route handler logic
+// The route matched by http.ServeMux (go1.23+, not in httpmuxgo121 mode) or reported via SetRoute is logged as a route field.
 func NewHttpLogMiddleware(opts ...HttpLogMiddlewareOpt) func(http.Handler) http.Handler {
 	cfg := &httpLogMiddlewareCfg{
-		obfuscatedHeaders: internal.DefaultObfuscatedHeaders,
+		obfuscatedHeaders:     internal.DefaultObfuscatedHeaders,
+		obfuscatedQueryParams: internal.DefaultObfuscatedQueryParams,
 	}
 	for _, opt := range opts {
 		opt(cfg)
 	}
+	redactor := internal.WithRedactedPaths(cfg.redactor, cfg.redactedBodyPaths)
+	var bodyLog *internal.BodyLogConfig
+	if cfg.bodyMaxBytes > 0 {
+		bodyLog = internal.NewBodyLogConfig(cfg.bodyMaxBytes, cfg.bodyContentTypes, redactor)
+	}
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
@@ -60,20 +104,44 @@ func NewHttpLogMiddleware(opts ...HttpLogMiddlewareOpt) func(http.Handler) http.
 
 			path := req.URL.Path
 			method := req.Method
+			logURL := internal.ObfuscateURL(req.URL, cfg.obfuscatedQueryParams, redactor)
+
+			// The route is known at this point if the middleware is attached to a route of the router
+			route := &routeHolder{}
+			req = req.WithContext(context.WithValue(req.Context(), contextKeyRoute, route))
+			msgPath := func(route string) string {
+				if cfg.routeInMessage && route != "" {
+					return route
+				}
+				return path
+			}
+			beginRoute := requestRoute(req, route)
 
 			log.Info().
 				WithDataFn(func(data diag.MsgData) {
 					data.
 						Str("method", method).
-						Str("url", req.URL.RequestURI()).
-						Interface("headers", internal.FlattenAndObfuscate(req.Header, cfg.obfuscatedHeaders)).
-						Interface("query", internal.FlattenAndObfuscate(req.URL.Query(), nil)).
-						Float64("memoryUsageMb", runtimeMemMb())
+						Str("url", logURL.RequestURI()).
+						Interface("headers", redactor.Map(internal.FlattenAndObfuscate(req.Header, cfg.obfuscatedHeaders))).
+						Interface("query", redactor.Map(internal.FlattenAndObfuscate(req.URL.Query(), cfg.obfuscatedQueryParams))).
+						Float64("memoryUsageMb", internal.RuntimeMemMb())
+					if beginRoute != "" {
+						data.Str("route", beginRoute)
+					}
 				}).
-				Msgf("BEGIN REQ: %s %s", method, path)
+				Msgf("BEGIN REQ: %s %s", method, msgPath(beginRoute))
+
+			var reqBody *internal.BodyCapture
+			if req.Body != nil {
+				reqBody = bodyLog.NewCapture(req.Header.Get("Content-Type"))
+			}
+			if reqBody != nil {
+				req.Body = internal.TeeBody(req.Body, reqBody)
+			}
 
-			rw := &responseWrapper{ResponseWriter: w}
 			start := time.Now()
+			rw, wrapped := newResponseWrapper(w)
+			rw.bodyLog = bodyLog
 
 			panics := true
 			defer func() {
@@ -89,20 +157,59 @@ func NewHttpLogMiddleware(opts ...HttpLogMiddlewareOpt) func(http.Handler) http.
 				if status == 0 {
 					status = 200
 				}
-
-				log.Info().
+				endRoute := requestRoute(req, route)
+
+				// The request info is written by cloud adapters that support it, e.g. as httpRequest of GCP entries
+				endEvent := diag.WithHTTPRequest(log.Info(), diag.HTTPRequestInfo{
+					Method:       method,
+					URL:          logURL.RequestURI(),
+					Status:       status,
+					RequestSize:  req.ContentLength,
+					ResponseSize: rw.bytesWritten,
+					UserAgent:    req.UserAgent(),
+					RemoteIP:     remoteIP(req),
+					Referer:      req.Referer(),
+					Protocol:     req.Proto,
+					Latency:      stop.Sub(start),
+				})
+				endEvent.
 					WithDataFn(func(data diag.MsgData) {
 						data.Int("statusCode", status)
-						data.Interface("headers", internal.FlattenAndObfuscate(w.Header(), cfg.obfuscatedHeaders))
+						if endRoute != "" {
+							data.Str("route", endRoute)
+						}
+						data.Interface("headers", redactor.Map(internal.FlattenAndObfuscate(w.Header(), cfg.obfuscatedHeaders)))
 						data.Float64("durationSec", stop.Sub(start).Seconds())
-						data.Float64("memoryUsageMb", runtimeMemMb())
+						data.Float64("memoryUsageMb", internal.RuntimeMemMb())
 						data.Str("userAgent", req.UserAgent())
+						data.Int64("bytesWritten", rw.bytesWritten)
+						if !rw.firstByteAt.IsZero() {
+							data.Float64("firstByteSec", rw.firstByteAt.Sub(start).Seconds())
+						}
+						if rw.hijacked {
+							data.Bool("hijacked", true)
+						}
+						if reqBody != nil {
+							reqBody.AppendLogData(bodyLog, data, "requestBody")
+						}
+						if rw.body != nil {
+							rw.body.AppendLogData(bodyLog, data, "responseBody")
+						}
 					}).
-					Msgf("END REQ: %v - %v", status, path)
+					Msgf("END REQ: %v - %v", status, msgPath(endRoute))
 			}()
 
-			next.ServeHTTP(rw, req)
+			next.ServeHTTP(wrapped, req)
 			panics = false
 		})
 	}
 }
+
+// remoteIP returns the ip of the request RemoteAddr, the RemoteAddr is returned as is if it has no port
+func remoteIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/gocombo/diag"
 	"github.com/gocombo/diag/http/internal"
+	"github.com/gocombo/diag/http/redact"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -90,6 +92,9 @@ func TestHttpLogMiddleware(t *testing.T) {
 		assert.NotZero(t, endData["durationSec"])
 		assert.NotZero(t, endData["memoryUsageMb"])
 		assert.Equal(t, userAgent, endData["userAgent"])
+		assert.Equal(t, float64(0), endData["bytesWritten"])
+		assert.Contains(t, endData, "firstByteSec")
+		assert.NotContains(t, endData, "hijacked")
 		gotEndHeaders := endData["headers"].(map[string]interface{})
 		for k, v := range wantResHeaders {
 			assert.Equal(t, v, gotEndHeaders[k])
@@ -101,6 +106,211 @@ func TestHttpLogMiddleware(t *testing.T) {
 		)
 	})
 
+	t.Run("should log response stats", func(t *testing.T) {
+		var output bytes.Buffer
+		rootCtx := diag.RootContext(diag.NewRootContextParams().WithOutput(&output))
+		req := httptest.NewRequest("GET", "/", http.NoBody).WithContext(rootCtx)
+		wantBody := fake.Lorem().Sentence(10)
+
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, err := w.Write([]byte(wantBody)); err != nil {
+				panic(err)
+			}
+			w.(http.Flusher).Flush()
+			if _, _, err := w.(http.Hijacker).Hijack(); err != nil {
+				panic(err)
+			}
+		})
+		res := newMockWriter(&mockFlusher{}, &mockHijacker{}, nil)
+		BuildHandler(h, NewHttpLogMiddleware()).ServeHTTP(res, req)
+
+		outputLines := strings.Split(strings.Trim(output.String(), "\n"), "\n")
+		if !assert.Len(t, outputLines, 2) {
+			return
+		}
+		var reqEnd map[string]interface{}
+		if err := json.Unmarshal([]byte(outputLines[1]), &reqEnd); !assert.NoError(t, err) {
+			return
+		}
+		endData := reqEnd["data"].(map[string]interface{})
+		assert.Equal(t, float64(len(wantBody)), endData["bytesWritten"])
+		assert.Equal(t, float64(200), endData["statusCode"])
+		assert.GreaterOrEqual(t, endData["firstByteSec"], float64(0))
+		assert.Equal(t, true, endData["hijacked"])
+	})
+
+	t.Run("should add http request to GCP entries", func(t *testing.T) {
+		var output bytes.Buffer
+		rootCtx := diag.RootContext(diag.NewRootContextParams().
+			WithGCPCloudAdapter().
+			WithOutput(&output))
+		req := httptest.NewRequest("POST", "/items?limit=10", strings.NewReader("{}")).WithContext(rootCtx)
+		req.Header.Set("User-Agent", "test-agent")
+		wantBody := fake.Lorem().Sentence(10)
+
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			if _, err := w.Write([]byte(wantBody)); err != nil {
+				panic(err)
+			}
+		})
+		BuildHandler(h, NewHttpLogMiddleware()).ServeHTTP(httptest.NewRecorder(), req)
+
+		outputLines := strings.Split(strings.Trim(output.String(), "\n"), "\n")
+		if !assert.Len(t, outputLines, 2) {
+			return
+		}
+		var reqBegin, reqEnd map[string]interface{}
+		if err := json.Unmarshal([]byte(outputLines[0]), &reqBegin); !assert.NoError(t, err) {
+			return
+		}
+		if err := json.Unmarshal([]byte(outputLines[1]), &reqEnd); !assert.NoError(t, err) {
+			return
+		}
+		assert.NotContains(t, reqBegin, "httpRequest")
+		assert.Equal(t, map[string]interface{}{
+			"requestMethod": "POST",
+			"requestUrl":    "/items?limit=10",
+			"status":        float64(http.StatusCreated),
+			"requestSize":   "2",
+			"responseSize":  fmt.Sprint(len(wantBody)),
+			"userAgent":     "test-agent",
+			"remoteIp":      "192.0.2.1",
+			"protocol":      "HTTP/1.1",
+			"latency":       reqEnd["httpRequest"].(map[string]interface{})["latency"],
+		}, reqEnd["httpRequest"])
+		assert.Regexp(t, `^\d+\.\d{9}s$`, reqEnd["httpRequest"].(map[string]interface{})["latency"])
+	})
+
+	t.Run("should log bodies", func(t *testing.T) {
+		serveBodies := func(reqContentType, resContentType string, opts ...HttpLogMiddlewareOpt) (string, map[string]interface{}) {
+			var output bytes.Buffer
+			rootCtx := diag.RootContext(diag.NewRootContextParams().WithOutput(&output))
+			reqBody := `{"user":"` + fake.Person().Name() + `","password":"` + fake.Internet().Password() + `"}`
+			req := httptest.NewRequest("POST", "/", strings.NewReader(reqBody)).WithContext(rootCtx)
+			req.Header.Set("Content-Type", reqContentType)
+
+			var gotBody []byte
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var err error
+				if gotBody, err = io.ReadAll(r.Body); err != nil {
+					panic(err)
+				}
+				w.Header().Set("Content-Type", resContentType)
+				if _, err = w.Write([]byte(`{"id":1,"token":"t1"}`)); err != nil {
+					panic(err)
+				}
+			})
+			BuildHandler(h, NewHttpLogMiddleware(opts...)).ServeHTTP(httptest.NewRecorder(), req)
+			assert.Equal(t, reqBody, string(gotBody))
+
+			outputLines := strings.Split(strings.Trim(output.String(), "\n"), "\n")
+			var reqEnd map[string]interface{}
+			if err := json.Unmarshal([]byte(outputLines[len(outputLines)-1]), &reqEnd); err != nil {
+				panic(err)
+			}
+			endData, _ := reqEnd["data"].(map[string]interface{})
+			return reqBody, endData
+		}
+
+		t.Run("disabled by default", func(t *testing.T) {
+			_, endData := serveBodies("application/json", "application/json")
+			assert.NotContains(t, endData, "requestBody")
+			assert.NotContains(t, endData, "responseBody")
+		})
+		t.Run("json bodies with redaction", func(t *testing.T) {
+			_, endData := serveBodies("application/json", "application/json; charset=utf-8",
+				WithHttpLogBodies(1024),
+				WithHttpLogRedactedBodyFields("password", "token"),
+			)
+			reqBody, _ := endData["requestBody"].(map[string]interface{})
+			assert.Regexp(t, `^\*obfuscated, length=\d+\*$`, reqBody["password"])
+			assert.NotEmpty(t, reqBody["user"])
+			assert.Equal(t, map[string]interface{}{"id": float64(1), "token": "*obfuscated, length=2*"}, endData["responseBody"])
+		})
+		t.Run("truncated", func(t *testing.T) {
+			reqBody, endData := serveBodies("text/plain", "application/json", WithHttpLogBodies(5, "text/*"))
+			assert.Equal(t, reqBody[:5], endData["requestBody"])
+			assert.Equal(t, true, endData["requestBodyTruncated"])
+			assert.NotContains(t, endData, "responseBody")
+		})
+	})
+
+	t.Run("should apply redactor to headers and query", func(t *testing.T) {
+		var output bytes.Buffer
+		rootCtx := diag.RootContext(diag.NewRootContextParams().WithOutput(&output))
+		req := httptest.NewRequest("GET", "/?partnerApiKey=k1&page=2", http.NoBody).WithContext(rootCtx)
+		req.Header.Set("X-Api-Key", "k1")
+		req.Header.Set("Authorization", "Bearer t1")
+
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Internal", "i1")
+		})
+		BuildHandler(h, NewHttpLogMiddleware(WithHttpLogRedactor(redact.New(redact.WithRules(
+			redact.Mask(redact.KeyGlob("*api*key")),
+			redact.Drop(redact.Key("x-internal")),
+		))))).ServeHTTP(httptest.NewRecorder(), req)
+
+		outputLines := strings.Split(strings.Trim(output.String(), "\n"), "\n")
+		if !assert.Len(t, outputLines, 2) {
+			return
+		}
+		var reqStart, reqEnd map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(outputLines[0]), &reqStart))
+		assert.NoError(t, json.Unmarshal([]byte(outputLines[1]), &reqEnd))
+		startData := reqStart["data"].(map[string]interface{})
+		assert.Equal(t, map[string]interface{}{
+			"X-Api-Key":     "*obfuscated, length=2*",
+			"Authorization": "*obfuscated, length=9*",
+		}, startData["headers"])
+		assert.Equal(t, map[string]interface{}{
+			"partnerApiKey": "*obfuscated, length=2*",
+			"page":          "2",
+		}, startData["query"])
+		assert.Equal(t, "/?partnerApiKey=*obfuscated, length=2*&page=2", startData["url"])
+		endData := reqEnd["data"].(map[string]interface{})
+		assert.Empty(t, endData["headers"])
+	})
+
+	t.Run("should obfuscate sensitive query params", func(t *testing.T) {
+		var output bytes.Buffer
+		rootCtx := diag.RootContext(diag.NewRootContextParams().WithOutput(&output))
+		token := fake.Lorem().Word()
+		custom := fake.Lorem().Word()
+		page := fake.Lorem().Word()
+		req := httptest.NewRequest(
+			"GET",
+			"/items?access_token="+token+"&X-Custom="+custom+"&page="+page,
+			http.NoBody,
+		).WithContext(rootCtx)
+
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, token, r.URL.Query().Get("access_token"))
+		})
+		BuildHandler(h, NewHttpLogMiddleware(WithHttpLogObfuscatedQueryParams("x-custom"))).
+			ServeHTTP(httptest.NewRecorder(), req)
+
+		outputLines := strings.Split(strings.Trim(output.String(), "\n"), "\n")
+		if !assert.Len(t, outputLines, 2) {
+			return
+		}
+		var reqStart map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(outputLines[0]), &reqStart))
+		startData := reqStart["data"].(map[string]interface{})
+		assert.Equal(t, map[string]interface{}{
+			"access_token": fmt.Sprintf("*obfuscated, length=%d*", len(token)),
+			"X-Custom":     fmt.Sprintf("*obfuscated, length=%d*", len(custom)),
+			"page":         page,
+		}, startData["query"])
+		assert.Equal(t,
+			fmt.Sprintf(
+				"/items?access_token=*obfuscated, length=%d*&X-Custom=*obfuscated, length=%d*&page=%s",
+				len(token), len(custom), page,
+			),
+			startData["url"],
+		)
+	})
+
 	t.Run("should obfuscate sensitive headers", func(t *testing.T) {
 		var output bytes.Buffer
 		outputWriter := bufio.NewWriter(&output)
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strings"
+
+	"github.com/gocombo/diag"
+	"github.com/gocombo/diag/http/internal"
+	"github.com/gocombo/diag/http/redact"
+)
+
+// StackFrame is a single frame of the panic stack trace
+type StackFrame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// problemDetails is a RFC 7807 problem details response body
+type problemDetails struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+}
+
+// RecoverResponseFn writes a response to a request that panicked
+type RecoverResponseFn func(w http.ResponseWriter, req *http.Request, recovered interface{})
+
+type httpRecoverMiddlewareCfg struct {
+	responseFn            RecoverResponseFn
+	repanicAbortHandler   bool
+	obfuscatedQueryParams []string
+	redactor              *redact.Redactor
+}
+
+type HttpRecoverMiddlewareOpt func(cfg *httpRecoverMiddlewareCfg)
+
+// WithRecoverResponse sets a function that writes the response if a handler panicked.
+// Default response is a JSON problem details with 500 status.
+func WithRecoverResponse(responseFn RecoverResponseFn) HttpRecoverMiddlewareOpt {
+	return func(cfg *httpRecoverMiddlewareCfg) {
+		cfg.responseFn = responseFn
+	}
+}
+
+// WithRepanicOnAbortHandler makes the middleware panic again with http.ErrAbortHandler
+// so the http server aborts the response. Otherwise the handler is treated as completed.
+func WithRepanicOnAbortHandler() HttpRecoverMiddlewareOpt {
+	return func(cfg *httpRecoverMiddlewareCfg) {
+		cfg.repanicAbortHandler = true
+	}
+}
+
+// WithRecoverObfuscatedQueryParams will obfuscate additional query params of the logged url
+// Default obfuscated query params are defined in internal.DefaultObfuscatedQueryParams
+func WithRecoverObfuscatedQueryParams(params ...string) HttpRecoverMiddlewareOpt {
+	return func(cfg *httpRecoverMiddlewareCfg) {
+		for _, param := range params {
+			cfg.obfuscatedQueryParams = append(cfg.obfuscatedQueryParams, strings.ToLower(param))
+		}
+	}
+}
+
+// WithRecoverRedactor applies the redactor to the query params of the logged url.
+// Obfuscated query params are still obfuscated.
+func WithRecoverRedactor(redactor *redact.Redactor) HttpRecoverMiddlewareOpt {
+	return func(cfg *httpRecoverMiddlewareCfg) {
+		cfg.redactor = redactor
+	}
+}
+
+func writeProblemDetailsResponse(w http.ResponseWriter, req *http.Request, _ interface{}) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(http.StatusInternalServerError)
+	if err := json.NewEncoder(w).Encode(problemDetails{
+		Type:   "about:blank",
+		Title:  http.StatusText(http.StatusInternalServerError),
+		Status: http.StatusInternalServerError,
+	}); err != nil {
+		diag.Log(req.Context()).Warn().WithError(err).Msg("Failed to write panic response")
+	}
+}
+
+// panicStack returns stack frames of the panicking goroutine starting from the
+// function that panicked. Should be called from the deferred function that recovered.
+func panicStack() []StackFrame {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var stack []StackFrame
+	panicFound := false
+	for {
+		frame, more := frames.Next()
+		switch {
+		case !panicFound:
+			panicFound = frame.Function == "runtime.gopanic"
+		case len(stack) == 0 && strings.HasPrefix(frame.Function, "runtime."):
+			// runtime error panics (e.g. nil dereference) have runtime frames after the gopanic
+		default:
+			stack = append(stack, StackFrame{Function: frame.Function, File: frame.File, Line: frame.Line})
+		}
+		if !more {
+			break
+		}
+	}
+	return stack
+}
+
+// NewHttpRecoverMiddleware recovers from panics of the next handlers, logs the panic value
+// and the stack trace at error level and writes 500 response unless headers were already sent.
+// Sensitive query params of the logged url are obfuscated the same way the log middleware does.
+// Should be placed after trace middleware to have the request diag context in the logs.
+func NewHttpRecoverMiddleware(opts ...HttpRecoverMiddlewareOpt) func(http.Handler) http.Handler {
+	cfg := &httpRecoverMiddlewareCfg{
+		responseFn:            writeProblemDetailsResponse,
+		obfuscatedQueryParams: internal.DefaultObfuscatedQueryParams,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			rw, wrapped := newResponseWrapper(w)
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+				log := diag.Log(req.Context())
+
+				err, ok := recovered.(error)
+				if !ok {
+					err = errors.New(fmt.Sprint(recovered))
+				}
+				if errors.Is(err, http.ErrAbortHandler) {
+					log.Warn().Msgf("Request handler aborted: %s %s", req.Method, req.URL.Path)
+					if cfg.repanicAbortHandler {
+						panic(recovered)
+					}
+					return
+				}
+
+				stack := panicStack()
+				log.Error().
+					WithError(err).
+					WithDataFn(func(data diag.MsgData) {
+						data.
+							Str("panic", fmt.Sprint(recovered)).
+							Interface("stack", stack).
+							Str("method", req.Method).
+							Str("url", internal.ObfuscateURL(req.URL, cfg.obfuscatedQueryParams, cfg.redactor).RequestURI())
+					}).
+					Msgf("Recovered from panic: %s %s", req.Method, req.URL.Path)
+
+				if rw.statusCode == 0 && !rw.hijacked {
+					cfg.responseFn(wrapped, req, recovered)
+				}
+			}()
+			next.ServeHTTP(wrapped, req)
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gocombo/diag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHttpRecoverMiddleware(t *testing.T) {
+	serveURL := func(target string, h http.HandlerFunc, opts ...HttpRecoverMiddlewareOpt) (*httptest.ResponseRecorder, []map[string]interface{}) {
+		output := &bytes.Buffer{}
+		rootCtx := diag.RootContext(diag.NewRootContextParams().WithOutput(output))
+		req := httptest.NewRequest("GET", target, http.NoBody).WithContext(rootCtx)
+		res := httptest.NewRecorder()
+		BuildHandler(h, NewHttpRecoverMiddleware(opts...)).ServeHTTP(res, req)
+
+		var entries []map[string]interface{}
+		for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			var entry map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				panic(err)
+			}
+			entries = append(entries, entry)
+		}
+		return res, entries
+	}
+	serve := func(h http.HandlerFunc, opts ...HttpRecoverMiddlewareOpt) (*httptest.ResponseRecorder, []map[string]interface{}) {
+		return serveURL("/"+fake.Internet().Slug(), h, opts...)
+	}
+
+	t.Run("passes through if no panic", func(t *testing.T) {
+		res, entries := serve(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+		})
+		assert.Equal(t, http.StatusAccepted, res.Code)
+		assert.Empty(t, entries)
+	})
+	t.Run("logs panic value and stack", func(t *testing.T) {
+		wantPanic := fake.Lorem().Sentence(3)
+		res, entries := serve(func(w http.ResponseWriter, r *http.Request) {
+			panic(wantPanic)
+		})
+		assert.Equal(t, http.StatusInternalServerError, res.Code)
+		assert.Equal(t, "application/problem+json", res.Header().Get("Content-Type"))
+		var body problemDetails
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+		assert.Equal(t, problemDetails{Type: "about:blank", Title: "Internal Server Error", Status: 500}, body)
+
+		if !assert.Len(t, entries, 1) {
+			return
+		}
+		entry := entries[0]
+		assert.Equal(t, "error", entry["level"])
+		assert.Equal(t, wantPanic, entry["error"])
+		assert.Contains(t, entry["msg"], "Recovered from panic: GET /")
+		data, _ := entry["data"].(map[string]interface{})
+		assert.Equal(t, wantPanic, data["panic"])
+		assert.Equal(t, "GET", data["method"])
+		stack, _ := data["stack"].([]interface{})
+		if assert.NotEmpty(t, stack) {
+			top, _ := stack[0].(map[string]interface{})
+			assert.Contains(t, top["function"], "TestHttpRecoverMiddleware")
+			assert.Contains(t, top["file"], "recover_middleware_test.go")
+			assert.NotZero(t, top["line"])
+		}
+	})
+	t.Run("obfuscates sensitive query params of logged url", func(t *testing.T) {
+		token := fake.Lorem().Word()
+		_, entries := serveURL("/items?access_token="+token+"&session=s1&page=2", func(w http.ResponseWriter, r *http.Request) {
+			panic(fake.Lorem().Sentence(3))
+		}, WithRecoverObfuscatedQueryParams("Session"))
+		if !assert.Len(t, entries, 1) {
+			return
+		}
+		data, _ := entries[0]["data"].(map[string]interface{})
+		assert.Equal(t,
+			fmt.Sprintf("/items?access_token=*obfuscated, length=%d*&session=*obfuscated, length=2*&page=2", len(token)),
+			data["url"],
+		)
+		assert.NotContains(t, entries[0]["msg"], token)
+	})
+	t.Run("logs runtime error panics", func(t *testing.T) {
+		res, entries := serve(func(w http.ResponseWriter, r *http.Request) {
+			var values map[string]int
+			values["key"]++
+		})
+		assert.Equal(t, http.StatusInternalServerError, res.Code)
+		if assert.Len(t, entries, 1) {
+			assert.Contains(t, entries[0]["error"], "assignment to entry in nil map")
+			data, _ := entries[0]["data"].(map[string]interface{})
+			stack, _ := data["stack"].([]interface{})
+			if assert.NotEmpty(t, stack) {
+				top, _ := stack[0].(map[string]interface{})
+				assert.Contains(t, top["function"], "TestHttpRecoverMiddleware")
+			}
+		}
+	})
+	t.Run("logs error panics", func(t *testing.T) {
+		wantErr := errors.New(fake.Lorem().Sentence(3))
+		_, entries := serve(func(w http.ResponseWriter, r *http.Request) {
+			panic(wantErr)
+		})
+		if assert.Len(t, entries, 1) {
+			assert.Equal(t, wantErr.Error(), entries[0]["error"])
+		}
+	})
+	t.Run("does not write response if headers sent", func(t *testing.T) {
+		wantBody := fake.Lorem().Sentence(3)
+		res, entries := serve(func(w http.ResponseWriter, r *http.Request) {
+			_, err := w.Write([]byte(wantBody))
+			if err != nil {
+				panic(err)
+			}
+			panic(fake.Lorem().Word())
+		})
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Equal(t, wantBody, res.Body.String())
+		assert.Len(t, entries, 1)
+	})
+	t.Run("custom response", func(t *testing.T) {
+		wantPanic := fake.Lorem().Word()
+		var gotRecovered interface{}
+		res, _ := serve(func(w http.ResponseWriter, r *http.Request) {
+			panic(wantPanic)
+		}, WithRecoverResponse(func(w http.ResponseWriter, req *http.Request, recovered interface{}) {
+			gotRecovered = recovered
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		assert.Equal(t, http.StatusServiceUnavailable, res.Code)
+		assert.Equal(t, wantPanic, gotRecovered)
+	})
+	t.Run("abort handler", func(t *testing.T) {
+		res, entries := serve(func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		})
+		assert.Equal(t, http.StatusOK, res.Code)
+		assert.Empty(t, res.Body.String())
+		if assert.Len(t, entries, 1) {
+			assert.Equal(t, "warn", entries[0]["level"])
+		}
+
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			serve(func(w http.ResponseWriter, r *http.Request) {
+				panic(http.ErrAbortHandler)
+			}, WithRepanicOnAbortHandler())
+		})
+	})
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gocombo/diag/http/internal"
+)
+
+// responseWrapper records the response status and stats of the wrapped writer
+type responseWrapper struct {
+	http.ResponseWriter
+
+	statusCode   int
+	bytesWritten int64
+	firstByteAt  time.Time
+	hijacked     bool
+
+	// bodyLog is set if response bodies should be captured
+	bodyLog *internal.BodyLogConfig
+	body    *internal.BodyCapture
+}
+
+// newResponseWrapper returns the wrapper to read stats from and a writer to pass to the next handlers.
+// The writer implements exactly the same optional interfaces (http.Flusher, http.Hijacker, io.ReaderFrom)
+// as the wrapped writer so type assertions by the next handlers work as without the wrapper.
+func newResponseWrapper(w http.ResponseWriter) (*responseWrapper, http.ResponseWriter) {
+	rw := &responseWrapper{ResponseWriter: w}
+	flusher, isFlusher := w.(http.Flusher)
+	hijacker, isHijacker := w.(http.Hijacker)
+	readerFrom, isReaderFrom := w.(io.ReaderFrom)
+
+	switch {
+	case isFlusher && isHijacker && isReaderFrom:
+		return rw, struct {
+			*responseWrapper
+			responseFlusher
+			responseHijacker
+			responseReaderFrom
+		}{rw, responseFlusher{rw, flusher}, responseHijacker{rw, hijacker}, responseReaderFrom{rw, readerFrom}}
+	case isFlusher && isHijacker:
+		return rw, struct {
+			*responseWrapper
+			responseFlusher
+			responseHijacker
+		}{rw, responseFlusher{rw, flusher}, responseHijacker{rw, hijacker}}
+	case isFlusher && isReaderFrom:
+		return rw, struct {
+			*responseWrapper
+			responseFlusher
+			responseReaderFrom
+		}{rw, responseFlusher{rw, flusher}, responseReaderFrom{rw, readerFrom}}
+	case isHijacker && isReaderFrom:
+		return rw, struct {
+			*responseWrapper
+			responseHijacker
+			responseReaderFrom
+		}{rw, responseHijacker{rw, hijacker}, responseReaderFrom{rw, readerFrom}}
+	case isFlusher:
+		return rw, struct {
+			*responseWrapper
+			responseFlusher
+		}{rw, responseFlusher{rw, flusher}}
+	case isHijacker:
+		return rw, struct {
+			*responseWrapper
+			responseHijacker
+		}{rw, responseHijacker{rw, hijacker}}
+	case isReaderFrom:
+		return rw, struct {
+			*responseWrapper
+			responseReaderFrom
+		}{rw, responseReaderFrom{rw, readerFrom}}
+	default:
+		return rw, rw
+	}
+}
+
+// markHeaderWritten records the status of the first final response header
+func (w *responseWrapper) markHeaderWritten(code int) {
+	if w.firstByteAt.IsZero() {
+		w.firstByteAt = time.Now()
+		w.body = w.bodyLog.NewCapture(w.Header().Get("Content-Type"))
+	}
+
+	// Informational headers (except 101) may be followed by the final one
+	isFinal := w.statusCode != 0 && (w.statusCode >= 200 || w.statusCode == http.StatusSwitchingProtocols)
+	if !isFinal {
+		w.statusCode = code
+	}
+}
+
+func (w *responseWrapper) WriteHeader(code int) {
+	w.markHeaderWritten(code)
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWrapper) Write(b []byte) (int, error) {
+	// Write without WriteHeader call implies 200 status
+	w.markHeaderWritten(http.StatusOK)
+	n, err := w.ResponseWriter.Write(b)
+	w.bytesWritten += int64(n)
+	if w.body != nil {
+		w.body.Capture(b[:n])
+	}
+	return n, err
+}
+
+// Unwrap allows http.ResponseController to access the wrapped writer
+func (w *responseWrapper) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+type responseFlusher struct {
+	w       *responseWrapper
+	flusher http.Flusher
+}
+
+func (f responseFlusher) Flush() {
+	f.w.markHeaderWritten(http.StatusOK)
+	f.flusher.Flush()
+}
+
+type responseHijacker struct {
+	w        *responseWrapper
+	hijacker http.Hijacker
+}
+
+func (h responseHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, buf, err := h.hijacker.Hijack()
+	if err == nil {
+		h.w.hijacked = true
+	}
+	return conn, buf, err
+}
+
+type responseReaderFrom struct {
+	w          *responseWrapper
+	readerFrom io.ReaderFrom
+}
+
+func (r responseReaderFrom) ReadFrom(src io.Reader) (int64, error) {
+	r.w.markHeaderWritten(http.StatusOK)
+	if r.w.body != nil {
+		src = io.TeeReader(src, r.w.body)
+	}
+	n, err := r.readerFrom.ReadFrom(src)
+	r.w.bytesWritten += n
+	return n, err
+}
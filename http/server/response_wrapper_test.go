@@ -0,0 +1,222 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gocombo/diag"
+	"github.com/gocombo/diag/http/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockFlusher struct {
+	flushed bool
+}
+
+func (f *mockFlusher) Flush() {
+	f.flushed = true
+}
+
+type mockHijacker struct {
+	err error
+}
+
+func (h *mockHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, h.err
+}
+
+type mockReaderFrom struct {
+	body strings.Builder
+}
+
+func (r *mockReaderFrom) ReadFrom(src io.Reader) (int64, error) {
+	b, err := io.ReadAll(src)
+	r.body.Write(b)
+	return int64(len(b)), err
+}
+
+// discardWriter accepts any headers sequence and discards the body
+type discardWriter struct {
+	header http.Header
+}
+
+func (w discardWriter) Header() http.Header {
+	return w.header
+}
+
+func (w discardWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w discardWriter) WriteHeader(int) {}
+
+// unwrappingWriter hides optional interfaces of the writer but allows unwrapping it
+type unwrappingWriter struct {
+	http.ResponseWriter
+}
+
+func (w unwrappingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func newMockWriter(flusher *mockFlusher, hijacker *mockHijacker, readerFrom *mockReaderFrom) http.ResponseWriter {
+	var w http.ResponseWriter = httptest.NewRecorder()
+	type writer struct{ http.ResponseWriter }
+	w = writer{w}
+	switch {
+	case flusher != nil && hijacker != nil && readerFrom != nil:
+		return struct {
+			http.ResponseWriter
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+		}{w, flusher, hijacker, readerFrom}
+	case flusher != nil && hijacker != nil:
+		return struct {
+			http.ResponseWriter
+			http.Flusher
+			http.Hijacker
+		}{w, flusher, hijacker}
+	case flusher != nil && readerFrom != nil:
+		return struct {
+			http.ResponseWriter
+			http.Flusher
+			io.ReaderFrom
+		}{w, flusher, readerFrom}
+	case hijacker != nil && readerFrom != nil:
+		return struct {
+			http.ResponseWriter
+			http.Hijacker
+			io.ReaderFrom
+		}{w, hijacker, readerFrom}
+	case flusher != nil:
+		return struct {
+			http.ResponseWriter
+			http.Flusher
+		}{w, flusher}
+	case hijacker != nil:
+		return struct {
+			http.ResponseWriter
+			http.Hijacker
+		}{w, hijacker}
+	case readerFrom != nil:
+		return struct {
+			http.ResponseWriter
+			io.ReaderFrom
+		}{w, readerFrom}
+	default:
+		return w
+	}
+}
+
+func TestResponseWrapper(t *testing.T) {
+	t.Run("exposes optional interfaces of the wrapped writer", func(t *testing.T) {
+		for i := 0; i < 8; i++ {
+			var flusher *mockFlusher
+			var hijacker *mockHijacker
+			var readerFrom *mockReaderFrom
+			if i&1 != 0 {
+				flusher = &mockFlusher{}
+			}
+			if i&2 != 0 {
+				hijacker = &mockHijacker{}
+			}
+			if i&4 != 0 {
+				readerFrom = &mockReaderFrom{}
+			}
+			rw, wrapped := newResponseWrapper(newMockWriter(flusher, hijacker, readerFrom))
+
+			gotFlusher, isFlusher := wrapped.(http.Flusher)
+			assert.Equal(t, flusher != nil, isFlusher)
+			if isFlusher {
+				gotFlusher.Flush()
+				assert.True(t, flusher.flushed)
+				assert.Equal(t, http.StatusOK, rw.statusCode)
+			}
+
+			gotHijacker, isHijacker := wrapped.(http.Hijacker)
+			assert.Equal(t, hijacker != nil, isHijacker)
+			if isHijacker {
+				_, _, err := gotHijacker.Hijack()
+				assert.NoError(t, err)
+				assert.True(t, rw.hijacked)
+			}
+
+			gotReaderFrom, isReaderFrom := wrapped.(io.ReaderFrom)
+			assert.Equal(t, readerFrom != nil, isReaderFrom)
+			if isReaderFrom {
+				wantBody := fake.Lorem().Sentence(5)
+				n, err := gotReaderFrom.ReadFrom(strings.NewReader(wantBody))
+				assert.NoError(t, err)
+				assert.Equal(t, int64(len(wantBody)), n)
+				assert.Equal(t, wantBody, readerFrom.body.String())
+				assert.Equal(t, int64(len(wantBody)), rw.bytesWritten)
+			}
+		}
+	})
+	t.Run("does not mark failed hijack", func(t *testing.T) {
+		rw, wrapped := newResponseWrapper(newMockWriter(nil, &mockHijacker{err: errors.New(fake.Lorem().Word())}, nil))
+		_, _, err := wrapped.(http.Hijacker).Hijack()
+		assert.Error(t, err)
+		assert.False(t, rw.hijacked)
+	})
+	t.Run("records stats", func(t *testing.T) {
+		rw, wrapped := newResponseWrapper(discardWriter{header: http.Header{}})
+		assert.True(t, rw.firstByteAt.IsZero())
+
+		wrapped.WriteHeader(http.StatusEarlyHints)
+		assert.False(t, rw.firstByteAt.IsZero())
+		wrapped.WriteHeader(http.StatusCreated)
+		wrapped.WriteHeader(http.StatusAccepted)
+		assert.Equal(t, http.StatusCreated, rw.statusCode)
+
+		body := fake.Lorem().Sentence(5)
+		_, err := wrapped.Write([]byte(body))
+		assert.NoError(t, err)
+		_, err = io.WriteString(wrapped, body)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2*len(body)), rw.bytesWritten)
+		assert.Equal(t, http.StatusCreated, rw.statusCode)
+	})
+	t.Run("captures body", func(t *testing.T) {
+		body := fake.Lorem().Sentence(5)
+		readerFrom := &mockReaderFrom{}
+		writer := newMockWriter(nil, nil, readerFrom)
+		writer.Header().Set("Content-Type", "text/plain")
+		rw, wrapped := newResponseWrapper(writer)
+		rw.bodyLog = internal.NewBodyLogConfig(len(body)*2, []string{"text/plain"}, nil)
+		_, err := wrapped.Write([]byte(body))
+		assert.NoError(t, err)
+		_, err = wrapped.(io.ReaderFrom).ReadFrom(strings.NewReader(body))
+		assert.NoError(t, err)
+		if assert.NotNil(t, rw.body) {
+			var output bytes.Buffer
+			log := diag.Log(diag.RootContext(diag.NewRootContextParams().WithOutput(&output)))
+			data := log.NewData()
+			rw.body.AppendLogData(rw.bodyLog, data, "body")
+			log.Info().WithData(data).Msg("")
+			assert.Contains(t, output.String(), body+body)
+		}
+
+		rw, wrapped = newResponseWrapper(httptest.NewRecorder())
+		rw.bodyLog = internal.NewBodyLogConfig(len(body), []string{"text/plain"}, nil)
+		_, err = wrapped.Write([]byte(body))
+		assert.NoError(t, err)
+		assert.Nil(t, rw.body)
+	})
+	t.Run("supports http.ResponseController", func(t *testing.T) {
+		res := httptest.NewRecorder()
+		_, wrapped := newResponseWrapper(unwrappingWriter{res})
+		_, isFlusher := wrapped.(http.Flusher)
+		assert.False(t, isFlusher)
+		assert.NoError(t, http.NewResponseController(wrapped).Flush())
+		assert.True(t, res.Flushed)
+	})
+}
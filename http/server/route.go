@@ -0,0 +1,51 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+type contextKey string
+
+const contextKeyRoute = contextKey("gocombo.diag.http.context-key.route")
+
+// routeHolder is placed to the request context by the log middleware
+// so the route matched by the inner router can be logged with END REQ
+type routeHolder struct {
+	route string
+}
+
+// SetRoute reports the route template (e.g. /users/{id}) matched for the request
+// to the http log middleware. Use it to log routes of routers other than http.ServeMux:
+//
+//	// chi
+//	r.Use(func(next http.Handler) http.Handler {
+//		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+//			next.ServeHTTP(w, r)
+//			server.SetRoute(r.Context(), chi.RouteContext(r.Context()).RoutePattern())
+//		})
+//	})
+//
+// Does nothing if the request is not handled by the log middleware.
+func SetRoute(ctx context.Context, route string) {
+	if holder, ok := ctx.Value(contextKeyRoute).(*routeHolder); ok {
+		holder.route = route
+	}
+}
+
+// requestRoute returns the route set via SetRoute or the http.ServeMux pattern
+// without method and host, empty if the route is not known
+func requestRoute(req *http.Request, holder *routeHolder) string {
+	if holder.route != "" {
+		return holder.route
+	}
+	pattern := requestPattern(req)
+	if _, path, found := strings.Cut(pattern, " "); found {
+		pattern = strings.TrimLeft(path, " \t")
+	}
+	if slash := strings.IndexByte(pattern, '/'); slash > 0 {
+		pattern = pattern[slash:]
+	}
+	return pattern
+}
@@ -0,0 +1,10 @@
+//go:build go1.23
+
+package server
+
+import "net/http"
+
+// requestPattern returns the pattern of http.ServeMux that matched the request
+func requestPattern(req *http.Request) string {
+	return req.Pattern
+}
@@ -0,0 +1,11 @@
+//go:build !go1.23
+
+package server
+
+import "net/http"
+
+// requestPattern returns empty pattern since http.ServeMux
+// does not expose the matched pattern before go1.23
+func requestPattern(*http.Request) string {
+	return ""
+}
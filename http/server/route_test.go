@@ -0,0 +1,96 @@
+//go:build go1.23
+
+//go:debug httpmuxgo121=0
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gocombo/diag"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoute(t *testing.T) {
+	serve := func(h http.Handler, target string) (map[string]interface{}, map[string]interface{}) {
+		var output bytes.Buffer
+		rootCtx := diag.RootContext(diag.NewRootContextParams().WithOutput(&output))
+		req := httptest.NewRequest("GET", target, http.NoBody).WithContext(rootCtx)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		outputLines := strings.Split(strings.Trim(output.String(), "\n"), "\n")
+		if !assert.Len(t, outputLines, 2) {
+			return nil, nil
+		}
+		var reqStart, reqEnd map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(outputLines[0]), &reqStart))
+		assert.NoError(t, json.Unmarshal([]byte(outputLines[1]), &reqEnd))
+		return reqStart, reqEnd
+	}
+	userID := fmt.Sprint(fake.IntBetween(1, 1000))
+
+	t.Run("matched by ServeMux", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET example.com/users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+		mux.HandleFunc("/orders/{id}", func(w http.ResponseWriter, r *http.Request) {})
+		h := BuildHandler(mux, NewHttpLogMiddleware())
+
+		reqStart, reqEnd := serve(h, "http://example.com/users/"+userID)
+		assert.NotContains(t, reqStart["data"], "route")
+		assert.Equal(t, "/users/{id}", reqEnd["data"].(map[string]interface{})["route"])
+		assert.Equal(t, "END REQ: 200 - /users/"+userID, reqEnd["msg"])
+
+		_, reqEnd = serve(h, "/orders/"+userID)
+		assert.Equal(t, "/orders/{id}", reqEnd["data"].(map[string]interface{})["route"])
+	})
+
+	t.Run("middleware attached to the route", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.Handle("GET /users/{id}", BuildHandler(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+			NewHttpLogMiddleware(WithHttpLogRouteInMessage()),
+		))
+
+		reqStart, reqEnd := serve(mux, "/users/"+userID)
+		assert.Equal(t, "/users/{id}", reqStart["data"].(map[string]interface{})["route"])
+		assert.Equal(t, "BEGIN REQ: GET /users/{id}", reqStart["msg"])
+		assert.Equal(t, "END REQ: 200 - /users/{id}", reqEnd["msg"])
+	})
+
+	t.Run("reported via SetRoute", func(t *testing.T) {
+		h := BuildHandler(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				SetRoute(r.Context(), "/custom/:id")
+			}),
+			NewHttpLogMiddleware(WithHttpLogRouteInMessage()),
+		)
+
+		reqStart, reqEnd := serve(h, "/custom/"+userID)
+		assert.Equal(t, "BEGIN REQ: GET /custom/"+userID, reqStart["msg"])
+		assert.Equal(t, "/custom/:id", reqEnd["data"].(map[string]interface{})["route"])
+		assert.Equal(t, "END REQ: 200 - /custom/:id", reqEnd["msg"])
+	})
+
+	t.Run("not known", func(t *testing.T) {
+		h := BuildHandler(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+			NewHttpLogMiddleware(WithHttpLogRouteInMessage()),
+		)
+
+		_, reqEnd := serve(h, "/plain/"+userID)
+		assert.NotContains(t, reqEnd["data"], "route")
+		assert.Equal(t, "END REQ: 200 - /plain/"+userID, reqEnd["msg"])
+	})
+
+	t.Run("SetRoute should ignore requests without log middleware", func(t *testing.T) {
+		assert.NotPanics(t, func() {
+			SetRoute(context.Background(), "/route")
+		})
+	})
+}
@@ -2,18 +2,171 @@ package server
 
 import (
 	"context"
+	"crypto/subtle"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/gocombo/diag"
 	"github.com/gofrs/uuid"
 )
 
+// TraceHeaderPrecedence defines which header is used as a correlation id
+// if both x-correlation-id and traceparent headers are present
+type TraceHeaderPrecedence int
+
+const (
+	// TraceHeaderPrecedenceCorrelationID uses x-correlation-id header as a correlation id,
+	// trace id of the traceparent header is used if there is no x-correlation-id header
+	TraceHeaderPrecedenceCorrelationID TraceHeaderPrecedence = iota
+
+	// TraceHeaderPrecedenceTraceParent uses trace id of the traceparent header as a correlation id,
+	// x-correlation-id header is used if there is no valid traceparent header
+	TraceHeaderPrecedenceTraceParent
+)
+
 type httpTraceMiddlewareOpts struct {
-	uuidFn func() string
+	uuidFn     func() string
+	precedence TraceHeaderPrecedence
+
+	levelOverrideHeader string
+	levelSecretHeader   string
+	levelSecret         string
+
+	tailBufferLevel      diag.LogLevel
+	tailBufferMaxEntries int
 }
 
 type HttpTraceMiddlewareOpt func(opts *httpTraceMiddlewareOpts)
 
+// WithTraceHeaderPrecedence defines which header wins if both x-correlation-id
+// and traceparent are present. Default is TraceHeaderPrecedenceCorrelationID
+func WithTraceHeaderPrecedence(precedence TraceHeaderPrecedence) HttpTraceMiddlewareOpt {
+	return func(opts *httpTraceMiddlewareOpts) {
+		opts.precedence = precedence
+	}
+}
+
+// WithLevelOverrideHeader makes the request logger use the level taken from
+// a given header (e.g. x-diag-level: trace). The level is propagated to all the
+// contexts derived from the request context. Invalid levels and levels that are not
+// more verbose than the current level are ignored, so clients can not suppress the logs.
+func WithLevelOverrideHeader(header string) HttpTraceMiddlewareOpt {
+	return func(opts *httpTraceMiddlewareOpts) {
+		opts.levelOverrideHeader = header
+	}
+}
+
+// WithLevelOverrideSecret makes the level override header applied only if the secretHeader
+// has a given secret value. The secret header is removed from the request passed to the next
+// handlers so it is not logged, the original request headers are not modified.
+func WithLevelOverrideSecret(secretHeader, secret string) HttpTraceMiddlewareOpt {
+	return func(opts *httpTraceMiddlewareOpts) {
+		opts.levelSecretHeader = secretHeader
+		opts.levelSecret = secret
+	}
+}
+
+// WithLogTailBuffer makes the request logger keep entries of a given or higher level that are
+// below the logger level in memory (up to maxEntries most recent). The entries are written
+// if an error entry is logged or the response status is 5xx and discarded otherwise.
+func WithLogTailBuffer(level diag.LogLevel, maxEntries int) HttpTraceMiddlewareOpt {
+	if _, ok := diag.ParseLogLevel(level.String()); !ok {
+		panic(fmt.Errorf("invalid log level %s", level))
+	}
+	if maxEntries <= 0 {
+		panic(fmt.Errorf("tail buffer max entries must be positive, got %d", maxEntries))
+	}
+	return func(opts *httpTraceMiddlewareOpts) {
+		opts.tailBufferLevel = level
+		opts.tailBufferMaxEntries = maxEntries
+	}
+}
+
+// parseLevelOverride returns the level requested via the level override header
+// if it is more verbose than the currentLevel
+func (opts *httpTraceMiddlewareOpts) parseLevelOverride(req *http.Request, currentLevel diag.LogLevel) (diag.LogLevel, bool, error) {
+	if opts.levelOverrideHeader == "" {
+		return "", false, nil
+	}
+	levelValue := req.Header.Get(opts.levelOverrideHeader)
+	var secret string
+	if opts.levelSecretHeader != "" {
+		secret = req.Header.Get(opts.levelSecretHeader)
+	}
+	if levelValue == "" {
+		return "", false, nil
+	}
+	if opts.levelSecretHeader != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(opts.levelSecret)) != 1 {
+		return "", false, fmt.Errorf("%s header has invalid secret", opts.levelOverrideHeader)
+	}
+	level, ok := diag.ParseLogLevel(strings.ToLower(levelValue))
+	if !ok {
+		return "", false, fmt.Errorf("%s header has invalid log level %q", opts.levelOverrideHeader, levelValue)
+	}
+	if !level.MoreVerboseThan(currentLevel) {
+		return "", false, fmt.Errorf("%s header level %s is not more verbose than %s", opts.levelOverrideHeader, level, currentLevel)
+	}
+	return level, true, nil
+}
+
+// withoutLevelSecret returns a shallow copy of the request without the secret header
+func (opts *httpTraceMiddlewareOpts) withoutLevelSecret(req *http.Request) *http.Request {
+	if opts.levelSecretHeader == "" || req.Header.Get(opts.levelSecretHeader) == "" {
+		return req
+	}
+	outReq := new(http.Request)
+	*outReq = *req
+	outReq.Header = req.Header.Clone()
+	outReq.Header.Del(opts.levelSecretHeader)
+	return outReq
+}
+
+// currentLevel returns the level the request logger would use without the level override
+func currentLevel(rootCtx context.Context) diag.LogLevel {
+	if level, ok := diag.LogLevelOverride(rootCtx); ok {
+		return level
+	}
+	return diag.LevelControl(rootCtx).Level()
+}
+
+// parseTraceContext returns trace context of the request span.
+// X-Cloud-Trace-Context header set by GCP load balancers is used if there is no traceparent header.
+// A new trace is started if the request has no valid trace context headers.
+func parseTraceContext(req *http.Request) (diag.TraceContext, bool, error) {
+	traceParent := req.Header.Get("traceparent")
+	if traceParent == "" {
+		return parseCloudTraceContext(req)
+	}
+	parent, err := diag.ParseTraceParent(traceParent)
+	if err != nil {
+		return diag.NewTraceContext(), false, err
+	}
+	trace := parent.ChildSpan()
+
+	// tracestate is not valid without traceparent so parsed only if traceparent is valid
+	// invalid tracestate is discarded as per spec
+	if traceState, err := diag.ParseTraceState(strings.Join(req.Header.Values("tracestate"), ",")); err == nil {
+		trace.TraceState = traceState
+	}
+	return trace, true, nil
+}
+
+func parseCloudTraceContext(req *http.Request) (diag.TraceContext, bool, error) {
+	cloudTraceContext := req.Header.Get("X-Cloud-Trace-Context")
+	if cloudTraceContext == "" {
+		return diag.NewTraceContext(), false, nil
+	}
+	parent, err := diag.ParseCloudTraceContext(cloudTraceContext)
+	if err != nil {
+		return diag.NewTraceContext(), false, err
+	}
+	return parent.ChildSpan(), true, nil
+}
+
+// NewHttpTraceMiddleware creates a request diag context with a correlation id
+// taken from x-correlation-id or W3C traceparent headers. Each request gets
+// a new span id, the trace and parent span ids are taken from the traceparent.
 func NewHttpTraceMiddleware(rootCtx context.Context, opts ...HttpTraceMiddlewareOpt) func(http.Handler) http.Handler {
 	cfg := httpTraceMiddlewareOpts{
 		uuidFn: func() string {
@@ -27,12 +180,54 @@ func NewHttpTraceMiddleware(rootCtx context.Context, opts ...HttpTraceMiddleware
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			trace, hasTraceParent, traceErr := parseTraceContext(req)
 			correlationID := req.Header.Get("x-correlation-id")
+			if hasTraceParent && (correlationID == "" || cfg.precedence == TraceHeaderPrecedenceTraceParent) {
+				correlationID = trace.TraceID
+			}
 			if correlationID == "" {
 				correlationID = cfg.uuidFn()
 			}
-			reqCtx := diag.DiagifyContext(req.Context(), rootCtx, diag.WithCorrelationID(correlationID))
-			next.ServeHTTP(w, req.WithContext(reqCtx))
+			diagOpts := []diag.DiagContextOption{
+				diag.WithCorrelationID(correlationID),
+				diag.WithTraceContext(trace),
+			}
+			if xrayTrace := req.Header.Get("X-Amzn-Trace-Id"); xrayTrace != "" {
+				diagOpts = append(diagOpts, diag.WithAWSXRayTraceHeader(xrayTrace))
+			}
+			level, hasLevel, levelErr := cfg.parseLevelOverride(req, currentLevel(rootCtx))
+			req = cfg.withoutLevelSecret(req)
+			if hasLevel {
+				diagOpts = append(diagOpts, diag.WithLogLevel(level))
+			}
+			var tailBuffer *diag.LogTailBuffer
+			if cfg.tailBufferMaxEntries > 0 {
+				tailBuffer = diag.NewLogTailBuffer(cfg.tailBufferLevel, cfg.tailBufferMaxEntries)
+				diagOpts = append(diagOpts, diag.WithTailBuffer(tailBuffer))
+			}
+			reqCtx := diag.DiagifyContext(req.Context(), rootCtx, diagOpts...)
+			if traceErr != nil {
+				diag.Log(reqCtx).Debug().WithError(traceErr).Msg("Ignoring invalid trace context header")
+			}
+			if levelErr != nil {
+				diag.Log(reqCtx).Debug().WithError(levelErr).Msg("Ignoring log level override")
+			}
+			if tailBuffer == nil {
+				next.ServeHTTP(w, req.WithContext(reqCtx))
+				return
+			}
+
+			rw, wrapped := newResponseWrapper(w)
+			panics := true
+			defer func() {
+				if panics || rw.statusCode >= 500 {
+					tailBuffer.Flush()
+				} else {
+					tailBuffer.Discard()
+				}
+			}()
+			next.ServeHTTP(wrapped, req.WithContext(reqCtx))
+			panics = false
 		})
 	}
 }
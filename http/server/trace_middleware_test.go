@@ -1,7 +1,9 @@
 package server
 
 import (
+	"bytes"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -61,4 +63,272 @@ func TestHttpTraceMiddleware(t *testing.T) {
 		}
 		assert.Equal(t, wantCorrelationId, gotDiagData.CorrelationID)
 	})
+	t.Run("W3C trace context", func(t *testing.T) {
+		serveTrace := func(t *testing.T, req *http.Request, opts ...HttpTraceMiddlewareOpt) (diag.ContextDiagData, bool) {
+			var gotDiagData diag.ContextDiagData
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotDiagData = diag.DiagData(r.Context())
+			})
+			rootCtx := diag.RootContext(diag.NewRootContextParams().WithOutput(io.Discard))
+			wrapped := BuildHandler(h, NewHttpTraceMiddleware(rootCtx, opts...))
+			res := httptest.NewRecorder()
+			wrapped.ServeHTTP(res, req)
+			return gotDiagData, assert.Equal(t, http.StatusOK, res.Code)
+		}
+		const (
+			wantTraceID      = "4bf92f3577b34da6a3ce929d0e0e4736"
+			wantParentSpanID = "00f067aa0ba902b7"
+			traceParent      = "00-" + wantTraceID + "-" + wantParentSpanID + "-01"
+		)
+
+		t.Run("setup trace from traceparent", func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/something", http.NoBody)
+			req.Header.Set("traceparent", traceParent)
+			req.Header.Add("tracestate", "rojo=00f067aa0ba902b7")
+			req.Header.Add("tracestate", "congo=t61rcWkgMzE")
+			gotDiagData, ok := serveTrace(t, req)
+			if !ok {
+				return
+			}
+			assert.Equal(t, wantTraceID, gotDiagData.CorrelationID)
+			assert.Equal(t, wantTraceID, gotDiagData.Trace.TraceID)
+			assert.Equal(t, wantParentSpanID, gotDiagData.Trace.ParentSpanID)
+			assert.NotEqual(t, wantParentSpanID, gotDiagData.Trace.SpanID)
+			assert.Len(t, gotDiagData.Trace.SpanID, 16)
+			assert.True(t, gotDiagData.Trace.Sampled)
+			assert.Equal(t, "rojo=00f067aa0ba902b7,congo=t61rcWkgMzE", gotDiagData.Trace.TraceState)
+		})
+		t.Run("discard invalid tracestate", func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/something", http.NoBody)
+			req.Header.Set("traceparent", traceParent)
+			req.Header.Set("tracestate", "Rojo")
+			gotDiagData, ok := serveTrace(t, req)
+			if !ok {
+				return
+			}
+			assert.Equal(t, wantTraceID, gotDiagData.Trace.TraceID)
+			assert.Empty(t, gotDiagData.Trace.TraceState)
+		})
+		t.Run("start new trace if no traceparent", func(t *testing.T) {
+			wantCorrelationID := fake.UUID().V4()
+			req := httptest.NewRequest("GET", "/something", http.NoBody)
+			req.Header.Set("X-Correlation-Id", wantCorrelationID)
+			gotDiagData, ok := serveTrace(t, req)
+			if !ok {
+				return
+			}
+			assert.Equal(t, wantCorrelationID, gotDiagData.CorrelationID)
+			assert.True(t, gotDiagData.Trace.IsValid())
+			assert.Empty(t, gotDiagData.Trace.ParentSpanID)
+		})
+		t.Run("start new trace if invalid traceparent", func(t *testing.T) {
+			wantCorrelationID := fake.UUID().V4()
+			req := httptest.NewRequest("GET", "/something", http.NoBody)
+			req.Header.Set("traceparent", "00-"+wantTraceID)
+			req.Header.Set("tracestate", "rojo=00f067aa0ba902b7")
+			gotDiagData, ok := serveTrace(t, req, func(opts *httpTraceMiddlewareOpts) {
+				opts.uuidFn = func() string {
+					return wantCorrelationID
+				}
+			})
+			if !ok {
+				return
+			}
+			assert.Equal(t, wantCorrelationID, gotDiagData.CorrelationID)
+			assert.True(t, gotDiagData.Trace.IsValid())
+			assert.NotEqual(t, wantTraceID, gotDiagData.Trace.TraceID)
+			assert.Empty(t, gotDiagData.Trace.ParentSpanID)
+			assert.Empty(t, gotDiagData.Trace.TraceState)
+		})
+		t.Run("setup trace from X-Cloud-Trace-Context", func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/something", http.NoBody)
+			req.Header.Set("X-Cloud-Trace-Context", wantTraceID+"/42;o=1")
+			gotDiagData, ok := serveTrace(t, req)
+			if !ok {
+				return
+			}
+			assert.Equal(t, wantTraceID, gotDiagData.CorrelationID)
+			assert.Equal(t, wantTraceID, gotDiagData.Trace.TraceID)
+			assert.Equal(t, "000000000000002a", gotDiagData.Trace.ParentSpanID)
+			assert.Len(t, gotDiagData.Trace.SpanID, 16)
+			assert.True(t, gotDiagData.Trace.Sampled)
+		})
+		t.Run("prefer traceparent over X-Cloud-Trace-Context", func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/something", http.NoBody)
+			req.Header.Set("traceparent", traceParent)
+			req.Header.Set("X-Cloud-Trace-Context", "105445aa7843bc8bf206b12000100000/42;o=1")
+			gotDiagData, ok := serveTrace(t, req)
+			if !ok {
+				return
+			}
+			assert.Equal(t, wantTraceID, gotDiagData.Trace.TraceID)
+			assert.Equal(t, wantParentSpanID, gotDiagData.Trace.ParentSpanID)
+		})
+		t.Run("setup X-Ray trace id from X-Amzn-Trace-Id", func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/something", http.NoBody)
+			req.Header.Set("X-Amzn-Trace-Id", "Root=1-5759e988-bd862e3fe1be46a994272793;Sampled=1")
+			gotDiagData, ok := serveTrace(t, req)
+			if !ok {
+				return
+			}
+			assert.Equal(t, "1-5759e988-bd862e3fe1be46a994272793", gotDiagData.Entries[diag.AWSXRayTraceIDEntry])
+		})
+		t.Run("precedence", func(t *testing.T) {
+			wantCorrelationID := fake.UUID().V4()
+			newReq := func() *http.Request {
+				req := httptest.NewRequest("GET", "/something", http.NoBody)
+				req.Header.Set("traceparent", traceParent)
+				req.Header.Set("X-Correlation-Id", wantCorrelationID)
+				return req
+			}
+
+			gotDiagData, ok := serveTrace(t, newReq())
+			if !ok {
+				return
+			}
+			assert.Equal(t, wantCorrelationID, gotDiagData.CorrelationID)
+			assert.Equal(t, wantTraceID, gotDiagData.Trace.TraceID)
+
+			gotDiagData, ok = serveTrace(t, newReq(), WithTraceHeaderPrecedence(TraceHeaderPrecedenceTraceParent))
+			if !ok {
+				return
+			}
+			assert.Equal(t, wantTraceID, gotDiagData.CorrelationID)
+			assert.Equal(t, wantTraceID, gotDiagData.Trace.TraceID)
+		})
+	})
+	t.Run("level override header", func(t *testing.T) {
+		type result struct {
+			level          diag.LogLevel
+			hasLevel       bool
+			secretHeader   string
+			debugLogOutput string
+		}
+		serveLevel := func(req *http.Request, opts ...HttpTraceMiddlewareOpt) result {
+			var res result
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				res.level, res.hasLevel = diag.LogLevelOverride(r.Context())
+				res.secretHeader = r.Header.Get("x-diag-secret")
+			})
+			output := &bytes.Buffer{}
+			rootCtx := diag.RootContext(diag.NewRootContextParams().
+				WithLogLevel(diag.LogLevelDebugValue).
+				WithOutput(output))
+			BuildHandler(h, NewHttpTraceMiddleware(rootCtx, opts...)).
+				ServeHTTP(httptest.NewRecorder(), req)
+			res.debugLogOutput = output.String()
+			return res
+		}
+		newReq := func(level string) *http.Request {
+			req := httptest.NewRequest("GET", "/something", http.NoBody)
+			req.Header.Set("x-diag-level", level)
+			return req
+		}
+
+		t.Run("ignored if not enabled", func(t *testing.T) {
+			res := serveLevel(newReq("trace"))
+			assert.False(t, res.hasLevel)
+		})
+		t.Run("applies the level", func(t *testing.T) {
+			res := serveLevel(newReq("TRACE"), WithLevelOverrideHeader("x-diag-level"))
+			assert.True(t, res.hasLevel)
+			assert.Equal(t, diag.LogLevelTraceValue, res.level)
+		})
+		t.Run("no header", func(t *testing.T) {
+			res := serveLevel(newReq(""), WithLevelOverrideHeader("x-diag-level"))
+			assert.False(t, res.hasLevel)
+			assert.Empty(t, res.debugLogOutput)
+		})
+		t.Run("ignores levels that are not more verbose", func(t *testing.T) {
+			for _, level := range []string{"debug", "info", "error"} {
+				res := serveLevel(newReq(level), WithLevelOverrideHeader("x-diag-level"))
+				assert.False(t, res.hasLevel, level)
+				assert.Contains(t, res.debugLogOutput, "x-diag-level header level "+level+" is not more verbose than debug")
+			}
+		})
+		t.Run("ignores invalid level", func(t *testing.T) {
+			badLevel := "bad-" + fake.Lorem().Word()
+			res := serveLevel(newReq(badLevel), WithLevelOverrideHeader("x-diag-level"))
+			assert.False(t, res.hasLevel)
+			assert.Contains(t, res.debugLogOutput, "Ignoring log level override")
+			assert.Contains(t, res.debugLogOutput, badLevel)
+		})
+		t.Run("secret", func(t *testing.T) {
+			secret := fake.Internet().Password()
+			opts := []HttpTraceMiddlewareOpt{
+				WithLevelOverrideHeader("x-diag-level"),
+				WithLevelOverrideSecret("x-diag-secret", secret),
+			}
+
+			req := newReq("trace")
+			req.Header.Set("x-diag-secret", secret)
+			res := serveLevel(req, opts...)
+			assert.True(t, res.hasLevel)
+			assert.Equal(t, diag.LogLevelTraceValue, res.level)
+			assert.Empty(t, res.secretHeader)
+			assert.Equal(t, secret, req.Header.Get("x-diag-secret"), "original request should not be modified")
+
+			badSecret := "bad-" + fake.Lorem().Word()
+			req = newReq("trace")
+			req.Header.Set("x-diag-secret", badSecret)
+			res = serveLevel(req, opts...)
+			assert.False(t, res.hasLevel)
+			assert.Empty(t, res.secretHeader)
+			assert.Contains(t, res.debugLogOutput, "x-diag-level header has invalid secret")
+			assert.NotContains(t, res.debugLogOutput, badSecret)
+
+			res = serveLevel(newReq("trace"), opts...)
+			assert.False(t, res.hasLevel)
+		})
+	})
+	t.Run("log tail buffer", func(t *testing.T) {
+		serve := func(output *bytes.Buffer, handlerFn http.HandlerFunc, opts ...HttpTraceMiddlewareOpt) string {
+			rootCtx := diag.RootContext(diag.NewRootContextParams().
+				WithLogLevel(diag.LogLevelInfoValue).
+				WithOutput(output))
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				diag.Log(r.Context()).Debug().Msg("debug entry")
+				diag.Log(r.Context()).Info().Msg("info entry")
+				handlerFn(w, r)
+			})
+			BuildHandler(h, NewHttpTraceMiddleware(rootCtx, opts...)).
+				ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/something", http.NoBody))
+			return output.String()
+		}
+		withTailBuffer := WithLogTailBuffer(diag.LogLevelDebugValue, 10)
+
+		t.Run("disabled by default", func(t *testing.T) {
+			output := serve(&bytes.Buffer{}, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			})
+			assert.Contains(t, output, "info entry")
+			assert.NotContains(t, output, "debug entry")
+		})
+		t.Run("discarded on success", func(t *testing.T) {
+			output := serve(&bytes.Buffer{}, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadRequest)
+			}, withTailBuffer)
+			assert.Contains(t, output, "info entry")
+			assert.NotContains(t, output, "debug entry")
+		})
+		t.Run("flushed on 5xx", func(t *testing.T) {
+			output := serve(&bytes.Buffer{}, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadGateway)
+			}, withTailBuffer)
+			assert.Contains(t, output, "debug entry")
+		})
+		t.Run("flushed on panic", func(t *testing.T) {
+			output := &bytes.Buffer{}
+			assert.Panics(t, func() {
+				serve(output, func(w http.ResponseWriter, r *http.Request) {
+					panic("boom")
+				}, withTailBuffer)
+			})
+			assert.Contains(t, output.String(), "debug entry")
+		})
+		t.Run("invalid options", func(t *testing.T) {
+			assert.Panics(t, func() { WithLogTailBuffer("bad-"+diag.LogLevel(fake.Lorem().Word()), 1) })
+			assert.Panics(t, func() { WithLogTailBuffer(diag.LogLevelDebugValue, 0) })
+		})
+	})
 }
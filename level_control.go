@@ -0,0 +1,283 @@
+package diag
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// logLevels lists all the valid levels from the most to the least verbose
+var logLevels = []LogLevel{
+	LogLevelTraceValue,
+	LogLevelDebugValue,
+	LogLevelInfoValue,
+	LogLevelWarnValue,
+	LogLevelErrorValue,
+}
+
+// severity returns the level position in logLevels, -1 if not a valid level
+func (l LogLevel) severity() int {
+	switch l {
+	case LogLevelTraceValue:
+		return 0
+	case LogLevelDebugValue:
+		return 1
+	case LogLevelInfoValue:
+		return 2
+	case LogLevelWarnValue:
+		return 3
+	case LogLevelErrorValue:
+		return 4
+	default:
+		return -1
+	}
+}
+
+// MoreVerboseThan returns true if the level writes entries the other level filters out,
+// e.g. debug is more verbose than info. Invalid levels are never more verbose.
+func (l LogLevel) MoreVerboseThan(other LogLevel) bool {
+	severity := l.severity()
+	return severity >= 0 && severity < other.severity()
+}
+
+// changeEntryLevel returns the level to log control changes at. It is at least info
+// but not below the current level so the entry is not filtered out.
+func changeEntryLevel(currentLevel LogLevel) LogLevel {
+	if currentLevel.severity() > LogLevelInfoValue.severity() {
+		return currentLevel
+	}
+	return LogLevelInfoValue
+}
+
+// LevelChange describes a runtime log level change
+type LevelChange struct {
+	PreviousLevel LogLevel  `json:"previousLevel"`
+	Level         LogLevel  `json:"level"`
+	ChangedBy     string    `json:"changedBy"`
+	ChangedAt     time.Time `json:"changedAt"`
+}
+
+// CorrelationLevel is a log level applied to all the loggers with a given correlation id
+type CorrelationLevel struct {
+	CorrelationID string    `json:"correlationId"`
+	Level         LogLevel  `json:"level"`
+	ChangedBy     string    `json:"changedBy"`
+	ChangedAt     time.Time `json:"changedAt"`
+}
+
+// LogLevelControl holds the log level shared by the root logger and all the loggers
+// derived from it. Changing the level immediately affects all the loggers
+// except the ones created with an explicit level (e.g. via WithLogLevel option).
+type LogLevelControl struct {
+	level atomic.Pointer[controlLevel]
+
+	// correlationLevels is replaced on each change so lookups do not need locking
+	correlationLevels atomic.Pointer[map[string]CorrelationLevel]
+
+	mu         sync.Mutex
+	logger     LevelLogger
+	lastChange *LevelChange
+}
+
+// controlLevel keeps the level severity so loggers compare levels without parsing them on each entry
+type controlLevel struct {
+	level    LogLevel
+	severity int
+}
+
+func newControlLevel(level LogLevel) *controlLevel {
+	return &controlLevel{level: level, severity: level.severity()}
+}
+
+func newLogLevelControl(level LogLevel) *LogLevelControl {
+	c := &LogLevelControl{}
+	c.level.Store(newControlLevel(level))
+	return c
+}
+
+// Level returns the current log level
+func (c *LogLevelControl) Level() LogLevel {
+	return c.level.Load().level
+}
+
+// SetLevel changes the log level, changedBy should describe
+// who initiated the change (e.g. admin api, signal).
+// The change is logged with the root logger.
+func (c *LogLevelControl) SetLevel(level LogLevel, changedBy string) error {
+	if _, ok := ParseLogLevel(level.String()); !ok {
+		return fmt.Errorf("invalid log level %s", level)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	change := LevelChange{
+		PreviousLevel: c.Level(),
+		Level:         level,
+		ChangedBy:     changedBy,
+		ChangedAt:     time.Now(),
+	}
+	c.level.Store(newControlLevel(level))
+	c.lastChange = &change
+
+	if c.logger != nil {
+		c.logger.WithLevel(changeEntryLevel(level)).
+			WithData(c.logger.NewData().
+				Str("previousLevel", change.PreviousLevel.String()).
+				Str("level", change.Level.String()).
+				Str("changedBy", change.ChangedBy).
+				Time("changedAt", change.ChangedAt)).
+			Msgf("Log level changed from %s to %s by %s", change.PreviousLevel, change.Level, change.ChangedBy)
+	}
+	return nil
+}
+
+// LastChange returns the last level change, false if level was never changed
+func (c *LogLevelControl) LastChange() (LevelChange, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lastChange == nil {
+		return LevelChange{}, false
+	}
+	return *c.lastChange, true
+}
+
+// SetCorrelationLevel makes loggers with a given correlation id use the level
+// regardless of the control level or the level the logger was created with.
+// Applies to already existing loggers as well. The change is logged with the root logger.
+func (c *LogLevelControl) SetCorrelationLevel(correlationID string, level LogLevel, changedBy string) error {
+	if correlationID == "" {
+		return fmt.Errorf("correlation id is required")
+	}
+	if _, ok := ParseLogLevel(level.String()); !ok {
+		return fmt.Errorf("invalid log level %s", level)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	override := CorrelationLevel{
+		CorrelationID: correlationID,
+		Level:         level,
+		ChangedBy:     changedBy,
+		ChangedAt:     time.Now(),
+	}
+	c.updateCorrelationLevels(func(levels map[string]CorrelationLevel) {
+		levels[correlationID] = override
+	})
+
+	if c.logger != nil {
+		c.logger.WithLevel(changeEntryLevel(c.Level())).
+			WithData(c.logger.NewData().
+				Str("correlationId", correlationID).
+				Str("level", level.String()).
+				Str("changedBy", changedBy).
+				Time("changedAt", override.ChangedAt)).
+			Msgf("Log level of %s set to %s by %s", correlationID, level, changedBy)
+	}
+	return nil
+}
+
+// RemoveCorrelationLevel removes the correlation id level, returns false if there was no such level
+func (c *LogLevelControl) RemoveCorrelationLevel(correlationID string, changedBy string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.correlationLevel(correlationID); !ok {
+		return false
+	}
+	c.updateCorrelationLevels(func(levels map[string]CorrelationLevel) {
+		delete(levels, correlationID)
+	})
+
+	if c.logger != nil {
+		c.logger.WithLevel(changeEntryLevel(c.Level())).
+			WithData(c.logger.NewData().
+				Str("correlationId", correlationID).
+				Str("changedBy", changedBy)).
+			Msgf("Log level of %s removed by %s", correlationID, changedBy)
+	}
+	return true
+}
+
+// CorrelationLevels returns all the correlation id levels ordered by correlation id
+func (c *LogLevelControl) CorrelationLevels() []CorrelationLevel {
+	levels := c.correlationLevels.Load()
+	if levels == nil {
+		return []CorrelationLevel{}
+	}
+	result := make([]CorrelationLevel, 0, len(*levels))
+	for _, level := range *levels {
+		result = append(result, level)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CorrelationID < result[j].CorrelationID
+	})
+	return result
+}
+
+func (c *LogLevelControl) correlationLevel(correlationID string) (CorrelationLevel, bool) {
+	levels := c.correlationLevels.Load()
+	if levels == nil {
+		return CorrelationLevel{}, false
+	}
+	level, ok := (*levels)[correlationID]
+	return level, ok
+}
+
+// updateCorrelationLevels should be called with the mu locked
+func (c *LogLevelControl) updateCorrelationLevels(update func(levels map[string]CorrelationLevel)) {
+	levels := make(map[string]CorrelationLevel)
+	if current := c.correlationLevels.Load(); current != nil {
+		for k, v := range *current {
+			levels[k] = v
+		}
+	}
+	update(levels)
+	if len(levels) == 0 {
+		c.correlationLevels.Store(nil)
+		return
+	}
+	c.correlationLevels.Store(&levels)
+}
+
+// loggerLevel returns the effective level of a logger with a given
+// correlation id and optional level the logger was created with
+func (c *LogLevelControl) loggerLevel(correlationID string, levelOverride *LogLevel) LogLevel {
+	if correlationLevel, ok := c.correlationLevel(correlationID); ok {
+		return correlationLevel.Level
+	}
+	if levelOverride != nil {
+		return *levelOverride
+	}
+	return c.Level()
+}
+
+// loggerSeverity returns the severity of the loggerLevel, -1 if the level is not valid
+func (c *LogLevelControl) loggerSeverity(correlationID string, levelOverride *LogLevel) int {
+	if correlationLevel, ok := c.correlationLevel(correlationID); ok {
+		return correlationLevel.Level.severity()
+	}
+	if levelOverride != nil {
+		return levelOverride.severity()
+	}
+	return c.level.Load().severity
+}
+
+// shiftLevel moves the level by a given number of positions in logLevels.
+// Negative delta makes logging more verbose. The result is clamped to valid levels.
+func (c *LogLevelControl) shiftLevel(delta int, changedBy string) {
+	current := c.Level().severity()
+	if current < 0 {
+		current = LogLevelDebugValue.severity()
+	}
+	next := current + delta
+	if next < 0 || next >= len(logLevels) || next == current {
+		return
+	}
+	if err := c.SetLevel(logLevels[next], changedBy); err != nil {
+		panic(err) // should not happen since logLevels are all valid
+	}
+}
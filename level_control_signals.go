@@ -0,0 +1,36 @@
+//go:build !windows
+
+package diag
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// CycleLevelOnSignals makes the level more verbose on SIGUSR1 (e.g. info -> debug)
+// and less verbose on SIGUSR2 (e.g. info -> warn). Level is not changed past trace or error.
+// Returned function stops listening for the signals.
+func (c *LogLevelControl) CycleLevelOnSignals() (stop func()) {
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				if sig == syscall.SIGUSR1 {
+					c.shiftLevel(-1, "signal:SIGUSR1")
+				} else {
+					c.shiftLevel(1, "signal:SIGUSR2")
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
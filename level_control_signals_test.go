@@ -0,0 +1,35 @@
+//go:build !windows
+
+package diag
+
+import (
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogLevelControl_CycleLevelOnSignals(t *testing.T) {
+	ctx := RootContext(NewRootContextParams().
+		WithLogLevel(LogLevelInfoValue).
+		WithOutput(io.Discard))
+	control := LevelControl(ctx)
+	stop := control.CycleLevelOnSignals()
+	defer stop()
+
+	assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR1))
+	assert.Eventually(t, func() bool {
+		return control.Level() == LogLevelDebugValue
+	}, time.Second, time.Millisecond)
+	change, _ := control.LastChange()
+	assert.Equal(t, "signal:SIGUSR1", change.ChangedBy)
+
+	assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR2))
+	assert.Eventually(t, func() bool {
+		return control.Level() == LogLevelInfoValue
+	}, time.Second, time.Millisecond)
+	change, _ = control.LastChange()
+	assert.Equal(t, "signal:SIGUSR2", change.ChangedBy)
+}
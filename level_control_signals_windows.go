@@ -0,0 +1,8 @@
+//go:build windows
+
+package diag
+
+// CycleLevelOnSignals is a noop on windows since there are no SIGUSR1/SIGUSR2 signals
+func (c *LogLevelControl) CycleLevelOnSignals() (stop func()) {
+	return func() {}
+}
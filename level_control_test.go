@@ -0,0 +1,242 @@
+package diag
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readLogEntries(t *testing.T, output *bytes.Buffer) []map[string]interface{} {
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]interface{}
+		if !assert.NoError(t, json.Unmarshal([]byte(line), &entry)) {
+			return nil
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestLogLevelControl(t *testing.T) {
+	factories := map[string]LoggerFactory{
+		"zerolog": zerologLoggerFactory{},
+		"slog":    NewSlogLoggerFactory(),
+	}
+
+	for name, factory := range factories {
+		factory := factory
+		newRootContext := func(level LogLevel) (context.Context, *bytes.Buffer) {
+			output := &bytes.Buffer{}
+			ctx := RootContext(NewRootContextParams().
+				WithLoggerFactory(factory).
+				WithLogLevel(level).
+				WithOutput(output))
+			return ctx, output
+		}
+
+		t.Run(name, func(t *testing.T) {
+			t.Run("creates a control with the root level", func(t *testing.T) {
+				ctx, _ := newRootContext(LogLevelWarnValue)
+				control := LevelControl(ctx)
+				assert.Equal(t, LogLevelWarnValue, control.Level())
+				_, changed := control.LastChange()
+				assert.False(t, changed)
+			})
+			t.Run("shares the control with derived contexts", func(t *testing.T) {
+				ctx, _ := newRootContext(LogLevelWarnValue)
+				childCtx := DiagifyContext(context.Background(), ctx)
+				forkedCtx := ForkContext(childCtx)
+				assert.Same(t, LevelControl(ctx), LevelControl(childCtx))
+				assert.Same(t, LevelControl(ctx), LevelControl(forkedCtx))
+			})
+			t.Run("changes level of existing loggers", func(t *testing.T) {
+				ctx, output := newRootContext(LogLevelWarnValue)
+				childCtx := DiagifyContext(context.Background(), ctx)
+				forkedCtx := ForkContext(childCtx)
+
+				Log(ctx).Debug().Msg(fake.Lorem().Sentence(3))
+				Log(childCtx).Debug().Msg(fake.Lorem().Sentence(3))
+				Log(forkedCtx).Debug().Msg(fake.Lorem().Sentence(3))
+				assert.Empty(t, output.String())
+
+				changedBy := fake.Lorem().Word()
+				assert.NoError(t, LevelControl(ctx).SetLevel(LogLevelDebugValue, changedBy))
+				output.Reset()
+
+				wantMessages := []string{
+					fake.Lorem().Sentence(3),
+					fake.Lorem().Sentence(3),
+					fake.Lorem().Sentence(3),
+				}
+				Log(ctx).Debug().Msg(wantMessages[0])
+				Log(childCtx).Debug().Msg(wantMessages[1])
+				Log(forkedCtx).Debug().Msg(wantMessages[2])
+				Log(forkedCtx).Trace().Msg(fake.Lorem().Sentence(3))
+
+				entries := readLogEntries(t, output)
+				if assert.Len(t, entries, len(wantMessages)) {
+					for i, entry := range entries {
+						assert.Equal(t, wantMessages[i], entry["msg"])
+					}
+				}
+			})
+			t.Run("keeps explicit level of child loggers", func(t *testing.T) {
+				ctx, output := newRootContext(LogLevelWarnValue)
+				childCtx := DiagifyContext(context.Background(), ctx, WithLogLevel(LogLevelInfoValue))
+				nestedCtx := DiagifyContext(context.Background(), childCtx)
+
+				assert.NoError(t, LevelControl(ctx).SetLevel(LogLevelErrorValue, fake.Lorem().Word()))
+				output.Reset()
+
+				Log(childCtx).Info().Msg(fake.Lorem().Sentence(3))
+				Log(nestedCtx).Info().Msg(fake.Lorem().Sentence(3))
+				Log(childCtx).Debug().Msg(fake.Lorem().Sentence(3))
+				Log(ctx).Warn().Msg(fake.Lorem().Sentence(3))
+				assert.Len(t, readLogEntries(t, output), 2)
+			})
+			t.Run("logs the level change", func(t *testing.T) {
+				ctx, output := newRootContext(LogLevelWarnValue)
+				changedBy := fake.Lorem().Word()
+				before := time.Now()
+				assert.NoError(t, LevelControl(ctx).SetLevel(LogLevelDebugValue, changedBy))
+
+				entries := readLogEntries(t, output)
+				if !assert.Len(t, entries, 1) {
+					return
+				}
+				entry := entries[0]
+				assert.Equal(t, "info", entry["level"])
+				assert.Equal(t, "Log level changed from warn to debug by "+changedBy, entry["msg"])
+				data, _ := entry["data"].(map[string]interface{})
+				assert.Equal(t, "warn", data["previousLevel"])
+				assert.Equal(t, "debug", data["level"])
+				assert.Equal(t, changedBy, data["changedBy"])
+				assert.NotEmpty(t, data["changedAt"])
+
+				change, ok := LevelControl(ctx).LastChange()
+				assert.True(t, ok)
+				assert.Equal(t, LogLevelWarnValue, change.PreviousLevel)
+				assert.Equal(t, LogLevelDebugValue, change.Level)
+				assert.Equal(t, changedBy, change.ChangedBy)
+				assert.False(t, change.ChangedAt.Before(before))
+			})
+			t.Run("logs the level change at the new level if above info", func(t *testing.T) {
+				ctx, output := newRootContext(LogLevelInfoValue)
+				assert.NoError(t, LevelControl(ctx).SetLevel(LogLevelErrorValue, fake.Lorem().Word()))
+				entries := readLogEntries(t, output)
+				if assert.Len(t, entries, 1) {
+					assert.Equal(t, "error", entries[0]["level"])
+				}
+			})
+			t.Run("applies correlation id level to existing loggers", func(t *testing.T) {
+				ctx, output := newRootContext(LogLevelWarnValue)
+				correlationID := fake.UUID().V4()
+				targetCtx := DiagifyContext(context.Background(), ctx,
+					WithCorrelationID(correlationID), WithLogLevel(LogLevelErrorValue))
+				otherCtx := DiagifyContext(context.Background(), ctx)
+
+				changedBy := fake.Lorem().Word()
+				assert.NoError(t, LevelControl(ctx).SetCorrelationLevel(correlationID, LogLevelDebugValue, changedBy))
+				entries := readLogEntries(t, output)
+				if assert.Len(t, entries, 1) {
+					data, _ := entries[0]["data"].(map[string]interface{})
+					assert.Equal(t, correlationID, data["correlationId"])
+					assert.Equal(t, "debug", data["level"])
+					assert.Equal(t, changedBy, data["changedBy"])
+				}
+				output.Reset()
+
+				wantMessage := fake.Lorem().Sentence(3)
+				Log(targetCtx).Debug().Msg(wantMessage)
+				Log(DiagifyContext(context.Background(), targetCtx)).Debug().Msg(wantMessage)
+				Log(otherCtx).Debug().Msg(fake.Lorem().Sentence(3))
+				entries = readLogEntries(t, output)
+				if assert.Len(t, entries, 2) {
+					assert.Equal(t, wantMessage, entries[0]["msg"])
+					assert.Equal(t, wantMessage, entries[1]["msg"])
+				}
+
+				assert.Equal(t, []CorrelationLevel{{
+					CorrelationID: correlationID,
+					Level:         LogLevelDebugValue,
+					ChangedBy:     changedBy,
+					ChangedAt:     LevelControl(ctx).CorrelationLevels()[0].ChangedAt,
+				}}, LevelControl(ctx).CorrelationLevels())
+
+				assert.True(t, LevelControl(ctx).RemoveCorrelationLevel(correlationID, changedBy))
+				assert.False(t, LevelControl(ctx).RemoveCorrelationLevel(correlationID, changedBy))
+				assert.Empty(t, LevelControl(ctx).CorrelationLevels())
+				output.Reset()
+				Log(targetCtx).Debug().Msg(fake.Lorem().Sentence(3))
+				Log(targetCtx).Warn().Msg(fake.Lorem().Sentence(3))
+				assert.Empty(t, output.String())
+			})
+			t.Run("rejects invalid correlation level", func(t *testing.T) {
+				ctx, _ := newRootContext(LogLevelInfoValue)
+				control := LevelControl(ctx)
+				badLevel := LogLevel("bad-" + fake.Lorem().Word())
+				assert.EqualError(t,
+					control.SetCorrelationLevel(fake.UUID().V4(), badLevel, fake.Lorem().Word()),
+					"invalid log level "+badLevel.String())
+				assert.EqualError(t,
+					control.SetCorrelationLevel("", LogLevelDebugValue, fake.Lorem().Word()),
+					"correlation id is required")
+				assert.Empty(t, control.CorrelationLevels())
+			})
+			t.Run("rejects invalid level", func(t *testing.T) {
+				ctx, output := newRootContext(LogLevelInfoValue)
+				badLevel := LogLevel("bad-" + fake.Lorem().Word())
+				err := LevelControl(ctx).SetLevel(badLevel, fake.Lorem().Word())
+				assert.EqualError(t, err, "invalid log level "+badLevel.String())
+				assert.Equal(t, LogLevelInfoValue, LevelControl(ctx).Level())
+				assert.Empty(t, output.String())
+			})
+		})
+	}
+
+	t.Run("shiftLevel", func(t *testing.T) {
+		tests := []struct {
+			name  string
+			level LogLevel
+			delta int
+			want  LogLevel
+		}{
+			{name: "more verbose", level: LogLevelInfoValue, delta: -1, want: LogLevelDebugValue},
+			{name: "less verbose", level: LogLevelInfoValue, delta: 1, want: LogLevelWarnValue},
+			{name: "not past trace", level: LogLevelTraceValue, delta: -1, want: LogLevelTraceValue},
+			{name: "not past error", level: LogLevelErrorValue, delta: 1, want: LogLevelErrorValue},
+			{name: "unknown level as debug", level: LogLevel("bad-" + fake.Lorem().Word()), delta: 1, want: LogLevelInfoValue},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				control := newLogLevelControl(tt.level)
+				control.shiftLevel(tt.delta, fake.Lorem().Word())
+				assert.Equal(t, tt.want, control.Level())
+			})
+		}
+	})
+
+	t.Run("LevelControl panics if no control", func(t *testing.T) {
+		assert.PanicsWithError(t, "context does not contain a log level control", func() {
+			LevelControl(context.Background())
+		})
+	})
+}
+
+func TestLogLevel_MoreVerboseThan(t *testing.T) {
+	assert.True(t, LogLevelTraceValue.MoreVerboseThan(LogLevelDebugValue))
+	assert.True(t, LogLevelDebugValue.MoreVerboseThan(LogLevelErrorValue))
+	assert.False(t, LogLevelInfoValue.MoreVerboseThan(LogLevelInfoValue))
+	assert.False(t, LogLevelErrorValue.MoreVerboseThan(LogLevelWarnValue))
+	assert.False(t, LogLevel("bad-"+fake.Lorem().Word()).MoreVerboseThan(LogLevelErrorValue))
+	assert.False(t, LogLevelTraceValue.MoreVerboseThan(LogLevel("bad-"+fake.Lorem().Word())))
+}
@@ -0,0 +1,283 @@
+package diag
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+type logSamplerBurst struct {
+	burst      uint64
+	interval   time.Duration
+	thereafter uint64
+}
+
+// logSamplerWindow counts entries per message of a level within the burst interval
+type logSamplerWindow struct {
+	startedAt time.Time
+	counts    map[string]uint64
+}
+
+// LogSampler drops a part of log entries to reduce the log volume.
+// Use with rootContextParams.WithLogSampler, a sampler should be used with a single root context.
+type LogSampler struct {
+	ratios          map[LogLevel]float64
+	bursts          map[LogLevel]logSamplerBurst
+	byCorrelationID bool
+	summaryInterval time.Duration
+
+	mu            sync.Mutex
+	windows       map[LogLevel]*logSamplerWindow
+	dropped       map[LogLevel]uint64
+	totalDropped  map[LogLevel]uint64
+	lastSummaryAt time.Time
+	now           func() time.Time
+	random        func() float64
+
+	// summaryLogger is the root logger, entries written with it are not sampled
+	summaryLogger LevelLogger
+	levelControl  *LogLevelControl
+	summaryStop   chan struct{}
+	summaryDone   chan struct{}
+
+	// summaryOnWrite is set if there is no shutdown function to stop the background summary
+	summaryOnWrite bool
+}
+
+// LogSamplerOpt is a functional option for configuring the log sampler
+type LogSamplerOpt func(s *LogSampler)
+
+// WithSampleRatio keeps a given ratio (0..1) of entries of the level, all entries are kept by default
+func WithSampleRatio(level LogLevel, ratio float64) LogSamplerOpt {
+	return func(s *LogSampler) {
+		if ratio < 0 || ratio > 1 {
+			panic(fmt.Errorf("sample ratio of %s must be between 0 and 1, got %v", level, ratio))
+		}
+		s.ratios[level] = ratio
+	}
+}
+
+// WithSampleBurst keeps first burst entries with the same message of the level
+// per interval and then every thereafter entry. Msgf entries are grouped by the format.
+func WithSampleBurst(level LogLevel, burst int, interval time.Duration, thereafter int) LogSamplerOpt {
+	return func(s *LogSampler) {
+		if burst < 0 || interval <= 0 || thereafter < 0 {
+			panic(fmt.Errorf("invalid sample burst of %s: burst=%d, interval=%s, thereafter=%d", level, burst, interval, thereafter))
+		}
+		s.bursts[level] = logSamplerBurst{burst: uint64(burst), interval: interval, thereafter: uint64(thereafter)}
+	}
+}
+
+// WithSampleByCorrelationID makes the ratio decision once per correlation id,
+// so all the entries of a request are either kept or dropped
+func WithSampleByCorrelationID() LogSamplerOpt {
+	return func(s *LogSampler) {
+		s.byCorrelationID = true
+	}
+}
+
+// WithSampleSummary will log number of dropped entries per level once per interval.
+// The summary is logged in background and one last time by the root context shutdown function.
+// If the root context is created without the shutdown function (see RootContext), the summary
+// is logged with the first entry written after the interval elapses.
+func WithSampleSummary(interval time.Duration) LogSamplerOpt {
+	return func(s *LogSampler) {
+		s.summaryInterval = interval
+	}
+}
+
+// NewLogSampler creates a log sampler, all entries are kept if no options provided
+func NewLogSampler(opts ...LogSamplerOpt) *LogSampler {
+	s := &LogSampler{
+		ratios:       map[LogLevel]float64{},
+		bursts:       map[LogLevel]logSamplerBurst{},
+		windows:      map[LogLevel]*logSamplerWindow{},
+		dropped:      map[LogLevel]uint64{},
+		totalDropped: map[LogLevel]uint64{},
+		now:          time.Now,
+		random:       rand.Float64,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.lastSummaryAt = s.now()
+	return s
+}
+
+// Dropped returns number of entries dropped per level since the sampler was created
+func (s *LogSampler) Dropped() map[LogLevel]uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dropped := make(map[LogLevel]uint64, len(s.totalDropped))
+	for level, count := range s.totalDropped {
+		dropped[level] = count
+	}
+	return dropped
+}
+
+func (s *LogSampler) drop(level LogLevel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.countDropped(level)
+}
+
+// countDropped must be called with the mu locked
+func (s *LogSampler) countDropped(level LogLevel) {
+	s.dropped[level]++
+	s.totalDropped[level]++
+}
+
+// correlationSample maps the correlation id to a stable value in [0, 1) using FNV-1a hash
+func correlationSample(correlationID string) float64 {
+	hash := uint64(14695981039346656037)
+	for i := 0; i < len(correlationID); i++ {
+		hash ^= uint64(correlationID[i])
+		hash *= 1099511628211
+	}
+	return float64(hash>>11) / (1 << 53)
+}
+
+// keepLevel makes the ratio decision for a new entry
+func (s *LogSampler) keepLevel(level LogLevel, correlationID string) bool {
+	ratio, ok := s.ratios[level]
+	if !ok || ratio >= 1 {
+		return true
+	}
+	var sample float64
+	if s.byCorrelationID {
+		sample = correlationSample(correlationID)
+	} else {
+		sample = s.random()
+	}
+	if sample < ratio {
+		return true
+	}
+	s.drop(level)
+	return false
+}
+
+// keepMessage makes the burst decision for an entry with the message
+func (s *LogSampler) keepMessage(level LogLevel, msg string) bool {
+	burst, ok := s.bursts[level]
+	if !ok {
+		return true
+	}
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	window := s.windows[level]
+	if window == nil || now.Sub(window.startedAt) >= burst.interval {
+		window = &logSamplerWindow{startedAt: now, counts: map[string]uint64{}}
+		s.windows[level] = window
+	}
+	window.counts[msg]++
+	count := window.counts[msg]
+	if count <= burst.burst || (burst.thereafter > 0 && (count-burst.burst)%burst.thereafter == 0) {
+		return true
+	}
+	s.countDropped(level)
+	return false
+}
+
+// startSummary sets the summary logger once and starts logging the summary in background
+// if it can be stopped by the shutdown function
+func (s *LogSampler) startSummary(logger LevelLogger, levelControl *LogLevelControl, background bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.summaryLogger != nil {
+		return
+	}
+	s.summaryLogger = logger
+	s.levelControl = levelControl
+	if s.summaryInterval <= 0 {
+		return
+	}
+	if !background {
+		s.summaryOnWrite = true
+		return
+	}
+	s.summaryStop = make(chan struct{})
+	s.summaryDone = make(chan struct{})
+	go s.runSummary(s.summaryStop, s.summaryDone)
+}
+
+func (s *LogSampler) runSummary(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(s.summaryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.logSummary(false)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// stopSummary stops the background summary and logs the entries dropped since the last summary
+func (s *LogSampler) stopSummary(ctx context.Context) error {
+	s.mu.Lock()
+	stop, done := s.summaryStop, s.summaryDone
+	s.summaryStop, s.summaryDone = nil, nil
+	s.mu.Unlock()
+	if stop == nil {
+		return nil
+	}
+	close(stop)
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	s.logSummary(true)
+	return nil
+}
+
+// logSummaryOnWrite logs the summary with the written entries if it is not logged in background
+func (s *LogSampler) logSummaryOnWrite() {
+	if s.summaryInterval <= 0 {
+		return
+	}
+	s.mu.Lock()
+	onWrite := s.summaryOnWrite
+	s.mu.Unlock()
+	if onWrite {
+		s.logSummary(false)
+	}
+}
+
+// logSummary logs the dropped entries if the summary interval elapsed or if forced
+func (s *LogSampler) logSummary(force bool) {
+	now := s.now()
+
+	s.mu.Lock()
+	logger, levelControl := s.summaryLogger, s.levelControl
+	if logger == nil || len(s.dropped) == 0 || (!force && now.Sub(s.lastSummaryAt) < s.summaryInterval) {
+		s.mu.Unlock()
+		return
+	}
+	period := now.Sub(s.lastSummaryAt)
+	dropped := s.dropped
+	s.dropped = map[LogLevel]uint64{}
+	s.lastSummaryAt = now
+	s.mu.Unlock()
+
+	var total uint64
+	for _, count := range dropped {
+		total += count
+	}
+	logger.WithLevel(changeEntryLevel(levelControl.Level())).
+		WithDataFn(func(data MsgData) {
+			droppedData := logger.NewData()
+			for level, count := range dropped {
+				droppedData.Uint64(level.String(), count)
+			}
+			data.Dict("dropped", droppedData).
+				Float64("periodSec", period.Seconds())
+		}).
+		Msgf("Log sampling dropped %d entries", total)
+}
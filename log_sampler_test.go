@@ -0,0 +1,231 @@
+package diag
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogSampler(t *testing.T) {
+	newRootContext := func(sampler *LogSampler) (context.Context, *bytes.Buffer) {
+		output := &bytes.Buffer{}
+		ctx := RootContext(NewRootContextParams().
+			WithLogLevel(LogLevelDebugValue).
+			WithLogSampler(sampler).
+			WithOutput(output))
+		return ctx, output
+	}
+	messages := func(entries []map[string]interface{}) []interface{} {
+		result := make([]interface{}, len(entries))
+		for i, entry := range entries {
+			result[i] = entry["msg"]
+		}
+		return result
+	}
+
+	t.Run("should keep all entries by default", func(t *testing.T) {
+		ctx, output := newRootContext(NewLogSampler())
+		for i := 0; i < 5; i++ {
+			Log(ctx).Debug().Msg("debug")
+		}
+		assert.Len(t, readLogEntries(t, output), 5)
+	})
+
+	t.Run("should sample entries by level ratio", func(t *testing.T) {
+		sampler := NewLogSampler(
+			WithSampleRatio(LogLevelDebugValue, 0.5),
+			WithSampleRatio(LogLevelTraceValue, 0),
+		)
+		samples := []float64{0.2, 0.7, 0.4, 0.9}
+		sampler.random = func() float64 {
+			sample := samples[0]
+			samples = samples[1:]
+			return sample
+		}
+		ctx, output := newRootContext(sampler)
+		log := Log(ctx)
+		for i := 0; i < 4; i++ {
+			log.Debug().WithDataFn(func(data MsgData) {
+				data.Int("i", i)
+			}).Msgf("debug %d", i)
+		}
+		log.Info().Msg("info")
+		log.Trace().Msg("trace")
+
+		assert.Equal(t, []interface{}{"debug 0", "debug 2", "info"}, messages(readLogEntries(t, output)))
+		assert.Equal(t, map[LogLevel]uint64{LogLevelDebugValue: 2}, sampler.Dropped())
+	})
+
+	t.Run("should keep or drop all entries of a correlation id", func(t *testing.T) {
+		var keptID, droppedID string
+		for i := 0; keptID == "" || droppedID == ""; i++ {
+			id := fmt.Sprint("correlation-", i)
+			if correlationSample(id) < 0.5 {
+				keptID = id
+			} else {
+				droppedID = id
+			}
+		}
+
+		sampler := NewLogSampler(
+			WithSampleRatio(LogLevelInfoValue, 0.5),
+			WithSampleRatio(LogLevelDebugValue, 0.5),
+			WithSampleByCorrelationID(),
+		)
+		ctx, output := newRootContext(sampler)
+		keptCtx := DiagifyContext(context.Background(), ctx, WithCorrelationID(keptID))
+		droppedCtx := DiagifyContext(context.Background(), ctx, WithCorrelationID(droppedID))
+		for _, reqCtx := range []context.Context{keptCtx, droppedCtx} {
+			Log(reqCtx).Info().Msg("BEGIN REQ")
+			Log(reqCtx).Debug().Msg("processing")
+			Log(reqCtx).Info().Msg("END REQ")
+		}
+
+		entries := readLogEntries(t, output)
+		assert.Equal(t, []interface{}{"BEGIN REQ", "processing", "END REQ"}, messages(entries))
+		for _, entry := range entries {
+			assert.Equal(t, keptID, entry["context"].(map[string]interface{})["correlationId"])
+		}
+		assert.Equal(t, map[LogLevel]uint64{LogLevelInfoValue: 2, LogLevelDebugValue: 1}, sampler.Dropped())
+	})
+
+	t.Run("should keep burst then every thereafter entry per message", func(t *testing.T) {
+		now := time.Now()
+		sampler := NewLogSampler(WithSampleBurst(LogLevelInfoValue, 2, time.Minute, 3))
+		sampler.now = func() time.Time { return now }
+		ctx, output := newRootContext(sampler)
+		log := Log(ctx)
+		for i := 1; i <= 8; i++ {
+			log.Info().Msgf("request %d", i)
+		}
+		log.Info().Msg("other")
+		log.Warn().Msg("warn")
+		now = now.Add(time.Minute)
+		log.Info().Msgf("request %d", 9)
+
+		assert.Equal(t,
+			[]interface{}{"request 1", "request 2", "request 5", "request 8", "other", "warn", "request 9"},
+			messages(readLogEntries(t, output)),
+		)
+		assert.Equal(t, map[LogLevel]uint64{LogLevelInfoValue: 4}, sampler.Dropped())
+	})
+
+	t.Run("should log summary with entries without shutdown function", func(t *testing.T) {
+		now := time.Now()
+		sampler := NewLogSampler(
+			WithSampleRatio(LogLevelDebugValue, 0),
+			WithSampleBurst(LogLevelInfoValue, 1, time.Hour, 0),
+			WithSampleSummary(time.Minute),
+		)
+		sampler.now = func() time.Time { return now }
+		sampler.lastSummaryAt = now
+		ctx, output := newRootContext(sampler)
+		log := Log(ctx)
+		log.Debug().Msg("debug")
+		log.Info().Msg("info")
+		log.Info().Msg("info")
+		now = now.Add(30 * time.Second)
+		log.Info().Msg("info")
+		now = now.Add(30 * time.Second)
+		log.Warn().Msg("warn")
+		log.Warn().Msg("warn")
+
+		entries := readLogEntries(t, output)
+		assert.Equal(t,
+			[]interface{}{"info", "warn", "Log sampling dropped 3 entries", "warn"},
+			messages(entries),
+		)
+		if !assert.Len(t, entries, 4) {
+			return
+		}
+		assert.Equal(t, "info", entries[2]["level"])
+		assert.Equal(t, map[string]interface{}{
+			"dropped":   map[string]interface{}{"debug": float64(1), "info": float64(2)},
+			"periodSec": float64(60),
+		}, entries[2]["data"])
+		assert.Nil(t, sampler.summaryStop, "no background summary without shutdown function")
+	})
+
+	t.Run("should log summary in background", func(t *testing.T) {
+		sampler := NewLogSampler(
+			WithSampleRatio(LogLevelDebugValue, 0),
+			WithSampleSummary(10*time.Millisecond),
+		)
+		output := &syncBuffer{}
+		ctx, shutdown := RootContextWithShutdown(NewRootContextParams().
+			WithLogLevel(LogLevelDebugValue).
+			WithLogSampler(sampler).
+			WithOutput(output))
+		Log(ctx).Debug().Msg("debug")
+		assert.Eventually(t, func() bool {
+			return strings.Contains(output.String(), "Log sampling dropped 1 entries")
+		}, time.Second, time.Millisecond)
+		assert.NoError(t, shutdown(context.Background()))
+	})
+
+	t.Run("should log summary on shutdown", func(t *testing.T) {
+		sampler := NewLogSampler(
+			WithSampleRatio(LogLevelDebugValue, 0),
+			WithSampleSummary(time.Hour),
+		)
+		output := &syncBuffer{}
+		ctx, shutdown := RootContextWithShutdown(NewRootContextParams().
+			WithLogLevel(LogLevelDebugValue).
+			WithLogSampler(sampler).
+			WithOutput(output))
+		Log(ctx).Debug().Msg("debug")
+		Log(ctx).Debug().Msg("debug")
+		assert.NotContains(t, output.String(), "Log sampling dropped")
+		assert.NoError(t, shutdown(context.Background()))
+		assert.Contains(t, output.String(), "Log sampling dropped 2 entries")
+	})
+
+	t.Run("should not sample entries of invalid levels", func(t *testing.T) {
+		sampler := NewLogSampler(WithSampleRatio(LogLevelDebugValue, 0))
+		ctx, output := newRootContext(sampler)
+		Log(ctx).WithLevel(LogLevel("bad-" + fake.Lorem().Word())).Msg("invalid level")
+		Log(ctx).WithLevel(LogLevelDebugValue).Msg("debug")
+
+		logged := messages(readLogEntries(t, output))
+		assert.Contains(t, logged, "invalid level")
+		assert.NotContains(t, logged, "debug")
+		assert.Equal(t, map[LogLevel]uint64{LogLevelDebugValue: 1}, sampler.Dropped())
+	})
+
+	t.Run("should not count entries disabled by level", func(t *testing.T) {
+		sampler := NewLogSampler(WithSampleRatio(LogLevelTraceValue, 0))
+		ctx, output := newRootContext(sampler)
+		Log(ctx).Trace().Msg("trace")
+		assert.Empty(t, output.String())
+		assert.Empty(t, sampler.Dropped())
+	})
+
+	t.Run("should panic on invalid options", func(t *testing.T) {
+		assert.Panics(t, func() { NewLogSampler(WithSampleRatio(LogLevelInfoValue, 1.5)) })
+		assert.Panics(t, func() { NewLogSampler(WithSampleBurst(LogLevelInfoValue, 1, 0, 1)) })
+	})
+}
+
+// syncBuffer is a buffer safe to read while the summary is written in background
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
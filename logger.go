@@ -79,6 +79,9 @@ type MsgData interface {
 	IPPrefix(key string, pfx net.IPNet) MsgData
 	MACAddr(key string, ha net.HardwareAddr) MsgData
 
+	// Secret adds the value obfuscated as *obfuscated, length=N*
+	Secret(key string, value string) MsgData
+
 	// Creates nested dictionary under a given key
 	Dict(key string, data MsgData) MsgData
 
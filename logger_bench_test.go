@@ -7,13 +7,7 @@ import (
 	"github.com/gofrs/uuid"
 )
 
-func BenchmarkLogger(b *testing.B) {
-	ctx := RootContext(
-		NewRootContextParams().
-			WithOutput(io.Discard).
-			WithCorrelationID(uuid.Must(uuid.NewV4()).String()),
-	)
-	log := Log(ctx)
+func benchmarkLogger(b *testing.B, log LevelLogger) {
 	b.Run("WithDataFn", func(b *testing.B) {
 		for i := 0; i < b.N; i++ {
 			log.Debug().
@@ -38,3 +32,33 @@ func BenchmarkLogger(b *testing.B) {
 		}
 	})
 }
+
+func BenchmarkLogger(b *testing.B) {
+	ctx := RootContext(
+		NewRootContextParams().
+			WithOutput(io.Discard).
+			WithCorrelationID(uuid.Must(uuid.NewV4()).String()),
+	)
+	benchmarkLogger(b, Log(ctx))
+}
+
+func BenchmarkLoggerWithPIIScrubber(b *testing.B) {
+	ctx := RootContext(
+		NewRootContextParams().
+			WithOutput(io.Discard).
+			WithCorrelationID(uuid.Must(uuid.NewV4()).String()).
+			WithPIIScrubber(NewPIIScrubber()),
+	)
+	log := Log(ctx)
+	benchmarkLogger(b, log)
+	b.Run("WithPII", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			log.Debug().
+				WithDataFn(func(data MsgData) {
+					data.Str("email", "john.doe@example.com")
+					data.Str("card", "4111 1111 1111 1111")
+				}).
+				Msg("Fibonacci is everywhere")
+		}
+	})
+}
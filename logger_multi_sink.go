@@ -0,0 +1,414 @@
+package diag
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// logSink is an additional output of the root logger, see rootContextParams.WithSink
+type logSink struct {
+	out    io.Writer
+	level  LogLevel
+	pretty bool
+	cloudPlatformAdapter
+}
+
+// LogSinkOpt is a functional option for configuring an additional log output
+type LogSinkOpt func(s *logSink)
+
+// WithSinkLevel sets min level of entries written to the sink, all entries are written by default.
+// Entries below the logger level are not written to any sink.
+func WithSinkLevel(level LogLevel) LogSinkOpt {
+	return func(s *logSink) {
+		if _, ok := ParseLogLevel(level.String()); !ok {
+			panic(fmt.Errorf("invalid sink log level %s", level))
+		}
+		s.level = level
+	}
+}
+
+// WithSinkPretty makes the sink write entries in a human readable console format instead of JSON
+func WithSinkPretty(value bool) LogSinkOpt {
+	return func(s *logSink) {
+		s.pretty = value
+	}
+}
+
+// WithSinkGCPCloudAdapter will add GCP specific log entries such as severity to the sink entries
+func WithSinkGCPCloudAdapter(opts ...GCPCloudAdapterOpt) LogSinkOpt {
+	return func(s *logSink) {
+		s.cloudPlatformAdapter = newGCPAdapter(opts...)
+	}
+}
+
+// WithSinkAWSCloudAdapter will write level and timestamp of the sink entries in the format of AWS Lambda JSON logs
+func WithSinkAWSCloudAdapter() LogSinkOpt {
+	return func(s *logSink) {
+		s.cloudPlatformAdapter = awsAdapter{}
+	}
+}
+
+// WithSinkAzureCloudAdapter will add Application Insights severity level and operation id fields to the sink entries
+func WithSinkAzureCloudAdapter() LogSinkOpt {
+	return func(s *logSink) {
+		s.cloudPlatformAdapter = azureAdapter{}
+	}
+}
+
+// multiSinkLoggerFactory creates a logger per sink with the target factory
+// and writes each entry to all the sinks that accept the entry level
+type multiSinkLoggerFactory struct {
+	target LoggerFactory
+	sinks  []logSink
+}
+
+func (f multiSinkLoggerFactory) NewLogger(p *rootContextParams) LevelLogger {
+	targets := make([]multiSinkTarget, 0, len(f.sinks)+1)
+	targets = append(targets, multiSinkTarget{logger: f.target.NewLogger(p), level: LogLevelTraceValue})
+	for _, sink := range f.sinks {
+		sinkParams := *p
+		sinkParams.Out = sink.out
+		sinkParams.Pretty = sink.pretty
+		sinkParams.cloudPlatformAdapter = sink.cloudPlatformAdapter
+		targets = append(targets, multiSinkTarget{logger: f.target.NewLogger(&sinkParams), level: sink.level})
+	}
+	return &multiSinkLogger{targets: targets}
+}
+
+func (f multiSinkLoggerFactory) ChildLogger(logger LevelLogger, diagOpts DiagOpts) LevelLogger {
+	multiSinkParent, ok := logger.(*multiSinkLogger)
+	if !ok {
+		panic(fmt.Errorf("multiSinkLoggerFactory.ChildLogger: logger is not a *multiSinkLogger"))
+	}
+	targets := make([]multiSinkTarget, len(multiSinkParent.targets))
+	for i, target := range multiSinkParent.targets {
+		targets[i] = multiSinkTarget{logger: f.target.ChildLogger(target.logger, diagOpts), level: target.level}
+	}
+	return &multiSinkLogger{targets: targets}
+}
+
+func (f multiSinkLoggerFactory) Shutdown(ctx context.Context) error {
+	return shutdownLoggerFactory(ctx, f.target)
+}
+
+var _ LoggerFactory = multiSinkLoggerFactory{}
+var _ LoggerFactoryShutdowner = multiSinkLoggerFactory{}
+
+type multiSinkTarget struct {
+	logger LevelLogger
+	level  LogLevel
+}
+
+type multiSinkLogger struct {
+	targets []multiSinkTarget
+}
+
+var _ LevelLogger = &multiSinkLogger{}
+
+// enabled returns true if any sink accepts the level
+func (l *multiSinkLogger) enabled(level LogLevel) bool {
+	for _, target := range l.targets {
+		if level.severity() >= target.level.severity() && loggerEnabled(target.logger, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (l *multiSinkLogger) event(level LogLevel, newEvent func(logger LevelLogger) LogLevelEvent) LogLevelEvent {
+	if _, ok := ParseLogLevel(level.String()); !ok {
+		// invalid levels are logged as debug by the builtin loggers
+		level = LogLevelDebugValue
+	}
+	evt := &multiSinkEvent{logger: l, targets: make([]multiSinkEventTarget, 0, len(l.targets))}
+	for i, target := range l.targets {
+		if level.severity() >= target.level.severity() {
+			evt.targets = append(evt.targets, multiSinkEventTarget{index: i, event: newEvent(target.logger)})
+		}
+	}
+	return evt
+}
+
+func (l *multiSinkLogger) Error() LogLevelEvent {
+	return l.event(LogLevelErrorValue, func(logger LevelLogger) LogLevelEvent { return logger.Error() })
+}
+
+func (l *multiSinkLogger) Warn() LogLevelEvent {
+	return l.event(LogLevelWarnValue, func(logger LevelLogger) LogLevelEvent { return logger.Warn() })
+}
+
+func (l *multiSinkLogger) Info() LogLevelEvent {
+	return l.event(LogLevelInfoValue, func(logger LevelLogger) LogLevelEvent { return logger.Info() })
+}
+
+func (l *multiSinkLogger) Debug() LogLevelEvent {
+	return l.event(LogLevelDebugValue, func(logger LevelLogger) LogLevelEvent { return logger.Debug() })
+}
+
+func (l *multiSinkLogger) Trace() LogLevelEvent {
+	return l.event(LogLevelTraceValue, func(logger LevelLogger) LogLevelEvent { return logger.Trace() })
+}
+
+func (l *multiSinkLogger) WithLevel(level LogLevel) LogLevelEvent {
+	return l.event(level, func(logger LevelLogger) LogLevelEvent { return logger.WithLevel(level) })
+}
+
+func (l *multiSinkLogger) NewData() MsgData {
+	targets := make([]MsgData, len(l.targets))
+	for i, target := range l.targets {
+		targets[i] = target.logger.NewData()
+	}
+	return &multiSinkData{targets: targets}
+}
+
+type multiSinkEventTarget struct {
+	// index of the logger target the event was created with
+	index int
+	event LogLevelEvent
+}
+
+type multiSinkEvent struct {
+	logger  *multiSinkLogger
+	targets []multiSinkEventTarget
+}
+
+func (e *multiSinkEvent) enabled() bool {
+	for _, target := range e.targets {
+		if enabler, ok := target.event.(levelEventEnabler); !ok || enabler.enabled() {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *multiSinkEvent) each(apply func(target LogLevelEvent) LogLevelEvent) LogLevelEvent {
+	for i, target := range e.targets {
+		e.targets[i].event = apply(target.event)
+	}
+	return e
+}
+
+func (e *multiSinkEvent) WithError(err error) LogLevelEvent {
+	return e.each(func(target LogLevelEvent) LogLevelEvent { return target.WithError(err) })
+}
+
+func (e *multiSinkEvent) WithHTTPRequest(info HTTPRequestInfo) LogLevelEvent {
+	return e.each(func(target LogLevelEvent) LogLevelEvent { return WithHTTPRequest(target, info) })
+}
+
+// WithDataFn calls the dataFn once and adds the data to all the sink events
+func (e *multiSinkEvent) WithDataFn(dataFn func(data MsgData)) LogLevelEvent {
+	if !e.enabled() {
+		return e
+	}
+	data := e.logger.NewData()
+	dataFn(data)
+	return e.WithData(data)
+}
+
+func (e *multiSinkEvent) WithData(data MsgData) LogLevelEvent {
+	multiSinkData, ok := data.(*multiSinkData)
+	if !ok {
+		panic(fmt.Errorf("multiSinkEvent.WithData: data is not a *multiSinkData"))
+	}
+	for i, target := range e.targets {
+		e.targets[i].event = target.event.WithData(multiSinkData.targets[target.index])
+	}
+	return e
+}
+
+func (e *multiSinkEvent) Msg(msg string) {
+	for _, target := range e.targets {
+		target.event.Msg(msg)
+	}
+}
+
+func (e *multiSinkEvent) Msgf(format string, v ...interface{}) {
+	for _, target := range e.targets {
+		target.event.Msgf(format, v...)
+	}
+}
+
+// multiSinkData holds data of each sink logger
+type multiSinkData struct {
+	targets []MsgData
+}
+
+var _ MsgData = &multiSinkData{}
+
+func (d *multiSinkData) each(apply func(target MsgData) MsgData) MsgData {
+	for i, target := range d.targets {
+		d.targets[i] = apply(target)
+	}
+	return d
+}
+
+func (d *multiSinkData) Str(key string, value string) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Str(key, value) })
+}
+
+func (d *multiSinkData) Strs(key string, value []string) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Strs(key, value) })
+}
+
+func (d *multiSinkData) Stringer(key string, value fmt.Stringer) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Stringer(key, value) })
+}
+
+func (d *multiSinkData) Bytes(key string, value []byte) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Bytes(key, value) })
+}
+
+func (d *multiSinkData) Hex(key string, value []byte) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Hex(key, value) })
+}
+
+func (d *multiSinkData) RawJSON(key string, value []byte) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.RawJSON(key, value) })
+}
+
+func (d *multiSinkData) Bool(key string, value bool) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Bool(key, value) })
+}
+
+func (d *multiSinkData) Bools(key string, value []bool) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Bools(key, value) })
+}
+
+func (d *multiSinkData) Int(key string, value int) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Int(key, value) })
+}
+
+func (d *multiSinkData) Ints(key string, value []int) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Ints(key, value) })
+}
+
+func (d *multiSinkData) Int8(key string, value int8) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Int8(key, value) })
+}
+
+func (d *multiSinkData) Ints8(key string, value []int8) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Ints8(key, value) })
+}
+
+func (d *multiSinkData) Int16(key string, value int16) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Int16(key, value) })
+}
+
+func (d *multiSinkData) Ints16(key string, value []int16) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Ints16(key, value) })
+}
+
+func (d *multiSinkData) Int32(key string, value int32) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Int32(key, value) })
+}
+
+func (d *multiSinkData) Ints32(key string, value []int32) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Ints32(key, value) })
+}
+
+func (d *multiSinkData) Int64(key string, value int64) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Int64(key, value) })
+}
+
+func (d *multiSinkData) Ints64(key string, value []int64) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Ints64(key, value) })
+}
+
+func (d *multiSinkData) Uint(key string, value uint) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Uint(key, value) })
+}
+
+func (d *multiSinkData) Uints(key string, value []uint) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Uints(key, value) })
+}
+
+func (d *multiSinkData) Uint8(key string, value uint8) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Uint8(key, value) })
+}
+
+func (d *multiSinkData) Uints8(key string, value []uint8) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Uints8(key, value) })
+}
+
+func (d *multiSinkData) Uint16(key string, value uint16) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Uint16(key, value) })
+}
+
+func (d *multiSinkData) Uints16(key string, value []uint16) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Uints16(key, value) })
+}
+
+func (d *multiSinkData) Uint32(key string, value uint32) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Uint32(key, value) })
+}
+
+func (d *multiSinkData) Uints32(key string, value []uint32) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Uints32(key, value) })
+}
+
+func (d *multiSinkData) Uint64(key string, value uint64) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Uint64(key, value) })
+}
+
+func (d *multiSinkData) Uints64(key string, value []uint64) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Uints64(key, value) })
+}
+
+func (d *multiSinkData) Float32(key string, value float32) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Float32(key, value) })
+}
+
+func (d *multiSinkData) Floats32(key string, value []float32) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Floats32(key, value) })
+}
+
+func (d *multiSinkData) Float64(key string, value float64) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Float64(key, value) })
+}
+
+func (d *multiSinkData) Floats64(key string, value []float64) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Floats64(key, value) })
+}
+
+func (d *multiSinkData) Time(key string, value time.Time) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Time(key, value) })
+}
+
+func (d *multiSinkData) Times(key string, value []time.Time) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Times(key, value) })
+}
+
+func (d *multiSinkData) IPAddr(key string, value net.IP) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.IPAddr(key, value) })
+}
+
+func (d *multiSinkData) IPPrefix(key string, value net.IPNet) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.IPPrefix(key, value) })
+}
+
+func (d *multiSinkData) MACAddr(key string, value net.HardwareAddr) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.MACAddr(key, value) })
+}
+
+func (d *multiSinkData) Secret(key string, value string) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Secret(key, value) })
+}
+
+func (d *multiSinkData) Dict(key string, data MsgData) MsgData {
+	multiSinkData, ok := data.(*multiSinkData)
+	if !ok {
+		panic(fmt.Errorf("MsgData instance is not multi sink data"))
+	}
+	for i, target := range d.targets {
+		d.targets[i] = target.Dict(key, multiSinkData.targets[i])
+	}
+	return d
+}
+
+func (d *multiSinkData) Interface(key string, value interface{}) MsgData {
+	return d.each(func(target MsgData) MsgData { return target.Interface(key, value) })
+}
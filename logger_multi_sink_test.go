@@ -0,0 +1,129 @@
+package diag
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultiSinkLogger(t *testing.T) {
+	factories := map[string]LoggerFactory{
+		"zerolog": zerologLoggerFactory{},
+		"slog":    NewSlogLoggerFactory(),
+	}
+	messages := func(entries []map[string]interface{}) []interface{} {
+		result := make([]interface{}, len(entries))
+		for i, entry := range entries {
+			result[i] = entry["msg"]
+		}
+		return result
+	}
+
+	for name, factory := range factories {
+		factory := factory
+		t.Run(name, func(t *testing.T) {
+			t.Run("should write entries to sinks by level", func(t *testing.T) {
+				primary, errorsSink := &bytes.Buffer{}, &bytes.Buffer{}
+				ctx := RootContext(NewRootContextParams().
+					WithLoggerFactory(factory).
+					WithLogLevel(LogLevelDebugValue).
+					WithOutput(primary).
+					WithSink(errorsSink, WithSinkLevel(LogLevelErrorValue), WithSinkGCPCloudAdapter()))
+				log := Log(ctx)
+				log.Trace().Msg("trace")
+				log.Debug().Msg("debug")
+				log.Warn().Msgf("warn %d", 1)
+				log.Error().WithError(errors.New("failed")).Msg("error")
+
+				assert.Equal(t, []interface{}{"debug", "warn 1", "error"}, messages(readLogEntries(t, primary)))
+				entries := readLogEntries(t, errorsSink)
+				if assert.Len(t, entries, 1) {
+					assert.Equal(t, "error", entries[0]["msg"])
+					assert.Equal(t, "failed", entries[0]["error"])
+					assert.Equal(t, "ERROR", entries[0]["severity"])
+				}
+				assert.NotContains(t, readLogEntries(t, primary)[2], "severity")
+			})
+
+			t.Run("should write pretty sink", func(t *testing.T) {
+				primary, prettySink := &bytes.Buffer{}, &bytes.Buffer{}
+				ctx := RootContext(NewRootContextParams().
+					WithLoggerFactory(factory).
+					WithOutput(primary).
+					WithSink(prettySink, WithSinkPretty(true)))
+				Log(ctx).Info().Msg("pretty message")
+				assert.Len(t, readLogEntries(t, primary), 1)
+				assert.Contains(t, prettySink.String(), "pretty message")
+				assert.False(t, strings.HasPrefix(prettySink.String(), "{"), "should not be JSON")
+			})
+
+			t.Run("should add data to all sinks", func(t *testing.T) {
+				primary, sink := &bytes.Buffer{}, &bytes.Buffer{}
+				ctx := RootContext(NewRootContextParams().
+					WithLoggerFactory(factory).
+					WithOutput(primary).
+					WithSink(sink))
+				log := DiagifyContext(context.Background(), ctx, WithCorrelationID("child"))
+				calls := 0
+				nested := Log(log).NewData().Str("nestedKey", "nested value")
+				Log(log).Info().WithDataFn(func(data MsgData) {
+					calls++
+					data.Int("n", 1).Dict("nested", nested)
+				}).Msg("with data fn")
+				Log(log).Info().WithData(Log(log).NewData().Str("key", "value")).Msg("with data")
+				assert.Equal(t, 1, calls)
+
+				for _, output := range []*bytes.Buffer{primary, sink} {
+					entries := readLogEntries(t, output)
+					if !assert.Len(t, entries, 2) {
+						continue
+					}
+					assert.Equal(t, "child", entries[0]["context"].(map[string]interface{})["correlationId"])
+					assert.Equal(t, map[string]interface{}{
+						"n":      float64(1),
+						"nested": map[string]interface{}{"nestedKey": "nested value"},
+					}, entries[0]["data"])
+					assert.Equal(t, map[string]interface{}{"key": "value"}, entries[1]["data"])
+				}
+			})
+
+			t.Run("should flush sinks on shutdown", func(t *testing.T) {
+				output := &bytes.Buffer{}
+				sink := bufio.NewWriter(output)
+				ctx, shutdown := RootContextWithShutdown(NewRootContextParams().
+					WithLoggerFactory(factory).
+					WithOutput(&bytes.Buffer{}).
+					WithSink(sink, WithSinkLevel(LogLevelWarnValue)))
+				Log(ctx).Warn().Msg("warn")
+				assert.Empty(t, output.String())
+				assert.NoError(t, shutdown(context.Background()))
+				assert.Equal(t, []interface{}{"warn"}, messages(readLogEntries(t, output)))
+			})
+
+			t.Run("should panic on data of other loggers", func(t *testing.T) {
+				ctx := RootContext(NewRootContextParams().
+					WithLoggerFactory(factory).
+					WithOutput(&bytes.Buffer{}).
+					WithSink(&bytes.Buffer{}))
+				foreignData := factory.NewLogger(NewRootContextParams()).NewData()
+				assert.PanicsWithError(t, "multiSinkEvent.WithData: data is not a *multiSinkData", func() {
+					Log(ctx).Info().WithData(foreignData).Msg("info")
+				})
+				assert.PanicsWithError(t, "MsgData instance is not multi sink data", func() {
+					Log(ctx).NewData().Dict("nested", foreignData)
+				})
+			})
+		})
+	}
+
+	t.Run("should panic on invalid sink level", func(t *testing.T) {
+		assert.Panics(t, func() {
+			NewRootContextParams().WithSink(&bytes.Buffer{}, WithSinkLevel("bad-"+LogLevel(fake.Lorem().Word())))
+		})
+	})
+}
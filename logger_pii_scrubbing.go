@@ -0,0 +1,376 @@
+package diag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+)
+
+// piiScrubbingLoggerFactory wraps loggers of the target factory
+// to scrub messages, errors and string data before they are written
+type piiScrubbingLoggerFactory struct {
+	target   LoggerFactory
+	scrubber *PIIScrubber
+}
+
+func (f piiScrubbingLoggerFactory) NewLogger(p *rootContextParams) LevelLogger {
+	return &piiScrubbingLogger{target: f.target.NewLogger(p), scrubber: f.scrubber}
+}
+
+func (f piiScrubbingLoggerFactory) ChildLogger(logger LevelLogger, diagOpts DiagOpts) LevelLogger {
+	scrubbingLogger, ok := logger.(*piiScrubbingLogger)
+	if !ok {
+		panic(fmt.Errorf("piiScrubbingLoggerFactory.ChildLogger: logger is not a *piiScrubbingLogger"))
+	}
+	return &piiScrubbingLogger{
+		target:   f.target.ChildLogger(scrubbingLogger.target, diagOpts),
+		scrubber: f.scrubber,
+	}
+}
+
+func (f piiScrubbingLoggerFactory) Shutdown(ctx context.Context) error {
+	return shutdownLoggerFactory(ctx, f.target)
+}
+
+var _ LoggerFactory = piiScrubbingLoggerFactory{}
+var _ LoggerFactoryShutdowner = piiScrubbingLoggerFactory{}
+
+type piiScrubbingLogger struct {
+	target   LevelLogger
+	scrubber *PIIScrubber
+}
+
+var _ LevelLogger = &piiScrubbingLogger{}
+
+func (l *piiScrubbingLogger) enabled(level LogLevel) bool {
+	return loggerEnabled(l.target, level)
+}
+
+func (l *piiScrubbingLogger) event(target LogLevelEvent) LogLevelEvent {
+	return &piiScrubbingEvent{target: target, scrubber: l.scrubber}
+}
+
+func (l *piiScrubbingLogger) Error() LogLevelEvent {
+	return l.event(l.target.Error())
+}
+
+func (l *piiScrubbingLogger) Warn() LogLevelEvent {
+	return l.event(l.target.Warn())
+}
+
+func (l *piiScrubbingLogger) Info() LogLevelEvent {
+	return l.event(l.target.Info())
+}
+
+func (l *piiScrubbingLogger) Debug() LogLevelEvent {
+	return l.event(l.target.Debug())
+}
+
+func (l *piiScrubbingLogger) Trace() LogLevelEvent {
+	return l.event(l.target.Trace())
+}
+
+func (l *piiScrubbingLogger) WithLevel(level LogLevel) LogLevelEvent {
+	return l.event(l.target.WithLevel(level))
+}
+
+func (l *piiScrubbingLogger) NewData() MsgData {
+	return &piiScrubbingData{target: l.target.NewData(), scrubber: l.scrubber}
+}
+
+// levelEventEnabler is implemented by events of the builtin loggers
+// to skip scrubbing of the events that will not be written
+type levelEventEnabler interface {
+	enabled() bool
+}
+
+type piiScrubbingEvent struct {
+	target   LogLevelEvent
+	scrubber *PIIScrubber
+}
+
+func (e *piiScrubbingEvent) enabled() bool {
+	enabler, ok := e.target.(levelEventEnabler)
+	return !ok || enabler.enabled()
+}
+
+// scrubbedError keeps the original error for errors.Is/As checks
+type scrubbedError struct {
+	msg string
+	err error
+}
+
+func (e scrubbedError) Error() string {
+	return e.msg
+}
+
+func (e scrubbedError) Unwrap() error {
+	return e.err
+}
+
+func (e *piiScrubbingEvent) WithError(err error) LogLevelEvent {
+	if err != nil && e.enabled() {
+		if msg := e.scrubber.Scrub(err.Error()); msg != err.Error() {
+			err = scrubbedError{msg: msg, err: err}
+		}
+	}
+	e.target = e.target.WithError(err)
+	return e
+}
+
+// WithHTTPRequest scrubs the url and referer since they may contain PII in the query
+func (e *piiScrubbingEvent) WithHTTPRequest(info HTTPRequestInfo) LogLevelEvent {
+	if e.enabled() {
+		info.URL = e.scrubber.Scrub(info.URL)
+		info.Referer = e.scrubber.Scrub(info.Referer)
+	}
+	e.target = WithHTTPRequest(e.target, info)
+	return e
+}
+
+func (e *piiScrubbingEvent) WithDataFn(dataFn func(data MsgData)) LogLevelEvent {
+	if !e.enabled() {
+		return e
+	}
+	e.target = e.target.WithDataFn(func(data MsgData) {
+		dataFn(&piiScrubbingData{target: data, scrubber: e.scrubber})
+	})
+	return e
+}
+
+func (e *piiScrubbingEvent) WithData(data MsgData) LogLevelEvent {
+	e.target = e.target.WithData(unwrapPIIScrubbingData(data))
+	return e
+}
+
+func (e *piiScrubbingEvent) Msg(msg string) {
+	if !e.enabled() {
+		return
+	}
+	e.target.Msg(e.scrubber.Scrub(msg))
+}
+
+func (e *piiScrubbingEvent) Msgf(format string, v ...interface{}) {
+	if !e.enabled() {
+		return
+	}
+	e.target.Msg(e.scrubber.Scrub(fmt.Sprintf(format, v...)))
+}
+
+type piiScrubbingData struct {
+	target   MsgData
+	scrubber *PIIScrubber
+}
+
+var _ MsgData = &piiScrubbingData{}
+
+func unwrapPIIScrubbingData(data MsgData) MsgData {
+	if scrubbingData, ok := data.(*piiScrubbingData); ok {
+		return scrubbingData.target
+	}
+	return data
+}
+
+func (d *piiScrubbingData) set(target MsgData) MsgData {
+	d.target = target
+	return d
+}
+
+func (d *piiScrubbingData) Str(key string, value string) MsgData {
+	return d.set(d.target.Str(key, d.scrubber.Scrub(value)))
+}
+
+func (d *piiScrubbingData) Strs(key string, value []string) MsgData {
+	scrubbed := make([]string, len(value))
+	for i, v := range value {
+		scrubbed[i] = d.scrubber.Scrub(v)
+	}
+	return d.set(d.target.Strs(key, scrubbed))
+}
+
+func (d *piiScrubbingData) Stringer(key string, value fmt.Stringer) MsgData {
+	if value == nil {
+		return d.set(d.target.Stringer(key, value))
+	}
+	str := value.String()
+	if scrubbed := d.scrubber.Scrub(str); scrubbed != str {
+		return d.set(d.target.Str(key, scrubbed))
+	}
+	return d.set(d.target.Stringer(key, value))
+}
+
+func (d *piiScrubbingData) Bytes(key string, value []byte) MsgData {
+	str := string(value)
+	if scrubbed := d.scrubber.Scrub(str); scrubbed != str {
+		return d.set(d.target.Bytes(key, []byte(scrubbed)))
+	}
+	return d.set(d.target.Bytes(key, value))
+}
+
+func (d *piiScrubbingData) Hex(key string, value []byte) MsgData {
+	return d.set(d.target.Hex(key, value))
+}
+
+func (d *piiScrubbingData) RawJSON(key string, value []byte) MsgData {
+	scrubbed, _ := d.scrubber.scrubJSON(value)
+	return d.set(d.target.RawJSON(key, scrubbed))
+}
+
+func (d *piiScrubbingData) Bool(key string, value bool) MsgData {
+	return d.set(d.target.Bool(key, value))
+}
+
+func (d *piiScrubbingData) Bools(key string, value []bool) MsgData {
+	return d.set(d.target.Bools(key, value))
+}
+
+func (d *piiScrubbingData) Int(key string, value int) MsgData {
+	return d.set(d.target.Int(key, value))
+}
+
+func (d *piiScrubbingData) Ints(key string, value []int) MsgData {
+	return d.set(d.target.Ints(key, value))
+}
+
+func (d *piiScrubbingData) Int8(key string, value int8) MsgData {
+	return d.set(d.target.Int8(key, value))
+}
+
+func (d *piiScrubbingData) Ints8(key string, value []int8) MsgData {
+	return d.set(d.target.Ints8(key, value))
+}
+
+func (d *piiScrubbingData) Int16(key string, value int16) MsgData {
+	return d.set(d.target.Int16(key, value))
+}
+
+func (d *piiScrubbingData) Ints16(key string, value []int16) MsgData {
+	return d.set(d.target.Ints16(key, value))
+}
+
+func (d *piiScrubbingData) Int32(key string, value int32) MsgData {
+	return d.set(d.target.Int32(key, value))
+}
+
+func (d *piiScrubbingData) Ints32(key string, value []int32) MsgData {
+	return d.set(d.target.Ints32(key, value))
+}
+
+func (d *piiScrubbingData) Int64(key string, value int64) MsgData {
+	return d.set(d.target.Int64(key, value))
+}
+
+func (d *piiScrubbingData) Ints64(key string, value []int64) MsgData {
+	return d.set(d.target.Ints64(key, value))
+}
+
+func (d *piiScrubbingData) Uint(key string, value uint) MsgData {
+	return d.set(d.target.Uint(key, value))
+}
+
+func (d *piiScrubbingData) Uints(key string, value []uint) MsgData {
+	return d.set(d.target.Uints(key, value))
+}
+
+func (d *piiScrubbingData) Uint8(key string, value uint8) MsgData {
+	return d.set(d.target.Uint8(key, value))
+}
+
+func (d *piiScrubbingData) Uints8(key string, value []uint8) MsgData {
+	return d.set(d.target.Uints8(key, value))
+}
+
+func (d *piiScrubbingData) Uint16(key string, value uint16) MsgData {
+	return d.set(d.target.Uint16(key, value))
+}
+
+func (d *piiScrubbingData) Uints16(key string, value []uint16) MsgData {
+	return d.set(d.target.Uints16(key, value))
+}
+
+func (d *piiScrubbingData) Uint32(key string, value uint32) MsgData {
+	return d.set(d.target.Uint32(key, value))
+}
+
+func (d *piiScrubbingData) Uints32(key string, value []uint32) MsgData {
+	return d.set(d.target.Uints32(key, value))
+}
+
+func (d *piiScrubbingData) Uint64(key string, value uint64) MsgData {
+	return d.set(d.target.Uint64(key, value))
+}
+
+func (d *piiScrubbingData) Uints64(key string, value []uint64) MsgData {
+	return d.set(d.target.Uints64(key, value))
+}
+
+func (d *piiScrubbingData) Float32(key string, value float32) MsgData {
+	return d.set(d.target.Float32(key, value))
+}
+
+func (d *piiScrubbingData) Floats32(key string, value []float32) MsgData {
+	return d.set(d.target.Floats32(key, value))
+}
+
+func (d *piiScrubbingData) Float64(key string, value float64) MsgData {
+	return d.set(d.target.Float64(key, value))
+}
+
+func (d *piiScrubbingData) Floats64(key string, value []float64) MsgData {
+	return d.set(d.target.Floats64(key, value))
+}
+
+func (d *piiScrubbingData) Time(key string, value time.Time) MsgData {
+	return d.set(d.target.Time(key, value))
+}
+
+func (d *piiScrubbingData) Times(key string, value []time.Time) MsgData {
+	return d.set(d.target.Times(key, value))
+}
+
+func (d *piiScrubbingData) IPAddr(key string, value net.IP) MsgData {
+	return d.set(d.target.IPAddr(key, value))
+}
+
+func (d *piiScrubbingData) IPPrefix(key string, value net.IPNet) MsgData {
+	return d.set(d.target.IPPrefix(key, value))
+}
+
+func (d *piiScrubbingData) MACAddr(key string, value net.HardwareAddr) MsgData {
+	return d.set(d.target.MACAddr(key, value))
+}
+
+func (d *piiScrubbingData) Secret(key string, value string) MsgData {
+	return d.set(d.target.Secret(key, value))
+}
+
+func (d *piiScrubbingData) Dict(key string, data MsgData) MsgData {
+	return d.set(d.target.Dict(key, unwrapPIIScrubbingData(data)))
+}
+
+// Interface values are scrubbed via their JSON representation, scrubbed values are written
+// as raw JSON. Scalars are written as is, string maps and slices (e.g. logged headers)
+// are scrubbed without the JSON round trip.
+func (d *piiScrubbingData) Interface(key string, value interface{}) MsgData {
+	switch v := value.(type) {
+	case string:
+		return d.Str(key, v)
+	case nil, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64,
+		float32, float64, time.Time, time.Duration:
+		return d.set(d.target.Interface(key, value))
+	case map[string]string:
+		return d.set(d.target.Interface(key, d.scrubber.scrubStringMap(v)))
+	case []string:
+		return d.set(d.target.Interface(key, d.scrubber.scrubStrings(v)))
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return d.set(d.target.Interface(key, value))
+	}
+	if scrubbed, changed := d.scrubber.scrubJSON(data); changed {
+		return d.set(d.target.RawJSON(key, scrubbed))
+	}
+	return d.set(d.target.Interface(key, value))
+}
@@ -0,0 +1,171 @@
+package diag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// samplingLoggerFactory wraps loggers of the target factory to drop entries
+// according to the sampler decisions
+type samplingLoggerFactory struct {
+	target  LoggerFactory
+	sampler *LogSampler
+}
+
+func (f samplingLoggerFactory) NewLogger(p *rootContextParams) LevelLogger {
+	logger := f.target.NewLogger(p)
+	levelControl := p.levelControl
+	if levelControl == nil {
+		levelControl = newLogLevelControl(p.LogLevel)
+	}
+	f.sampler.startSummary(logger, levelControl, p.hasShutdown)
+	return &samplingLogger{target: logger, sampler: f.sampler, correlationID: p.DiagData.CorrelationID}
+}
+
+func (f samplingLoggerFactory) ChildLogger(logger LevelLogger, diagOpts DiagOpts) LevelLogger {
+	samplingParent, ok := logger.(*samplingLogger)
+	if !ok {
+		panic(fmt.Errorf("samplingLoggerFactory.ChildLogger: logger is not a *samplingLogger"))
+	}
+	return &samplingLogger{
+		target:        f.target.ChildLogger(samplingParent.target, diagOpts),
+		sampler:       f.sampler,
+		correlationID: diagOpts.DiagData.CorrelationID,
+	}
+}
+
+// Shutdown logs the last sampling summary before the target factory is shut down
+func (f samplingLoggerFactory) Shutdown(ctx context.Context) error {
+	return errors.Join(f.sampler.stopSummary(ctx), shutdownLoggerFactory(ctx, f.target))
+}
+
+var _ LoggerFactory = samplingLoggerFactory{}
+var _ LoggerFactoryShutdowner = samplingLoggerFactory{}
+
+type samplingLogger struct {
+	target        LevelLogger
+	sampler       *LogSampler
+	correlationID string
+}
+
+var _ LevelLogger = &samplingLogger{}
+
+// enabled ignores the sampler since the sampling decision is made per entry
+func (l *samplingLogger) enabled(level LogLevel) bool {
+	return loggerEnabled(l.target, level)
+}
+
+// event returns a disabled event if the entry is dropped by the level ratio
+func (l *samplingLogger) event(level LogLevel, newTarget func() LogLevelEvent) LogLevelEvent {
+	target := newTarget()
+	if enabler, ok := target.(levelEventEnabler); ok && !enabler.enabled() {
+		return target
+	}
+	if !l.sampler.keepLevel(level, l.correlationID) {
+		return droppedLogLevelEvent{}
+	}
+	return &samplingEvent{target: target, level: level, sampler: l.sampler}
+}
+
+func (l *samplingLogger) Error() LogLevelEvent {
+	return l.event(LogLevelErrorValue, l.target.Error)
+}
+
+func (l *samplingLogger) Warn() LogLevelEvent {
+	return l.event(LogLevelWarnValue, l.target.Warn)
+}
+
+func (l *samplingLogger) Info() LogLevelEvent {
+	return l.event(LogLevelInfoValue, l.target.Info)
+}
+
+func (l *samplingLogger) Debug() LogLevelEvent {
+	return l.event(LogLevelDebugValue, l.target.Debug)
+}
+
+func (l *samplingLogger) Trace() LogLevelEvent {
+	return l.event(LogLevelTraceValue, l.target.Trace)
+}
+
+// WithLevel does not sample entries of invalid levels since the target logs them at its own fallback level
+func (l *samplingLogger) WithLevel(level LogLevel) LogLevelEvent {
+	sampleLevel, ok := ParseLogLevel(level.String())
+	if !ok {
+		return l.target.WithLevel(level)
+	}
+	return l.event(sampleLevel, func() LogLevelEvent {
+		return l.target.WithLevel(level)
+	})
+}
+
+func (l *samplingLogger) NewData() MsgData {
+	return l.target.NewData()
+}
+
+// droppedLogLevelEvent is an event dropped by the sampler, it does nothing
+type droppedLogLevelEvent struct{}
+
+func (e droppedLogLevelEvent) enabled() bool {
+	return false
+}
+
+func (e droppedLogLevelEvent) WithError(error) LogLevelEvent {
+	return e
+}
+
+func (e droppedLogLevelEvent) WithDataFn(func(data MsgData)) LogLevelEvent {
+	return e
+}
+
+func (e droppedLogLevelEvent) WithData(MsgData) LogLevelEvent {
+	return e
+}
+
+func (droppedLogLevelEvent) Msg(string) {}
+
+func (droppedLogLevelEvent) Msgf(string, ...interface{}) {}
+
+type samplingEvent struct {
+	target  LogLevelEvent
+	level   LogLevel
+	sampler *LogSampler
+}
+
+func (e *samplingEvent) enabled() bool {
+	return true
+}
+
+func (e *samplingEvent) WithError(err error) LogLevelEvent {
+	e.target = e.target.WithError(err)
+	return e
+}
+
+func (e *samplingEvent) WithHTTPRequest(info HTTPRequestInfo) LogLevelEvent {
+	e.target = WithHTTPRequest(e.target, info)
+	return e
+}
+
+func (e *samplingEvent) WithDataFn(dataFn func(data MsgData)) LogLevelEvent {
+	e.target = e.target.WithDataFn(dataFn)
+	return e
+}
+
+func (e *samplingEvent) WithData(data MsgData) LogLevelEvent {
+	e.target = e.target.WithData(data)
+	return e
+}
+
+func (e *samplingEvent) Msg(msg string) {
+	if e.sampler.keepMessage(e.level, msg) {
+		e.target.Msg(msg)
+	}
+	e.sampler.logSummaryOnWrite()
+}
+
+func (e *samplingEvent) Msgf(format string, v ...interface{}) {
+	if e.sampler.keepMessage(e.level, format) {
+		e.target.Msgf(format, v...)
+	}
+	e.sampler.logSummaryOnWrite()
+}
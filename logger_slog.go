@@ -0,0 +1,386 @@
+package diag
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// SlogLevelTrace is a slog level used to represent LogLevelTraceValue
+// slog does not define a trace level so it is placed below slog.LevelDebug
+const SlogLevelTrace = slog.LevelDebug - 4
+
+// SlogLevel returns a slog level that corresponds to the log level
+// Unknown levels are mapped to slog.LevelDebug
+func (l LogLevel) SlogLevel() slog.Level {
+	switch l {
+	case LogLevelTraceValue:
+		return SlogLevelTrace
+	case LogLevelDebugValue:
+		return slog.LevelDebug
+	case LogLevelInfoValue:
+		return slog.LevelInfo
+	case LogLevelWarnValue:
+		return slog.LevelWarn
+	case LogLevelErrorValue:
+		return slog.LevelError
+	default:
+		return slog.LevelDebug
+	}
+}
+
+// LogLevelFromSlog returns a log level that corresponds to the slog level
+// Levels in between of the standard slog levels are rounded down
+func LogLevelFromSlog(level slog.Level) LogLevel {
+	switch {
+	case level < slog.LevelDebug:
+		return LogLevelTraceValue
+	case level < slog.LevelInfo:
+		return LogLevelDebugValue
+	case level < slog.LevelWarn:
+		return LogLevelInfoValue
+	case level < slog.LevelError:
+		return LogLevelWarnValue
+	default:
+		return LogLevelErrorValue
+	}
+}
+
+// replaceSlogBuiltinAttr makes the builtin slog handlers output
+// the level in the same format as the zerolog based logger
+func replaceSlogBuiltinAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && a.Key == slog.LevelKey {
+		if level, ok := a.Value.Any().(slog.Level); ok {
+			return slog.String(slog.LevelKey, LogLevelFromSlog(level).String())
+		}
+	}
+	return a
+}
+
+// dropSlogBuiltinAttr omits the builtin level and time, used if the cloud adapter writes them.
+// Attributes with the same keys written by the adapter are strings so they are kept.
+func dropSlogBuiltinAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) > 0 {
+		return a
+	}
+	if _, isLevel := a.Value.Any().(slog.Level); isLevel && a.Key == slog.LevelKey {
+		return slog.Attr{}
+	}
+	if a.Key == slog.TimeKey && a.Value.Kind() == slog.KindTime {
+		return slog.Attr{}
+	}
+	return a
+}
+
+func newSlogContextAttr(diagData ContextDiagData, adapter cloudPlatformAdapter) slog.Attr {
+	attrs := make([]slog.Attr, 0, len(diagData.Entries)+5)
+	if !writesCorrelationID(adapter, diagData) {
+		attrs = append(attrs, slog.String("correlationId", diagData.CorrelationID))
+	}
+	if trace := diagData.Trace; trace.IsValid() {
+		attrs = append(attrs,
+			slog.String("traceId", trace.TraceID),
+			slog.String("spanId", trace.SpanID),
+			slog.Bool("traceSampled", trace.Sampled),
+		)
+		if trace.ParentSpanID != "" {
+			attrs = append(attrs, slog.String("parentSpanId", trace.ParentSpanID))
+		}
+	}
+	for k, v := range diagData.Entries {
+		attrs = append(attrs, slog.String(k, v))
+	}
+	return slog.Attr{Key: "context", Value: slog.GroupValue(attrs...)}
+}
+
+type slogLoggerFactoryCfg struct {
+	handler slog.Handler
+}
+
+// SlogLoggerFactoryOpt is a functional option for configuring the slog logger factory
+type SlogLoggerFactoryOpt func(cfg *slogLoggerFactoryCfg)
+
+// WithSlogHandler makes the logger write all entries to a given handler
+// Pretty and Out root context params are ignored in this case
+func WithSlogHandler(handler slog.Handler) SlogLoggerFactoryOpt {
+	return func(cfg *slogLoggerFactoryCfg) {
+		cfg.handler = handler
+	}
+}
+
+// NewSlogLoggerFactory returns a logger factory that writes log entries via log/slog.
+// By default JSON or text (if Pretty) slog handler is used that writes to the root context Out.
+func NewSlogLoggerFactory(opts ...SlogLoggerFactoryOpt) LoggerFactory {
+	cfg := slogLoggerFactoryCfg{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return slogLoggerFactory{handler: cfg.handler}
+}
+
+type slogLoggerFactory struct {
+	handler slog.Handler
+}
+
+func (f slogLoggerFactory) NewLogger(p *rootContextParams) LevelLogger {
+	if _, ok := ParseLogLevel(p.LogLevel.String()); !ok {
+		panic(fmt.Errorf("invalid log level %s", p.LogLevel))
+	}
+
+	handler := f.handler
+	if handler == nil {
+		var out io.Writer = os.Stderr
+		if p.Out != nil {
+			out = p.Out
+		}
+
+		// Level filtering is done by the logger since child loggers may have a different level
+		handlerOpts := &slog.HandlerOptions{
+			Level:       SlogLevelTrace,
+			ReplaceAttr: replaceSlogBuiltinAttr,
+		}
+		if replacesBuiltinFields(p.cloudPlatformAdapter) {
+			handlerOpts.ReplaceAttr = dropSlogBuiltinAttr
+		}
+		if p.Pretty {
+			handler = slog.NewTextHandler(out, handlerOpts)
+		} else {
+			handler = slog.NewJSONHandler(out, handlerOpts)
+		}
+	}
+
+	levelControl := p.levelControl
+	if levelControl == nil {
+		levelControl = newLogLevelControl(p.LogLevel)
+	}
+
+	return &slogLevelLogger{
+		handler:              handler,
+		levelControl:         levelControl,
+		correlationID:        p.DiagData.CorrelationID,
+		cloudPlatformAdapter: p.cloudPlatformAdapter,
+		contextAttr:          newSlogContextAttr(p.DiagData, p.cloudPlatformAdapter),
+		diagData:             p.DiagData,
+	}
+}
+
+func (slogLoggerFactory) ChildLogger(logger LevelLogger, diagOpts DiagOpts) LevelLogger {
+	slogLogger, ok := logger.(*slogLevelLogger)
+	if !ok {
+		panic(fmt.Errorf("slogLoggerFactory.ChildLogger: logger is not a *slogLevelLogger"))
+	}
+
+	levelOverride := slogLogger.levelOverride
+	if diagOpts.Level != nil {
+		if childLevel, ok := ParseLogLevel(diagOpts.Level.String()); ok {
+			levelOverride = &childLevel
+		} else {
+			slogLogger.Warn().Msgf("unexpected log level: %s", diagOpts.Level)
+		}
+	}
+
+	tailBuffer := slogLogger.tailBuffer
+	if diagOpts.TailBuffer != nil {
+		tailBuffer = diagOpts.TailBuffer
+	}
+
+	return &slogLevelLogger{
+		handler:              slogLogger.handler,
+		levelControl:         slogLogger.levelControl,
+		levelOverride:        levelOverride,
+		tailBuffer:           tailBuffer,
+		correlationID:        diagOpts.DiagData.CorrelationID,
+		cloudPlatformAdapter: slogLogger.cloudPlatformAdapter,
+		contextAttr:          newSlogContextAttr(diagOpts.DiagData, slogLogger.cloudPlatformAdapter),
+		diagData:             diagOpts.DiagData,
+	}
+}
+
+var _ LoggerFactory = slogLoggerFactory{}
+
+type slogLevelLogger struct {
+	handler slog.Handler
+	cloudPlatformAdapter
+	contextAttr slog.Attr
+	diagData    ContextDiagData
+
+	// levelControl holds the level shared with the root logger
+	levelControl *LogLevelControl
+
+	// levelOverride is a fixed level of the logger, if set the levelControl level is ignored
+	levelOverride *LogLevel
+
+	// correlationID is used to lookup correlation id level of the levelControl
+	correlationID string
+
+	// tailBuffer holds entries below the logger level
+	tailBuffer *LogTailBuffer
+}
+
+var _ LevelLogger = &slogLevelLogger{}
+
+func (l *slogLevelLogger) enabled(level LogLevel) bool {
+	slogLevel := level.SlogLevel()
+	return slogLevel >= l.level().SlogLevel() && l.handler.Enabled(context.Background(), slogLevel)
+}
+
+func (l *slogLevelLogger) level() LogLevel {
+	return l.levelControl.loggerLevel(l.correlationID, l.levelOverride)
+}
+
+func (l *slogLevelLogger) newEvent(level LogLevel) *slogLogLevelEvent {
+	slogLevel := level.SlogLevel()
+	buffered := false
+	if !l.enabled(level) {
+		if !l.tailBuffer.captures(level) || !l.handler.Enabled(context.Background(), slogLevel) {
+			return &slogLogLevelEvent{}
+		}
+		buffered = true
+	} else if level == LogLevelErrorValue {
+		l.tailBuffer.Flush()
+	}
+	evt := &slogLogLevelEvent{
+		logger:   l,
+		level:    slogLevel,
+		attrs:    []slog.Attr{l.contextAttr},
+		buffered: buffered,
+	}
+	if l.cloudPlatformAdapter != nil {
+		l.cloudPlatformAdapter.appendLevelData(level, slogLogFieldAppender{attrs: &evt.attrs})
+		l.cloudPlatformAdapter.appendContextData(l.diagData, slogLogFieldAppender{attrs: &evt.attrs})
+	}
+	return evt
+}
+
+func (l *slogLevelLogger) Error() LogLevelEvent {
+	return l.newEvent(LogLevelErrorValue)
+}
+
+func (l *slogLevelLogger) Warn() LogLevelEvent {
+	return l.newEvent(LogLevelWarnValue)
+}
+
+func (l *slogLevelLogger) Info() LogLevelEvent {
+	return l.newEvent(LogLevelInfoValue)
+}
+
+func (l *slogLevelLogger) Debug() LogLevelEvent {
+	return l.newEvent(LogLevelDebugValue)
+}
+
+func (l *slogLevelLogger) Trace() LogLevelEvent {
+	return l.newEvent(LogLevelTraceValue)
+}
+
+func (l *slogLevelLogger) WithLevel(level LogLevel) LogLevelEvent {
+	if _, ok := ParseLogLevel(level.String()); !ok {
+		l.Warn().Msgf("Invalid log level: %s. Will use %s", level, LogLevelDebugValue)
+		level = LogLevelDebugValue
+	}
+	return l.newEvent(level)
+}
+
+func (l *slogLevelLogger) NewData() MsgData {
+	return &slogLogData{}
+}
+
+// slogLogLevelEvent accumulates attributes of a single log entry.
+// Event with no logger is disabled and will not be written.
+type slogLogLevelEvent struct {
+	logger *slogLevelLogger
+	level  slog.Level
+	attrs  []slog.Attr
+
+	// buffered entries are written via the logger tail buffer
+	buffered bool
+}
+
+func (e *slogLogLevelEvent) enabled() bool {
+	return e.logger != nil
+}
+
+type slogLogFieldAppender struct {
+	attrs *[]slog.Attr
+}
+
+func (a slogLogFieldAppender) Str(key, val string) {
+	*a.attrs = append(*a.attrs, slog.String(key, val))
+}
+
+func (a slogLogFieldAppender) Int(key string, val int) {
+	*a.attrs = append(*a.attrs, slog.Int(key, val))
+}
+
+func (a slogLogFieldAppender) Bool(key string, val bool) {
+	*a.attrs = append(*a.attrs, slog.Bool(key, val))
+}
+
+func (a slogLogFieldAppender) Dict(key string, appendFields func(target logFieldAppender)) {
+	var attrs []slog.Attr
+	appendFields(slogLogFieldAppender{attrs: &attrs})
+	*a.attrs = append(*a.attrs, slog.Attr{Key: key, Value: slog.GroupValue(attrs...)})
+}
+
+func (e *slogLogLevelEvent) WithHTTPRequest(info HTTPRequestInfo) LogLevelEvent {
+	if e.logger != nil && e.logger.cloudPlatformAdapter != nil {
+		e.logger.cloudPlatformAdapter.appendHTTPRequestData(info, slogLogFieldAppender{attrs: &e.attrs})
+	}
+	return e
+}
+
+func (e *slogLogLevelEvent) WithError(err error) LogLevelEvent {
+	if e.logger != nil && err != nil {
+		e.attrs = append(e.attrs, slog.Any("error", err))
+	}
+	return e
+}
+
+func (e *slogLogLevelEvent) WithDataFn(dataFn func(data MsgData)) LogLevelEvent {
+	if e.logger == nil {
+		return e
+	}
+	data := &slogLogData{}
+	dataFn(data)
+	e.attrs = append(e.attrs, slog.Attr{Key: "data", Value: slog.GroupValue(data.attrs...)})
+	return e
+}
+
+func (e *slogLogLevelEvent) WithData(data MsgData) LogLevelEvent {
+	slogData, ok := data.(*slogLogData)
+	if !ok {
+		panic(fmt.Errorf("slogLogLevelEvent.WithData: data is not a *slogLogData"))
+	}
+	if e.logger != nil {
+		e.attrs = append(e.attrs, slog.Attr{Key: "data", Value: slog.GroupValue(slogData.attrs...)})
+	}
+	return e
+}
+
+func (e *slogLogLevelEvent) Msg(msg string) {
+	if e.logger == nil {
+		return
+	}
+	record := slog.NewRecord(time.Now(), e.level, msg, 0)
+	record.AddAttrs(e.attrs...)
+	handler := e.logger.handler
+	write := func() {
+		if err := handler.Handle(context.Background(), record); err != nil {
+			fmt.Fprintf(os.Stderr, "diag: could not write slog record: %v\n", err)
+		}
+	}
+	if e.buffered {
+		e.logger.tailBuffer.write(write)
+		return
+	}
+	write()
+}
+
+func (e *slogLogLevelEvent) Msgf(format string, v ...interface{}) {
+	if e.logger == nil {
+		return
+	}
+	e.Msg(fmt.Sprintf(format, v...))
+}
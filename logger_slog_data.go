@@ -0,0 +1,199 @@
+package diag
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+)
+
+// slogLogData accumulates slog attributes of a log message data
+type slogLogData struct {
+	attrs []slog.Attr
+}
+
+var _ MsgData = &slogLogData{}
+
+// slogFloat32 makes JSON handler preserve float32 precision,
+// slog would otherwise convert float32 values to float64
+type slogFloat32 float32
+
+func (d *slogLogData) add(attr slog.Attr) MsgData {
+	d.attrs = append(d.attrs, attr)
+	return d
+}
+
+func (d *slogLogData) Str(key string, value string) MsgData {
+	return d.add(slog.String(key, value))
+}
+
+func (d *slogLogData) Strs(key string, value []string) MsgData {
+	return d.add(slog.Any(key, value))
+}
+
+func (d *slogLogData) Stringer(key string, value fmt.Stringer) MsgData {
+	if value == nil {
+		return d.add(slog.Any(key, nil))
+	}
+	return d.add(slog.String(key, value.String()))
+}
+
+func (d *slogLogData) Bytes(key string, value []byte) MsgData {
+	return d.add(slog.String(key, string(value)))
+}
+
+func (d *slogLogData) Hex(key string, value []byte) MsgData {
+	return d.add(slog.String(key, hex.EncodeToString(value)))
+}
+
+func (d *slogLogData) RawJSON(key string, value []byte) MsgData {
+	return d.add(slog.Any(key, json.RawMessage(value)))
+}
+
+func (d *slogLogData) Bool(key string, value bool) MsgData {
+	return d.add(slog.Bool(key, value))
+}
+
+func (d *slogLogData) Bools(key string, value []bool) MsgData {
+	return d.add(slog.Any(key, value))
+}
+
+func (d *slogLogData) Int(key string, value int) MsgData {
+	return d.add(slog.Int(key, value))
+}
+
+func (d *slogLogData) Ints(key string, value []int) MsgData {
+	return d.add(slog.Any(key, value))
+}
+
+func (d *slogLogData) Int8(key string, value int8) MsgData {
+	return d.add(slog.Int64(key, int64(value)))
+}
+
+func (d *slogLogData) Ints8(key string, value []int8) MsgData {
+	return d.add(slog.Any(key, value))
+}
+
+func (d *slogLogData) Int16(key string, value int16) MsgData {
+	return d.add(slog.Int64(key, int64(value)))
+}
+
+func (d *slogLogData) Ints16(key string, value []int16) MsgData {
+	return d.add(slog.Any(key, value))
+}
+
+func (d *slogLogData) Int32(key string, value int32) MsgData {
+	return d.add(slog.Int64(key, int64(value)))
+}
+
+func (d *slogLogData) Ints32(key string, value []int32) MsgData {
+	return d.add(slog.Any(key, value))
+}
+
+func (d *slogLogData) Int64(key string, value int64) MsgData {
+	return d.add(slog.Int64(key, value))
+}
+
+func (d *slogLogData) Ints64(key string, value []int64) MsgData {
+	return d.add(slog.Any(key, value))
+}
+
+func (d *slogLogData) Uint(key string, value uint) MsgData {
+	return d.add(slog.Uint64(key, uint64(value)))
+}
+
+func (d *slogLogData) Uints(key string, value []uint) MsgData {
+	return d.add(slog.Any(key, value))
+}
+
+func (d *slogLogData) Uint8(key string, value uint8) MsgData {
+	return d.add(slog.Uint64(key, uint64(value)))
+}
+
+func (d *slogLogData) Uints8(key string, value []uint8) MsgData {
+	// []uint8 is marshaled as base64 string by encoding/json
+	// so converting to a wider type to get an array of numbers
+	values := make([]uint16, len(value))
+	for i, v := range value {
+		values[i] = uint16(v)
+	}
+	return d.add(slog.Any(key, values))
+}
+
+func (d *slogLogData) Uint16(key string, value uint16) MsgData {
+	return d.add(slog.Uint64(key, uint64(value)))
+}
+
+func (d *slogLogData) Uints16(key string, value []uint16) MsgData {
+	return d.add(slog.Any(key, value))
+}
+
+func (d *slogLogData) Uint32(key string, value uint32) MsgData {
+	return d.add(slog.Uint64(key, uint64(value)))
+}
+
+func (d *slogLogData) Uints32(key string, value []uint32) MsgData {
+	return d.add(slog.Any(key, value))
+}
+
+func (d *slogLogData) Uint64(key string, value uint64) MsgData {
+	return d.add(slog.Uint64(key, value))
+}
+
+func (d *slogLogData) Uints64(key string, value []uint64) MsgData {
+	return d.add(slog.Any(key, value))
+}
+
+func (d *slogLogData) Float32(key string, value float32) MsgData {
+	return d.add(slog.Any(key, slogFloat32(value)))
+}
+
+func (d *slogLogData) Floats32(key string, value []float32) MsgData {
+	return d.add(slog.Any(key, value))
+}
+
+func (d *slogLogData) Float64(key string, value float64) MsgData {
+	return d.add(slog.Float64(key, value))
+}
+
+func (d *slogLogData) Floats64(key string, value []float64) MsgData {
+	return d.add(slog.Any(key, value))
+}
+
+func (d *slogLogData) Time(key string, value time.Time) MsgData {
+	return d.add(slog.Time(key, value))
+}
+
+func (d *slogLogData) Times(key string, value []time.Time) MsgData {
+	return d.add(slog.Any(key, value))
+}
+
+func (d *slogLogData) IPAddr(key string, value net.IP) MsgData {
+	return d.add(slog.String(key, value.String()))
+}
+
+func (d *slogLogData) IPPrefix(key string, value net.IPNet) MsgData {
+	return d.add(slog.String(key, value.String()))
+}
+
+func (d *slogLogData) MACAddr(key string, value net.HardwareAddr) MsgData {
+	return d.add(slog.String(key, value.String()))
+}
+
+func (d *slogLogData) Secret(key string, value string) MsgData {
+	return d.add(slog.String(key, obfuscate(value)))
+}
+
+func (d *slogLogData) Dict(key string, data MsgData) MsgData {
+	slogData, ok := data.(*slogLogData)
+	if !ok {
+		panic(fmt.Errorf("MsgData instance is not slog data"))
+	}
+	return d.add(slog.Attr{Key: key, Value: slog.GroupValue(slogData.attrs...)})
+}
+
+func (d *slogLogData) Interface(key string, value interface{}) MsgData {
+	return d.add(slog.Any(key, value))
+}
@@ -0,0 +1,433 @@
+package diag
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlog_Levels(t *testing.T) {
+	tests := []struct {
+		level     LogLevel
+		slogLevel slog.Level
+	}{
+		{LogLevelTraceValue, SlogLevelTrace},
+		{LogLevelDebugValue, slog.LevelDebug},
+		{LogLevelInfoValue, slog.LevelInfo},
+		{LogLevelWarnValue, slog.LevelWarn},
+		{LogLevelErrorValue, slog.LevelError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level.String(), func(t *testing.T) {
+			assert.Equal(t, tt.slogLevel, tt.level.SlogLevel())
+			assert.Equal(t, tt.level, LogLevelFromSlog(tt.slogLevel))
+		})
+	}
+	t.Run("unknown level", func(t *testing.T) {
+		assert.Equal(t, slog.LevelDebug, LogLevel("bad-"+fake.Lorem().Word()).SlogLevel())
+	})
+	t.Run("in between levels", func(t *testing.T) {
+		assert.Equal(t, LogLevelTraceValue, LogLevelFromSlog(slog.LevelDebug-1))
+		assert.Equal(t, LogLevelDebugValue, LogLevelFromSlog(slog.LevelInfo-1))
+		assert.Equal(t, LogLevelInfoValue, LogLevelFromSlog(slog.LevelWarn-1))
+		assert.Equal(t, LogLevelWarnValue, LogLevelFromSlog(slog.LevelError-1))
+		assert.Equal(t, LogLevelErrorValue, LogLevelFromSlog(slog.LevelError+4))
+	})
+}
+
+func TestSlog_LoggerFactory(t *testing.T) {
+	factory := NewSlogLoggerFactory()
+	var output bytes.Buffer
+	outputWriter := bufio.NewWriter(&output)
+	t.Run("NewLogger", func(t *testing.T) {
+		t.Run("returns a new logger", func(t *testing.T) {
+			wantCorrelationID := fake.UUID().V4()
+			wantEntries := map[string]string{
+				"key1": fake.Lorem().Word(),
+				"key2": fake.Lorem().Word(),
+			}
+			logger := factory.NewLogger(&rootContextParams{
+				DiagData: ContextDiagData{
+					CorrelationID: wantCorrelationID,
+					Entries:       wantEntries,
+				},
+				LogLevel: LogLevelInfoValue,
+				Out:      outputWriter,
+			})
+			assert.IsType(t, &slogLevelLogger{}, logger)
+
+			msg := fake.Lorem().Sentence(3)
+			output.Reset()
+			logger.Info().Msg(msg)
+			logger.Debug().Msg(msg)
+			outputWriter.Flush()
+
+			var logMessage TestLogMessage[map[string]string]
+			assert.NoError(t, json.Unmarshal(output.Bytes(), &logMessage))
+			assert.Equal(t, TestLogMessage[map[string]string]{
+				Level: "info",
+				Msg:   msg,
+				Time:  logMessage.Time,
+				Context: map[string]string{
+					"correlationId": wantCorrelationID,
+					"key1":          wantEntries["key1"],
+					"key2":          wantEntries["key2"],
+				},
+			}, logMessage)
+			assert.NotEmpty(t, logMessage.Time)
+		})
+
+		t.Run("returns a new logger with pretty", func(t *testing.T) {
+			logger := factory.NewLogger(&rootContextParams{
+				LogLevel: LogLevelInfoValue,
+				Out:      outputWriter,
+				Pretty:   true,
+			})
+			msg := fake.Lorem().Sentence(3)
+			output.Reset()
+			logger.Info().Msg(msg)
+			outputWriter.Flush()
+			outputStr := output.String()
+			assert.Contains(t, outputStr, msg)
+			assert.Contains(t, outputStr, "level=info")
+			assert.NotContains(t, outputStr, `"msg":"`+msg+`"`)
+		})
+
+		t.Run("uses custom handler", func(t *testing.T) {
+			var output bytes.Buffer
+			handler := slog.NewJSONHandler(&output, &slog.HandlerOptions{Level: slog.LevelWarn})
+			logger := NewSlogLoggerFactory(WithSlogHandler(handler)).NewLogger(&rootContextParams{
+				LogLevel: LogLevelTraceValue,
+				Out:      outputWriter,
+			})
+			msg := fake.Lorem().Sentence(3)
+			logger.Info().Msg(msg)
+			assert.Empty(t, output.String())
+
+			logger.Warn().Msg(msg)
+			var logMessage map[string]interface{}
+			assert.NoError(t, json.Unmarshal(output.Bytes(), &logMessage))
+			assert.Equal(t, "WARN", logMessage["level"])
+			assert.Equal(t, msg, logMessage["msg"])
+		})
+
+		t.Run("panics if bad log level", func(t *testing.T) {
+			assert.Panics(t, func() {
+				factory.NewLogger(&rootContextParams{
+					LogLevel: LogLevel("bad-" + fake.Lorem().Word()),
+					Out:      outputWriter,
+				})
+			})
+		})
+	})
+
+	t.Run("ChildLogger", func(t *testing.T) {
+		t.Run("creates a derived logger", func(t *testing.T) {
+			rootEntry1 := fmt.Sprintf("root-entry1-%s", fake.Lorem().Word())
+			rootDiagParams := ContextDiagData{
+				CorrelationID: fake.UUID().V4(),
+				Entries: map[string]string{
+					"root-key1":            rootEntry1,
+					"root-overridden-key1": fake.Lorem().Word(),
+				},
+			}
+			rootLogger := factory.NewLogger(&rootContextParams{
+				LogLevel: LogLevelTraceValue,
+				Out:      outputWriter,
+				DiagData: rootDiagParams,
+			})
+
+			nextCorrelationID := fake.UUID().V4()
+			wantChildEntries := map[string]string{
+				"ch-key1":              fake.Lorem().Word(),
+				"root-key1":            rootEntry1,
+				"root-overridden-key1": fake.Lorem().Word(),
+			}
+			childLogger := factory.ChildLogger(rootLogger, DiagOpts{
+				DiagData: ContextDiagData{
+					CorrelationID: nextCorrelationID,
+					Entries:       wantChildEntries,
+				},
+			})
+			assert.IsType(t, &slogLevelLogger{}, childLogger)
+
+			msg := fake.Lorem().Sentence(3)
+			output.Reset()
+			childLogger.Trace().Msg(msg)
+			outputWriter.Flush()
+			assert.Len(t,
+				regexp.MustCompile("root-overridden-key1").FindAllIndex(output.Bytes(), -1),
+				1)
+			assert.Len(t,
+				regexp.MustCompile("correlationId").FindAllIndex(output.Bytes(), -1),
+				1)
+			var logMessage TestLogMessage[map[string]string]
+			assert.NoError(t, json.Unmarshal(output.Bytes(), &logMessage))
+			assert.Equal(t, TestLogMessage[map[string]string]{
+				Level: "trace",
+				Msg:   msg,
+				Time:  logMessage.Time,
+				Context: map[string]string{
+					"correlationId":        nextCorrelationID,
+					"ch-key1":              wantChildEntries["ch-key1"],
+					"root-key1":            rootEntry1,
+					"root-overridden-key1": wantChildEntries["root-overridden-key1"],
+				},
+			}, logMessage)
+		})
+		t.Run("creates a derived logger with custom level", func(t *testing.T) {
+			var output bytes.Buffer
+			outputWriter := bufio.NewWriter(&output)
+
+			rootLogger := factory.NewLogger(&rootContextParams{
+				LogLevel: LogLevelInfoValue,
+				Out:      outputWriter,
+			})
+
+			for _, tt := range []struct {
+				level     LogLevel
+				wantEmpty bool
+			}{
+				{level: LogLevelWarnValue, wantEmpty: true},
+				{level: LogLevelTraceValue, wantEmpty: false},
+			} {
+				nextLogLevel := tt.level
+				childLogger := factory.ChildLogger(rootLogger, DiagOpts{
+					Level: &nextLogLevel,
+				})
+				output.Reset()
+				childLogger.Debug().Msg(fake.Lorem().Sentence(3))
+				childLogger.Info().Msg(fake.Lorem().Sentence(3))
+				outputWriter.Flush()
+				if tt.wantEmpty {
+					assert.Empty(t, output.String())
+				} else {
+					assert.Len(t, bytes.Split(bytes.Trim(output.Bytes(), "\n"), []byte("\n")), 2)
+				}
+			}
+		})
+		t.Run("ignore bad level", func(t *testing.T) {
+			var output bytes.Buffer
+			outputWriter := bufio.NewWriter(&output)
+
+			rootLogger := factory.NewLogger(&rootContextParams{
+				LogLevel: LogLevelInfoValue,
+				Out:      outputWriter,
+			})
+
+			badLevel := LogLevel("bad-" + fake.Lorem().Word())
+			childLogger := factory.ChildLogger(rootLogger, DiagOpts{
+				Level: &badLevel,
+			})
+			outputWriter.Flush()
+			assert.Contains(t, output.String(), fmt.Sprintf("unexpected log level: %s", badLevel))
+			assert.Contains(t, output.String(), `"level":"warn"`)
+
+			msg := fake.Lorem().Sentence(3)
+			output.Reset()
+			childLogger.Debug().Msg(msg)
+			outputWriter.Flush()
+			assert.Empty(t, output.String())
+
+			output.Reset()
+			childLogger.Info().Msg(msg)
+			outputWriter.Flush()
+			assert.Contains(t, output.String(), msg)
+		})
+		t.Run("validates if proper parent logger", func(t *testing.T) {
+			assert.PanicsWithError(t, "slogLoggerFactory.ChildLogger: logger is not a *slogLevelLogger", func() {
+				factory.ChildLogger(nil, DiagOpts{})
+			})
+		})
+	})
+}
+
+func TestSlog_WithLevel(t *testing.T) {
+	var output bytes.Buffer
+	outputWriter := bufio.NewWriter(&output)
+
+	params := NewRootContextParams().
+		WithLoggerFactory(NewSlogLoggerFactory()).
+		WithLogLevel(LogLevelTraceValue).
+		WithOutput(outputWriter)
+	wantLogKey := "mock-key-" + fake.UUID().V4()
+	mockLogLevelValuePrefix := "mock-level-"
+	params.cloudPlatformAdapter = mockCloudPlatformAdapter{
+		mockLogKey:              wantLogKey,
+		mockLogLevelValuePrefix: mockLogLevelValuePrefix,
+	}
+	log := Log(RootContext(params))
+
+	t.Run("valid", func(t *testing.T) {
+		tests := []struct {
+			log       LogLevelEvent
+			wantLevel string
+		}{
+			{log: log.Error(), wantLevel: "error"},
+			{log: log.Warn(), wantLevel: "warn"},
+			{log: log.Info(), wantLevel: "info"},
+			{log: log.Debug(), wantLevel: "debug"},
+			{log: log.Trace(), wantLevel: "trace"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.wantLevel, func(t *testing.T) {
+				msg := fake.Lorem().Sentence(3)
+
+				for _, fn := range []func(){
+					func() {
+						tt.log.Msg(msg)
+					},
+					func() {
+						level, _ := ParseLogLevel(tt.wantLevel)
+						log.WithLevel(level).Msgf("%s", msg)
+					},
+				} {
+					output.Reset()
+					fn()
+					outputWriter.Flush()
+
+					var logMessage map[string]interface{}
+					assert.NoError(t, json.Unmarshal(output.Bytes(), &logMessage))
+					assert.Equal(t, tt.wantLevel, logMessage["level"])
+					assert.Equal(t, msg, logMessage["msg"])
+					assert.Equal(t, mockLogLevelValuePrefix+tt.wantLevel, logMessage[wantLogKey])
+				}
+			})
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		wantMsg := fake.Lorem().Sentence(3)
+		badLevelValue := "bad-" + fake.Lorem().Word()
+		output.Reset()
+		badLevelLogger := log.WithLevel(LogLevel(badLevelValue))
+		outputWriter.Flush()
+		var logMessage TestLogMessage[TestContext]
+		assert.NoError(t, json.Unmarshal(output.Bytes(), &logMessage))
+		assert.Equal(t, LogLevelWarnValue.String(), logMessage.Level)
+		assert.Equal(t,
+			fmt.Sprintf("Invalid log level: %s. Will use %s", badLevelValue, LogLevelDebugValue),
+			logMessage.Msg,
+		)
+
+		output.Reset()
+		badLevelLogger.Msg(wantMsg)
+		outputWriter.Flush()
+		assert.NoError(t, json.Unmarshal(output.Bytes(), &logMessage))
+		assert.Equal(t, LogLevelDebugValue.String(), logMessage.Level)
+		assert.Equal(t, wantMsg, logMessage.Msg)
+	})
+
+	t.Run("disabled level", func(t *testing.T) {
+		var output bytes.Buffer
+		log := NewSlogLoggerFactory().NewLogger(&rootContextParams{
+			LogLevel: LogLevelErrorValue,
+			Out:      &output,
+		})
+		log.Info().
+			WithError(errors.New(fake.Lorem().Word())).
+			WithDataFn(func(data MsgData) {
+				data.Str(fake.Lorem().Word(), fake.Lorem().Word())
+			}).
+			WithData(log.NewData()).
+			Msgf("%s", fake.Lorem().Sentence(3))
+		assert.Empty(t, output.String())
+	})
+}
+
+func TestSlog_LogData(t *testing.T) {
+	var output bytes.Buffer
+	outputWriter := bufio.NewWriter(&output)
+	logger := NewSlogLoggerFactory().NewLogger(&rootContextParams{
+		Out: outputWriter, LogLevel: LogLevelDebugValue,
+	})
+
+	t.Run("DataMethods", func(t *testing.T) {
+		testLogDataMethods(t, func(out io.Writer) LevelLogger {
+			return NewSlogLoggerFactory().NewLogger(&rootContextParams{Out: out, LogLevel: LogLevelDebugValue})
+		}, decodeLogEntryData)
+	})
+
+	t.Run("nil Stringer", func(t *testing.T) {
+		output.Reset()
+		logger.Info().WithData(logger.NewData().Stringer("key", nil)).Msg(fake.Lorem().Sentence(3))
+		outputWriter.Flush()
+		var logMessage map[string]interface{}
+		assert.NoError(t, json.Unmarshal(output.Bytes(), &logMessage))
+		assert.Equal(t, map[string]interface{}{"key": nil}, logMessage["data"])
+	})
+
+	t.Run("WithDataFn", func(t *testing.T) {
+		output.Reset()
+		val1 := fake.Lorem().Word()
+		val2 := fake.Lorem().Word()
+		logger.Info().WithDataFn(func(data MsgData) {
+			data.Str("key1", val1)
+			data.Str("key2", val2)
+		}).Msg(fake.Lorem().Sentence(3))
+		outputWriter.Flush()
+		var logMessage map[string]interface{}
+		assert.NoError(t, json.Unmarshal(output.Bytes(), &logMessage))
+		gotData := logMessage["data"].(map[string]interface{})
+		assert.Equal(t, map[string]interface{}{
+			"key1": val1,
+			"key2": val2,
+		}, gotData)
+	})
+
+	t.Run("WithData bad type", func(t *testing.T) {
+		assert.PanicsWithError(
+			t,
+			"slogLogLevelEvent.WithData: data is not a *slogLogData",
+			func() {
+				logger.Info().WithData(nil).Msg(fake.Lorem().Sentence(3))
+			})
+	})
+
+	t.Run("Dict with not a dict", func(t *testing.T) {
+		assert.PanicsWithError(
+			t,
+			"MsgData instance is not slog data",
+			func() {
+				logger.NewData().Dict(fake.Lorem().Word(), nil)
+			})
+	})
+
+	t.Run("WithError", func(t *testing.T) {
+		output.Reset()
+		wantErr := errors.New(fake.Lorem().Sentence(3))
+		logger.Info().WithError(wantErr).WithError(nil).Msg(fake.Lorem().Sentence(3))
+		outputWriter.Flush()
+		var logMessage map[string]interface{}
+		assert.NoError(t, json.Unmarshal(output.Bytes(), &logMessage))
+		assert.Equal(t, wantErr.Error(), logMessage["error"])
+	})
+}
+
+type failingSlogHandler struct {
+	slog.Handler
+}
+
+func (failingSlogHandler) Handle(context.Context, slog.Record) error {
+	return errors.New("handler failed")
+}
+
+func TestSlog_HandlerError(t *testing.T) {
+	handler := failingSlogHandler{Handler: slog.NewJSONHandler(&bytes.Buffer{}, nil)}
+	logger := NewSlogLoggerFactory(WithSlogHandler(handler)).NewLogger(&rootContextParams{
+		LogLevel: LogLevelInfoValue,
+	})
+	assert.NotPanics(t, func() {
+		logger.Info().Msg(fake.Lorem().Sentence(3))
+	})
+}
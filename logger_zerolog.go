@@ -16,10 +16,21 @@ func init() {
 	zerolog.MessageFieldName = "msg"
 }
 
-func newZerologContextDataFunc(diagData ContextDiagData) func(*zerolog.Event) {
+func newZerologContextDataFunc(diagData ContextDiagData, adapter cloudPlatformAdapter) func(*zerolog.Event) {
 	return func(e *zerolog.Event) {
-		contextData := zerolog.Dict().
-			Str("correlationId", diagData.CorrelationID)
+		contextData := zerolog.Dict()
+		if !writesCorrelationID(adapter, diagData) {
+			contextData = contextData.Str("correlationId", diagData.CorrelationID)
+		}
+		if trace := diagData.Trace; trace.IsValid() {
+			contextData = contextData.
+				Str("traceId", trace.TraceID).
+				Str("spanId", trace.SpanID).
+				Bool("traceSampled", trace.Sampled)
+			if trace.ParentSpanID != "" {
+				contextData = contextData.Str("parentSpanId", trace.ParentSpanID)
+			}
+		}
 		for k, v := range diagData.Entries {
 			contextData = contextData.Str(k, v)
 		}
@@ -38,28 +49,35 @@ func (zerologLoggerFactory) NewLogger(p *rootContextParams) LevelLogger {
 	}
 
 	if p.Pretty {
-		logger = zerolog.New(
-			zerolog.ConsoleWriter{Out: out},
-		)
-	} else {
-		logger = zerolog.New(out)
+		out = zerolog.ConsoleWriter{Out: out}
 	}
+	logger = zerolog.New(out)
 
-	zerologLevel, err := zerolog.ParseLevel(p.LogLevel.String())
-	if err != nil {
+	if _, err := zerolog.ParseLevel(p.LogLevel.String()); err != nil {
 		panic(fmt.Errorf("invalid log level %s: %w", p.LogLevel, err))
 	}
 
-	logger = logger.
-		With().
-		Timestamp().
-		Logger().
-		Level(zerologLevel)
+	// Level filtering is done by the logger since the level can be changed at runtime
+	if !replacesBuiltinFields(p.cloudPlatformAdapter) {
+		logger = logger.
+			With().
+			Timestamp().
+			Logger()
+	}
+
+	levelControl := p.levelControl
+	if levelControl == nil {
+		levelControl = newLogLevelControl(p.LogLevel)
+	}
 
 	return &zerologLevelLogger{
 		Logger:               logger,
+		out:                  out,
 		cloudPlatformAdapter: p.cloudPlatformAdapter,
-		ContextDiagDataFunc:  newZerologContextDataFunc(p.DiagData),
+		ContextDiagDataFunc:  newZerologContextDataFunc(p.DiagData, p.cloudPlatformAdapter),
+		diagData:             p.DiagData,
+		levelControl:         levelControl,
+		correlationID:        p.DiagData.CorrelationID,
 	}
 }
 
@@ -72,20 +90,36 @@ func (zerologLoggerFactory) ChildLogger(logger LevelLogger, diagOpts DiagOpts) L
 	diagData := diagOpts.DiagData
 	childLogger := zerologLogger.Logger.With().Logger()
 
+	levelOverride := zerologLogger.levelOverride
 	if diagOpts.Level != nil {
 		logLevel := diagOpts.Level.String()
-		zerologLevel, err := zerolog.ParseLevel(logLevel)
-		if err != nil {
-			childLogger.Warn().Err(err).Msgf("unexpected log level: %s", logLevel)
+		if _, err := zerolog.ParseLevel(logLevel); err != nil {
+			zerologLogger.Warn().WithError(err).Msgf("unexpected log level: %s", logLevel)
 		} else {
-			childLogger = childLogger.Level(zerologLevel)
+			levelOverride = diagOpts.Level
 		}
 	}
 
+	tailBuffer := zerologLogger.tailBuffer
+	if diagOpts.TailBuffer != nil {
+		tailBuffer = diagOpts.TailBuffer
+	}
+	var tailLogger zerolog.Logger
+	if tailBuffer != nil {
+		tailLogger = childLogger.Output(tailBufferWriter{buffer: tailBuffer, out: zerologLogger.out})
+	}
+
 	return &zerologLevelLogger{
 		Logger:               childLogger,
+		out:                  zerologLogger.out,
 		cloudPlatformAdapter: zerologLogger.cloudPlatformAdapter,
-		ContextDiagDataFunc:  newZerologContextDataFunc(diagData),
+		ContextDiagDataFunc:  newZerologContextDataFunc(diagData, zerologLogger.cloudPlatformAdapter),
+		diagData:             diagData,
+		levelControl:         zerologLogger.levelControl,
+		levelOverride:        levelOverride,
+		correlationID:        diagData.CorrelationID,
+		tailBuffer:           tailBuffer,
+		tailLogger:           tailLogger,
 	}
 }
 
@@ -93,18 +127,39 @@ var _ LoggerFactory = zerologLoggerFactory{}
 
 type zerologLevelLogger struct {
 	zerolog.Logger
+	out io.Writer
 	cloudPlatformAdapter
 	ContextDiagDataFunc func(*zerolog.Event)
+	diagData            ContextDiagData
+
+	// levelControl holds the level shared with the root logger
+	levelControl *LogLevelControl
+
+	// levelOverride is a fixed level of the logger, if set the levelControl level is ignored
+	levelOverride *LogLevel
+
+	// correlationID is used to lookup correlation id level of the levelControl
+	correlationID string
+
+	// tailBuffer holds entries below the logger level, they are written via tailLogger
+	tailBuffer *LogTailBuffer
+	tailLogger zerolog.Logger
 }
 
-func (l *zerologLevelLogger) appendCloudPlatformLevelData(level LogLevel, evt *zerolog.Event) {
+func (l *zerologLevelLogger) appendCloudPlatformData(level LogLevel, evt *zerolog.Event) {
 	if l.cloudPlatformAdapter != nil {
 		l.cloudPlatformAdapter.appendLevelData(level, zerologLogFieldAppender{Event: evt})
+		l.cloudPlatformAdapter.appendContextData(l.diagData, zerologLogFieldAppender{Event: evt})
 	}
 }
 
 type zerologLogLevelEvent struct {
 	*zerolog.Event
+	adapter cloudPlatformAdapter
+}
+
+func (l zerologLogLevelEvent) enabled() bool {
+	return l.Event != nil
 }
 
 type zerologLogFieldAppender struct {
@@ -115,52 +170,116 @@ func (l zerologLogFieldAppender) Str(key, val string) {
 	l.Event.Str(key, val)
 }
 
+func (l zerologLogFieldAppender) Int(key string, val int) {
+	l.Event.Int(key, val)
+}
+
+func (l zerologLogFieldAppender) Bool(key string, val bool) {
+	l.Event.Bool(key, val)
+}
+
+func (l zerologLogFieldAppender) Dict(key string, appendFields func(target logFieldAppender)) {
+	dict := zerolog.Dict()
+	appendFields(zerologLogFieldAppender{Event: dict})
+	l.Event.Dict(key, dict)
+}
+
 type zerologLogData struct {
 	*zerolog.Event
 }
 
 var _ MsgData = &zerologLogData{}
 
+// zerologLevels maps severity of logLevels to zerolog levels
+var zerologLevels = [...]zerolog.Level{
+	zerolog.TraceLevel,
+	zerolog.DebugLevel,
+	zerolog.InfoLevel,
+	zerolog.WarnLevel,
+	zerolog.ErrorLevel,
+}
+
+// enabled is called for each entry so levels are compared by severity instead of parsing them
+func (l *zerologLevelLogger) enabled(level LogLevel) bool {
+	severity, minSeverity := level.severity(), l.severity()
+	if severity < 0 || minSeverity < 0 || severity < minSeverity {
+		return false
+	}
+	zerologLevel := zerologLevels[severity]
+	return zerologLevel >= l.Logger.GetLevel() && zerologLevel >= zerolog.GlobalLevel()
+}
+
+func (l *zerologLevelLogger) severity() int {
+	if l.levelControl != nil {
+		return l.levelControl.loggerSeverity(l.correlationID, l.levelOverride)
+	}
+	return l.level().severity()
+}
+
+func (l *zerologLevelLogger) level() LogLevel {
+	if l.levelControl != nil {
+		return l.levelControl.loggerLevel(l.correlationID, l.levelOverride)
+	}
+	if l.levelOverride != nil {
+		return *l.levelOverride
+	}
+	return LogLevelTraceValue
+}
+
+// newEvent returns nil event if the level is disabled, nil events are noop
+func (l *zerologLevelLogger) newEvent(level LogLevel, zerologLevel zerolog.Level) *zerolog.Event {
+	logger := &l.Logger
+	if !l.enabled(level) {
+		if !l.tailBuffer.captures(level) {
+			return nil
+		}
+		logger = &l.tailLogger
+	} else if level == LogLevelErrorValue {
+		l.tailBuffer.Flush()
+	}
+	if replacesBuiltinFields(l.cloudPlatformAdapter) {
+		zerologLevel = zerolog.NoLevel
+	}
+	evt := logger.WithLevel(zerologLevel).Func(l.ContextDiagDataFunc)
+	l.appendCloudPlatformData(level, evt)
+	return evt
+}
+
 func (l *zerologLevelLogger) Error() LogLevelEvent {
-	evt := l.Logger.Error().Func(l.ContextDiagDataFunc)
-	l.appendCloudPlatformLevelData(LogLevelErrorValue, evt)
-	return zerologLogLevelEvent{Event: evt}
+	evt := l.newEvent(LogLevelErrorValue, zerolog.ErrorLevel)
+	return zerologLogLevelEvent{Event: evt, adapter: l.cloudPlatformAdapter}
 }
 
 func (l *zerologLevelLogger) Warn() LogLevelEvent {
-	evt := l.Logger.Warn().Func(l.ContextDiagDataFunc)
-	l.appendCloudPlatformLevelData(LogLevelWarnValue, evt)
-	return &zerologLogLevelEvent{Event: evt}
+	evt := l.newEvent(LogLevelWarnValue, zerolog.WarnLevel)
+	return &zerologLogLevelEvent{Event: evt, adapter: l.cloudPlatformAdapter}
 }
 
 func (l *zerologLevelLogger) Info() LogLevelEvent {
-	evt := l.Logger.Info().Func(l.ContextDiagDataFunc)
-	l.appendCloudPlatformLevelData(LogLevelInfoValue, evt)
-	return &zerologLogLevelEvent{Event: evt}
+	evt := l.newEvent(LogLevelInfoValue, zerolog.InfoLevel)
+	return &zerologLogLevelEvent{Event: evt, adapter: l.cloudPlatformAdapter}
 }
 
 func (l *zerologLevelLogger) Debug() LogLevelEvent {
-	evt := l.Logger.Debug().Func(l.ContextDiagDataFunc)
-	l.appendCloudPlatformLevelData(LogLevelDebugValue, evt)
-	return &zerologLogLevelEvent{Event: evt}
+	evt := l.newEvent(LogLevelDebugValue, zerolog.DebugLevel)
+	return &zerologLogLevelEvent{Event: evt, adapter: l.cloudPlatformAdapter}
 }
 
 func (l *zerologLevelLogger) Trace() LogLevelEvent {
-	evt := l.Logger.Trace().Func(l.ContextDiagDataFunc)
-	l.appendCloudPlatformLevelData(LogLevelTraceValue, evt)
-	return &zerologLogLevelEvent{Event: evt}
+	evt := l.newEvent(LogLevelTraceValue, zerolog.TraceLevel)
+	return &zerologLogLevelEvent{Event: evt, adapter: l.cloudPlatformAdapter}
 }
 
 func (l *zerologLevelLogger) WithLevel(level LogLevel) LogLevelEvent {
 	zerologLevel, err := zerolog.ParseLevel(level.String())
 	if err != nil {
 		zerologLevel = zerolog.DebugLevel
-		l.Logger.Warn().Err(err).Msgf("Invalid log level: %s. Will use %s", level, zerologLevel)
+		l.Warn().WithError(err).Msgf("Invalid log level: %s. Will use %s", level, zerologLevel)
+		level = LogLevelDebugValue
 	}
 
-	evt := l.Logger.WithLevel(zerologLevel).Func(l.ContextDiagDataFunc)
-	l.appendCloudPlatformLevelData(level, evt)
-	return &zerologLogLevelEvent{Event: evt}
+	evt := l.newEvent(level, zerologLevel)
+	return &zerologLogLevelEvent{Event: evt, adapter: l.cloudPlatformAdapter}
 }
 
 func (l *zerologLevelLogger) NewData() MsgData {
@@ -171,7 +290,8 @@ func (l zerologLogLevelEvent) WithDataFn(dataFn func(data MsgData)) LogLevelEven
 	evt := &zerologLogData{Event: zerolog.Dict()}
 	dataFn(evt)
 	return &zerologLogLevelEvent{
-		Event: l.Event.Dict("data", evt.Event),
+		Event:   l.Event.Dict("data", evt.Event),
+		adapter: l.adapter,
 	}
 }
 
@@ -182,12 +302,24 @@ func (e zerologLogLevelEvent) WithData(data MsgData) LogLevelEvent {
 	}
 
 	return &zerologLogLevelEvent{
-		Event: e.Event.Dict("data", zerologData.Event),
+		Event:   e.Event.Dict("data", zerologData.Event),
+		adapter: e.adapter,
 	}
 }
 
 func (e zerologLogLevelEvent) WithError(err error) LogLevelEvent {
-	return &zerologLogLevelEvent{Event: e.Event.Err(err)}
+	return &zerologLogLevelEvent{Event: e.Event.Err(err), adapter: e.adapter}
+}
+
+func (e zerologLogLevelEvent) WithHTTPRequest(info HTTPRequestInfo) LogLevelEvent {
+	if e.adapter != nil && e.Event != nil {
+		e.adapter.appendHTTPRequestData(info, zerologLogFieldAppender{Event: e.Event})
+	}
+	return &e
+}
+
+func (d *zerologLogData) Secret(key string, value string) MsgData {
+	return &zerologLogData{Event: d.Event.Str(key, obfuscate(value))}
 }
 
 func (d *zerologLogData) Dict(key string, data MsgData) MsgData {
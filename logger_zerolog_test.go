@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"regexp"
 	"testing"
@@ -14,6 +15,7 @@ import (
 
 	"github.com/gofrs/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type logDataFieldFn[TVal any] func(key string, value TVal) MsgData
@@ -33,6 +35,10 @@ func (m mockCloudPlatformAdapter) appendLevelData(level LogLevel, target logFiel
 	target.Str(m.mockLogKey, m.mockLogLevelValuePrefix+level.String())
 }
 
+func (m mockCloudPlatformAdapter) appendContextData(ContextDiagData, logFieldAppender) {}
+
+func (m mockCloudPlatformAdapter) appendHTTPRequestData(HTTPRequestInfo, logFieldAppender) {}
+
 var _ cloudPlatformAdapter = mockCloudPlatformAdapter{}
 
 func jsonify(data any) any {
@@ -420,366 +426,9 @@ func TestZerolog_LogData(t *testing.T) {
 	})
 
 	t.Run("DataMethods", func(t *testing.T) {
-		type testCase struct {
-			name  string
-			value any
-			fn    logDataFieldFn[any]
-
-			// expectedValue should be provided if serialization makes it different from value
-			expectedValue any
-		}
-
-		type testCaseFn func(data MsgData) testCase
-
-		tests := []testCaseFn{
-			func(data MsgData) testCase {
-				return testCase{
-					name:  "Str",
-					value: fake.Lorem().Sentence(3),
-					fn:    castLotDataFieldFn(data.Str),
-				}
-			},
-			func(data MsgData) testCase {
-				return testCase{
-					name:  "Strs",
-					value: fake.Lorem().Words(3),
-					fn:    castLotDataFieldFn(data.Strs),
-				}
-			},
-			func(data MsgData) testCase {
-				return testCase{
-					name:  "Stringer",
-					value: net.ParseIP(fake.Internet().Ipv4()),
-					fn:    castLotDataFieldFn(data.Stringer),
-				}
-			},
-			func(data MsgData) testCase {
-				value := fake.Lorem().Bytes(10)
-				return testCase{
-					name:          "Bytes",
-					value:         value,
-					expectedValue: string(value),
-					fn:            castLotDataFieldFn(data.Bytes),
-				}
-			},
-			func(data MsgData) testCase {
-				value := fake.Lorem().Bytes(10)
-				return testCase{
-					name:          "Hex",
-					value:         value,
-					expectedValue: hex.EncodeToString(value),
-					fn:            castLotDataFieldFn(data.Hex),
-				}
-			},
-			func(data MsgData) testCase {
-				value := map[string]interface{}{
-					"key1": fake.Lorem().Word(),
-					"key2": fake.Lorem().Word(),
-				}
-				rawJSON, _ := json.Marshal(value)
-				return testCase{
-					name:          "RawJSON",
-					value:         rawJSON,
-					expectedValue: value,
-					fn:            castLotDataFieldFn(data.RawJSON),
-				}
-			},
-			func(data MsgData) testCase {
-				return testCase{
-					name:  "Bool",
-					value: fake.Bool(),
-					fn:    castLotDataFieldFn(data.Bool),
-				}
-			},
-			func(data MsgData) testCase {
-				return testCase{
-					name:  "Bools",
-					value: []bool{fake.Bool(), fake.Bool()},
-					fn:    castLotDataFieldFn(data.Bools),
-				}
-			},
-			func(data MsgData) testCase {
-				return testCase{
-					name:  "Int",
-					value: fake.Int(),
-					fn:    castLotDataFieldFn(data.Int),
-				}
-			},
-			func(data MsgData) testCase {
-				return testCase{
-					name:  "Ints",
-					value: []int{fake.Int(), fake.Int()},
-					fn:    castLotDataFieldFn(data.Ints),
-				}
-			},
-			func(data MsgData) testCase {
-				return testCase{
-					name:  "Int8",
-					value: fake.Int8(),
-					fn:    castLotDataFieldFn(data.Int8),
-				}
-			},
-			func(data MsgData) testCase {
-				return testCase{
-					name:  "Ints8",
-					value: []int8{fake.Int8(), fake.Int8()},
-					fn:    castLotDataFieldFn(data.Ints8),
-				}
-			},
-			func(data MsgData) testCase {
-				return testCase{
-					name:  "Int16",
-					value: fake.Int16(),
-					fn:    castLotDataFieldFn(data.Int16),
-				}
-			},
-			func(data MsgData) testCase {
-				return testCase{
-					name:  "Ints16",
-					value: []int16{fake.Int16(), fake.Int16()},
-					fn:    castLotDataFieldFn(data.Ints16),
-				}
-			},
-			func(data MsgData) testCase {
-				return testCase{
-					name:  "Int32",
-					value: fake.Int32(),
-					fn:    castLotDataFieldFn(data.Int32),
-				}
-			},
-			func(data MsgData) testCase {
-				return testCase{
-					name:  "Ints32",
-					value: []int32{fake.Int32(), fake.Int32()},
-					fn:    castLotDataFieldFn(data.Ints32),
-				}
-			},
-			func(data MsgData) testCase {
-				return testCase{
-					name:  "Int64",
-					value: fake.Int64(),
-					fn:    castLotDataFieldFn(data.Int64),
-				}
-			},
-			func(data MsgData) testCase {
-				return testCase{
-					name:  "Ints64",
-					value: []int64{fake.Int64(), fake.Int64()},
-					fn:    castLotDataFieldFn(data.Ints64),
-				}
-			},
-			func(data MsgData) testCase {
-				return testCase{
-					name:  "Uint",
-					value: fake.UInt(),
-					fn:    castLotDataFieldFn(data.Uint),
-				}
-			},
-			func(data MsgData) testCase {
-				return testCase{
-					name:  "Uints",
-					value: []uint{fake.UInt(), fake.UInt()},
-					fn:    castLotDataFieldFn(data.Uints),
-				}
-			},
-			func(data MsgData) testCase {
-				return testCase{
-					name:  "Uint8",
-					value: fake.UInt8(),
-					fn:    castLotDataFieldFn(data.Uint8),
-				}
-			},
-			func(data MsgData) testCase {
-				value := []uint8{fake.UInt8(), fake.UInt8()}
-				return testCase{
-					name:          "Uints8",
-					value:         value,
-					expectedValue: []interface{}{float64(value[0]), float64(value[1])},
-					fn:            castLotDataFieldFn(data.Uints8),
-				}
-			},
-			func(data MsgData) testCase {
-				return testCase{
-					name:  "Uint16",
-					value: fake.UInt16(),
-					fn:    castLotDataFieldFn(data.Uint16),
-				}
-			},
-			func(data MsgData) testCase {
-				return testCase{
-					name:  "Uints16",
-					value: []uint16{fake.UInt16(), fake.UInt16()},
-					fn:    castLotDataFieldFn(data.Uints16),
-				}
-			},
-			func(data MsgData) testCase {
-				return testCase{
-					name:  "Uint32",
-					value: fake.UInt32(),
-					fn:    castLotDataFieldFn(data.Uint32),
-				}
-			},
-			func(data MsgData) testCase {
-				return testCase{
-					name:  "Uints32",
-					value: []uint32{fake.UInt32(), fake.UInt32()},
-					fn:    castLotDataFieldFn(data.Uints32),
-				}
-			},
-			func(data MsgData) testCase {
-				return testCase{
-					name:  "Uint64",
-					value: fake.UInt64(),
-					fn:    castLotDataFieldFn(data.Uint64),
-				}
-			},
-			func(data MsgData) testCase {
-				return testCase{
-					name:  "Uints64",
-					value: []uint64{fake.UInt64(), fake.UInt64()},
-					fn:    castLotDataFieldFn(data.Uints64),
-				}
-			},
-			func(data MsgData) testCase {
-				return testCase{
-					name:  "Float32",
-					value: fake.Float32(5, 10, 100000),
-					fn:    castLotDataFieldFn(data.Float32),
-				}
-			},
-			func(data MsgData) testCase {
-				return testCase{
-					name:  "Floats32",
-					value: []float32{fake.Float32(5, 10, 100000), fake.Float32(5, 10, 100000)},
-					fn:    castLotDataFieldFn(data.Floats32),
-				}
-			},
-			func(data MsgData) testCase {
-				return testCase{
-					name:  "Float64",
-					value: fake.Float64(5, 10, 100000),
-					fn:    castLotDataFieldFn(data.Float64),
-				}
-			},
-			func(data MsgData) testCase {
-				return testCase{
-					name:  "Floats64",
-					value: []float64{fake.Float64(5, 10, 100000), fake.Float64(5, 10, 100000)},
-					fn:    castLotDataFieldFn(data.Floats64),
-				}
-			},
-			func(data MsgData) testCase {
-				value := fake.Time().Time(time.Now())
-				return testCase{
-					name:          "Time",
-					value:         value,
-					expectedValue: value.Format(time.RFC3339Nano),
-					fn:            castLotDataFieldFn(data.Time),
-				}
-			},
-			func(data MsgData) testCase {
-				value := []time.Time{
-					fake.Time().Time(time.Now()),
-					fake.Time().Time(time.Now()),
-				}
-				return testCase{
-					name:  "Times",
-					value: value,
-					expectedValue: []interface{}{
-						value[0].Format(time.RFC3339Nano),
-						value[1].Format(time.RFC3339Nano),
-					},
-					fn: castLotDataFieldFn(data.Times),
-				}
-			},
-			func(data MsgData) testCase {
-				value := net.ParseIP(fake.Internet().Ipv4())
-				return testCase{
-					name:  "IPAddr",
-					value: value,
-					fn:    castLotDataFieldFn(data.IPAddr),
-				}
-			},
-			func(data MsgData) testCase {
-				ip := fake.Internet().Ipv4()
-				mask := fake.IntBetween(8, 32)
-				_, addr, err := net.ParseCIDR(fmt.Sprintf("%v/%v", ip, mask))
-				if err != nil {
-					panic(err)
-				}
-				return testCase{
-					name:          "IPPrefix",
-					value:         *addr,
-					expectedValue: addr.String(),
-					fn:            castLotDataFieldFn(data.IPPrefix),
-				}
-			},
-			func(data MsgData) testCase {
-				mac, err := net.ParseMAC(fake.Internet().MacAddress())
-				if err != nil {
-					panic(err)
-				}
-				return testCase{
-					name:          "MACAddr",
-					value:         mac,
-					expectedValue: mac.String(),
-					fn:            castLotDataFieldFn(data.MACAddr),
-				}
-			},
-			func(data MsgData) testCase {
-				val1 := fake.Lorem().Word()
-				val2 := fake.Lorem().Word()
-				dict := logger.NewData().
-					Str("key1", val1).
-					Str("key2", val2)
-				return testCase{
-					name:  "Dict",
-					value: dict,
-					expectedValue: map[string]interface{}{
-						"key1": val1,
-						"key2": val2,
-					},
-					fn: castLotDataFieldFn(data.Dict),
-				}
-			},
-			func(data MsgData) testCase {
-				value := map[string]interface{}{
-					"key1": fake.Lorem().Word(),
-					"key2": fake.Lorem().Word(),
-				}
-				return testCase{
-					name:  "Interface",
-					value: value,
-					fn:    castLotDataFieldFn(data.Interface),
-				}
-			},
-		}
-
-		for _, test := range tests {
-			data := logger.NewData()
-			tt := test(data)
-			t.Run(tt.name, func(t *testing.T) {
-				output.Reset()
-				wantKey := fake.Lorem().Word()
-				wantValue := tt.value
-				data := tt.fn(wantKey, wantValue)
-				if tt.expectedValue != nil {
-					wantValue = tt.expectedValue
-				} else {
-					wantValue = jsonify(tt.value)
-				}
-				logger.Info().WithData(data).Msg(fake.Lorem().Sentence(3))
-
-				outputWriter.Flush()
-				var logMessage map[string]interface{}
-				json.Unmarshal(output.Bytes(), &logMessage)
-
-				gotData := logMessage["data"].(map[string]interface{})
-				assert.Equal(t, map[string]interface{}{
-					wantKey: wantValue,
-				}, gotData)
-			})
-		}
+		testLogDataMethods(t, func(out io.Writer) LevelLogger {
+			return factory.NewLogger(&rootContextParams{Out: out, LogLevel: LogLevelDebugValue})
+		}, decodeLogEntryData)
 	})
 
 	t.Run("WithDataFn", func(t *testing.T) {
@@ -828,3 +477,378 @@ func TestZerolog_LogData(t *testing.T) {
 		assert.Equal(t, wantErr.Error(), logMessage["error"])
 	})
 }
+
+// testLogDataMethods logs a value with each MsgData method using the logger created by newLogger
+// and compares it with the data decoded from the output by decodeData.
+// It is shared by zerolog and slog tests so both loggers are checked with the same cases.
+func testLogDataMethods(
+	t *testing.T,
+	newLogger func(out io.Writer) LevelLogger,
+	decodeData func(t *testing.T, output []byte) map[string]interface{},
+) {
+	type testCase struct {
+		name  string
+		value any
+		fn    logDataFieldFn[any]
+
+		// expectedValue should be provided if serialization makes it different from value
+		expectedValue any
+	}
+
+	type testCaseFn func(data MsgData) testCase
+
+	var output bytes.Buffer
+	logger := newLogger(&output)
+	tests := []testCaseFn{
+		func(data MsgData) testCase {
+			return testCase{
+				name:  "Str",
+				value: fake.Lorem().Sentence(3),
+				fn:    castLotDataFieldFn(data.Str),
+			}
+		},
+		func(data MsgData) testCase {
+			return testCase{
+				name:  "Strs",
+				value: fake.Lorem().Words(3),
+				fn:    castLotDataFieldFn(data.Strs),
+			}
+		},
+		func(data MsgData) testCase {
+			return testCase{
+				name:  "Stringer",
+				value: net.ParseIP(fake.Internet().Ipv4()),
+				fn:    castLotDataFieldFn(data.Stringer),
+			}
+		},
+		func(data MsgData) testCase {
+			value := fake.Lorem().Bytes(10)
+			return testCase{
+				name:          "Bytes",
+				value:         value,
+				expectedValue: string(value),
+				fn:            castLotDataFieldFn(data.Bytes),
+			}
+		},
+		func(data MsgData) testCase {
+			value := fake.Lorem().Bytes(10)
+			return testCase{
+				name:          "Hex",
+				value:         value,
+				expectedValue: hex.EncodeToString(value),
+				fn:            castLotDataFieldFn(data.Hex),
+			}
+		},
+		func(data MsgData) testCase {
+			value := map[string]interface{}{
+				"key1": fake.Lorem().Word(),
+				"key2": fake.Lorem().Word(),
+			}
+			rawJSON, _ := json.Marshal(value)
+			return testCase{
+				name:          "RawJSON",
+				value:         rawJSON,
+				expectedValue: value,
+				fn:            castLotDataFieldFn(data.RawJSON),
+			}
+		},
+		func(data MsgData) testCase {
+			return testCase{
+				name:  "Bool",
+				value: fake.Bool(),
+				fn:    castLotDataFieldFn(data.Bool),
+			}
+		},
+		func(data MsgData) testCase {
+			return testCase{
+				name:  "Bools",
+				value: []bool{fake.Bool(), fake.Bool()},
+				fn:    castLotDataFieldFn(data.Bools),
+			}
+		},
+		func(data MsgData) testCase {
+			return testCase{
+				name:  "Int",
+				value: fake.Int(),
+				fn:    castLotDataFieldFn(data.Int),
+			}
+		},
+		func(data MsgData) testCase {
+			return testCase{
+				name:  "Ints",
+				value: []int{fake.Int(), fake.Int()},
+				fn:    castLotDataFieldFn(data.Ints),
+			}
+		},
+		func(data MsgData) testCase {
+			return testCase{
+				name:  "Int8",
+				value: fake.Int8(),
+				fn:    castLotDataFieldFn(data.Int8),
+			}
+		},
+		func(data MsgData) testCase {
+			return testCase{
+				name:  "Ints8",
+				value: []int8{fake.Int8(), fake.Int8()},
+				fn:    castLotDataFieldFn(data.Ints8),
+			}
+		},
+		func(data MsgData) testCase {
+			return testCase{
+				name:  "Int16",
+				value: fake.Int16(),
+				fn:    castLotDataFieldFn(data.Int16),
+			}
+		},
+		func(data MsgData) testCase {
+			return testCase{
+				name:  "Ints16",
+				value: []int16{fake.Int16(), fake.Int16()},
+				fn:    castLotDataFieldFn(data.Ints16),
+			}
+		},
+		func(data MsgData) testCase {
+			return testCase{
+				name:  "Int32",
+				value: fake.Int32(),
+				fn:    castLotDataFieldFn(data.Int32),
+			}
+		},
+		func(data MsgData) testCase {
+			return testCase{
+				name:  "Ints32",
+				value: []int32{fake.Int32(), fake.Int32()},
+				fn:    castLotDataFieldFn(data.Ints32),
+			}
+		},
+		func(data MsgData) testCase {
+			return testCase{
+				name:  "Int64",
+				value: fake.Int64(),
+				fn:    castLotDataFieldFn(data.Int64),
+			}
+		},
+		func(data MsgData) testCase {
+			return testCase{
+				name:  "Ints64",
+				value: []int64{fake.Int64(), fake.Int64()},
+				fn:    castLotDataFieldFn(data.Ints64),
+			}
+		},
+		func(data MsgData) testCase {
+			return testCase{
+				name:  "Uint",
+				value: fake.UInt(),
+				fn:    castLotDataFieldFn(data.Uint),
+			}
+		},
+		func(data MsgData) testCase {
+			return testCase{
+				name:  "Uints",
+				value: []uint{fake.UInt(), fake.UInt()},
+				fn:    castLotDataFieldFn(data.Uints),
+			}
+		},
+		func(data MsgData) testCase {
+			return testCase{
+				name:  "Uint8",
+				value: fake.UInt8(),
+				fn:    castLotDataFieldFn(data.Uint8),
+			}
+		},
+		func(data MsgData) testCase {
+			value := []uint8{fake.UInt8(), fake.UInt8()}
+			return testCase{
+				name:          "Uints8",
+				value:         value,
+				expectedValue: []interface{}{float64(value[0]), float64(value[1])},
+				fn:            castLotDataFieldFn(data.Uints8),
+			}
+		},
+		func(data MsgData) testCase {
+			return testCase{
+				name:  "Uint16",
+				value: fake.UInt16(),
+				fn:    castLotDataFieldFn(data.Uint16),
+			}
+		},
+		func(data MsgData) testCase {
+			return testCase{
+				name:  "Uints16",
+				value: []uint16{fake.UInt16(), fake.UInt16()},
+				fn:    castLotDataFieldFn(data.Uints16),
+			}
+		},
+		func(data MsgData) testCase {
+			return testCase{
+				name:  "Uint32",
+				value: fake.UInt32(),
+				fn:    castLotDataFieldFn(data.Uint32),
+			}
+		},
+		func(data MsgData) testCase {
+			return testCase{
+				name:  "Uints32",
+				value: []uint32{fake.UInt32(), fake.UInt32()},
+				fn:    castLotDataFieldFn(data.Uints32),
+			}
+		},
+		func(data MsgData) testCase {
+			return testCase{
+				name:  "Uint64",
+				value: fake.UInt64(),
+				fn:    castLotDataFieldFn(data.Uint64),
+			}
+		},
+		func(data MsgData) testCase {
+			return testCase{
+				name:  "Uints64",
+				value: []uint64{fake.UInt64(), fake.UInt64()},
+				fn:    castLotDataFieldFn(data.Uints64),
+			}
+		},
+		func(data MsgData) testCase {
+			return testCase{
+				name:  "Float32",
+				value: fake.Float32(5, 10, 100000),
+				fn:    castLotDataFieldFn(data.Float32),
+			}
+		},
+		func(data MsgData) testCase {
+			return testCase{
+				name:  "Floats32",
+				value: []float32{fake.Float32(5, 10, 100000), fake.Float32(5, 10, 100000)},
+				fn:    castLotDataFieldFn(data.Floats32),
+			}
+		},
+		func(data MsgData) testCase {
+			return testCase{
+				name:  "Float64",
+				value: fake.Float64(5, 10, 100000),
+				fn:    castLotDataFieldFn(data.Float64),
+			}
+		},
+		func(data MsgData) testCase {
+			return testCase{
+				name:  "Floats64",
+				value: []float64{fake.Float64(5, 10, 100000), fake.Float64(5, 10, 100000)},
+				fn:    castLotDataFieldFn(data.Floats64),
+			}
+		},
+		func(data MsgData) testCase {
+			value := fake.Time().Time(time.Now())
+			return testCase{
+				name:          "Time",
+				value:         value,
+				expectedValue: value.Format(time.RFC3339Nano),
+				fn:            castLotDataFieldFn(data.Time),
+			}
+		},
+		func(data MsgData) testCase {
+			value := []time.Time{
+				fake.Time().Time(time.Now()),
+				fake.Time().Time(time.Now()),
+			}
+			return testCase{
+				name:  "Times",
+				value: value,
+				expectedValue: []interface{}{
+					value[0].Format(time.RFC3339Nano),
+					value[1].Format(time.RFC3339Nano),
+				},
+				fn: castLotDataFieldFn(data.Times),
+			}
+		},
+		func(data MsgData) testCase {
+			value := net.ParseIP(fake.Internet().Ipv4())
+			return testCase{
+				name:  "IPAddr",
+				value: value,
+				fn:    castLotDataFieldFn(data.IPAddr),
+			}
+		},
+		func(data MsgData) testCase {
+			ip := fake.Internet().Ipv4()
+			mask := fake.IntBetween(8, 32)
+			_, addr, err := net.ParseCIDR(fmt.Sprintf("%v/%v", ip, mask))
+			if err != nil {
+				panic(err)
+			}
+			return testCase{
+				name:          "IPPrefix",
+				value:         *addr,
+				expectedValue: addr.String(),
+				fn:            castLotDataFieldFn(data.IPPrefix),
+			}
+		},
+		func(data MsgData) testCase {
+			mac, err := net.ParseMAC(fake.Internet().MacAddress())
+			if err != nil {
+				panic(err)
+			}
+			return testCase{
+				name:          "MACAddr",
+				value:         mac,
+				expectedValue: mac.String(),
+				fn:            castLotDataFieldFn(data.MACAddr),
+			}
+		},
+		func(data MsgData) testCase {
+			val1 := fake.Lorem().Word()
+			val2 := fake.Lorem().Word()
+			dict := logger.NewData().
+				Str("key1", val1).
+				Str("key2", val2)
+			return testCase{
+				name:  "Dict",
+				value: dict,
+				expectedValue: map[string]interface{}{
+					"key1": val1,
+					"key2": val2,
+				},
+				fn: castLotDataFieldFn(data.Dict),
+			}
+		},
+		func(data MsgData) testCase {
+			value := map[string]interface{}{
+				"key1": fake.Lorem().Word(),
+				"key2": fake.Lorem().Word(),
+			}
+			return testCase{
+				name:  "Interface",
+				value: value,
+				fn:    castLotDataFieldFn(data.Interface),
+			}
+		},
+	}
+
+	for _, test := range tests {
+		data := logger.NewData()
+		tt := test(data)
+		t.Run(tt.name, func(t *testing.T) {
+			output.Reset()
+			wantKey := fake.Lorem().Word()
+			wantValue := tt.value
+			data := tt.fn(wantKey, wantValue)
+			if tt.expectedValue != nil {
+				wantValue = tt.expectedValue
+			} else {
+				wantValue = jsonify(tt.value)
+			}
+			logger.Info().WithData(data).Msg(fake.Lorem().Sentence(3))
+
+			assert.Equal(t, map[string]interface{}{
+				wantKey: wantValue,
+			}, decodeData(t, output.Bytes()))
+		})
+	}
+}
+
+// decodeLogEntryData returns data of a single JSON log entry
+func decodeLogEntryData(t *testing.T, output []byte) map[string]interface{} {
+	var logMessage map[string]interface{}
+	require.NoError(t, json.Unmarshal(output, &logMessage))
+	gotData, _ := logMessage["data"].(map[string]interface{})
+	return gotData
+}
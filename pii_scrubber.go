@@ -0,0 +1,363 @@
+package diag
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// PIIDetector finds values of a certain kind (e.g. emails) in a log string
+type PIIDetector struct {
+	// Name is used in the mask and in the scrubber stats
+	Name string
+
+	// Precheck is an optional cheap check (e.g. the value contains @)
+	// to skip the pattern matching of values that can not match
+	Precheck func(value string) bool
+
+	// Pattern matches candidate values
+	Pattern *regexp.Regexp
+
+	// Validate is an optional check of a matched value (e.g. checksum),
+	// the value is not masked if it returns false
+	Validate func(match string) bool
+}
+
+var (
+	piiEmailPattern      = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`)
+	piiCardNumberPattern = regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`)
+	piiJWTPattern        = regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]+\.eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]*`)
+	piiBearerPattern     = regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`)
+	piiIBANPattern       = regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]){11,30}\b`)
+	piiAWSKeyPattern     = regexp.MustCompile(`\b(?:AKIA|ASIA|AIDA|AROA)[A-Z0-9]{16}\b`)
+)
+
+// PIIDetectorEmail detects email addresses
+func PIIDetectorEmail() PIIDetector {
+	return PIIDetector{
+		Name:     "email",
+		Precheck: func(value string) bool { return strings.IndexByte(value, '@') >= 0 },
+		Pattern:  piiEmailPattern,
+	}
+}
+
+// PIIDetectorCardNumber detects Luhn valid card numbers of 13-19 digits separated by spaces
+// or dashes. Numbers without separators are detected only if they have a known issuer prefix
+// and length, so ids and timestamps that happen to be Luhn valid are not masked.
+func PIIDetectorCardNumber() PIIDetector {
+	return PIIDetector{
+		Name:     "card",
+		Precheck: func(value string) bool { return countDigits(value) >= 13 },
+		Pattern:  piiCardNumberPattern,
+		Validate: isCardNumber,
+	}
+}
+
+// PIIDetectorJWT detects JSON web tokens
+func PIIDetectorJWT() PIIDetector {
+	return PIIDetector{
+		Name:     "jwt",
+		Precheck: func(value string) bool { return strings.Contains(value, "eyJ") },
+		Pattern:  piiJWTPattern,
+	}
+}
+
+// PIIDetectorBearerToken detects bearer tokens (e.g. Bearer abc) of any format
+func PIIDetectorBearerToken() PIIDetector {
+	return PIIDetector{
+		Name:     "bearer",
+		Precheck: func(value string) bool { return strings.Contains(value, "earer") || strings.Contains(value, "EARER") },
+		Pattern:  piiBearerPattern,
+	}
+}
+
+// PIIDetectorIBAN detects IBANs with a valid checksum, optionally grouped by spaces
+func PIIDetectorIBAN() PIIDetector {
+	return PIIDetector{
+		Name:     "iban",
+		Precheck: func(value string) bool { return countDigits(value) >= 4 },
+		Pattern:  piiIBANPattern,
+		Validate: isIBANValid,
+	}
+}
+
+// PIIDetectorAWSAccessKey detects AWS access key ids
+func PIIDetectorAWSAccessKey() PIIDetector {
+	return PIIDetector{
+		Name:     "aws-key",
+		Precheck: func(value string) bool { return len(value) >= 20 && strings.Contains(value, "A") },
+		Pattern:  piiAWSKeyPattern,
+	}
+}
+
+// DefaultPIIDetectors returns all the builtin detectors. Token detectors go first
+// so tokens containing digits are masked as a whole.
+func DefaultPIIDetectors() []PIIDetector {
+	return []PIIDetector{
+		PIIDetectorJWT(),
+		PIIDetectorBearerToken(),
+		PIIDetectorAWSAccessKey(),
+		PIIDetectorIBAN(),
+		PIIDetectorEmail(),
+		PIIDetectorCardNumber(),
+	}
+}
+
+func countDigits(value string) int {
+	count := 0
+	for i := 0; i < len(value); i++ {
+		if value[i] >= '0' && value[i] <= '9' {
+			count++
+		}
+	}
+	return count
+}
+
+func removeSeparators(value string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(value)
+}
+
+func isCardNumber(value string) bool {
+	if !isLuhnValid(value) {
+		return false
+	}
+	return strings.ContainsAny(value, " -") || hasCardIssuerPrefix(value)
+}
+
+// cardIssuer describes a range of card number prefixes of a given length
+type cardIssuer struct {
+	prefixLen int
+	minPrefix int
+	maxPrefix int
+	minLength int
+	maxLength int
+}
+
+// cardIssuers lists the major card networks:
+// Visa, Mastercard, American Express, Discover, JCB, Diners Club and UnionPay
+var cardIssuers = []cardIssuer{
+	{prefixLen: 1, minPrefix: 4, maxPrefix: 4, minLength: 13, maxLength: 19},
+	{prefixLen: 2, minPrefix: 51, maxPrefix: 55, minLength: 16, maxLength: 16},
+	{prefixLen: 4, minPrefix: 2221, maxPrefix: 2720, minLength: 16, maxLength: 16},
+	{prefixLen: 2, minPrefix: 34, maxPrefix: 34, minLength: 15, maxLength: 15},
+	{prefixLen: 2, minPrefix: 37, maxPrefix: 37, minLength: 15, maxLength: 15},
+	{prefixLen: 4, minPrefix: 6011, maxPrefix: 6011, minLength: 16, maxLength: 19},
+	{prefixLen: 3, minPrefix: 644, maxPrefix: 649, minLength: 16, maxLength: 19},
+	{prefixLen: 2, minPrefix: 65, maxPrefix: 65, minLength: 16, maxLength: 19},
+	{prefixLen: 4, minPrefix: 3528, maxPrefix: 3589, minLength: 16, maxLength: 19},
+	{prefixLen: 2, minPrefix: 36, maxPrefix: 36, minLength: 14, maxLength: 19},
+	{prefixLen: 3, minPrefix: 300, maxPrefix: 305, minLength: 14, maxLength: 19},
+	{prefixLen: 2, minPrefix: 62, maxPrefix: 62, minLength: 16, maxLength: 19},
+}
+
+func hasCardIssuerPrefix(digits string) bool {
+	for _, issuer := range cardIssuers {
+		if len(digits) < issuer.minLength || len(digits) > issuer.maxLength {
+			continue
+		}
+		prefix, err := strconv.Atoi(digits[:issuer.prefixLen])
+		if err == nil && prefix >= issuer.minPrefix && prefix <= issuer.maxPrefix {
+			return true
+		}
+	}
+	return false
+}
+
+func isLuhnValid(value string) bool {
+	digits := removeSeparators(value)
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		digit := int(digits[i] - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+func isIBANValid(value string) bool {
+	iban := removeSeparators(value)
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+	var numeric strings.Builder
+	for _, c := range iban[4:] + iban[:4] {
+		if c >= 'A' && c <= 'Z' {
+			numeric.WriteString(fmt.Sprint(c - 'A' + 10))
+		} else {
+			numeric.WriteRune(c)
+		}
+	}
+	n, ok := new(big.Int).SetString(numeric.String(), 10)
+	return ok && n.Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+// PIIScrubber masks values found by detectors in log messages, errors and string data.
+// Masked values are counted per detector. Nil scrubber does nothing.
+type PIIScrubber struct {
+	detectors []PIIDetector
+	counts    []atomic.Uint64
+}
+
+// NewPIIScrubber creates a scrubber with given detectors,
+// DefaultPIIDetectors are used if no detectors provided
+func NewPIIScrubber(detectors ...PIIDetector) *PIIScrubber {
+	if len(detectors) == 0 {
+		detectors = DefaultPIIDetectors()
+	}
+	for _, detector := range detectors {
+		if detector.Name == "" || detector.Pattern == nil {
+			panic(fmt.Errorf("PII detector name and pattern are required"))
+		}
+	}
+	return &PIIScrubber{
+		detectors: detectors,
+		counts:    make([]atomic.Uint64, len(detectors)),
+	}
+}
+
+// Scrub returns the value with all the detected values replaced with *redacted:<detector name>*
+func (s *PIIScrubber) Scrub(value string) string {
+	if s == nil {
+		return value
+	}
+	for i := range s.detectors {
+		detector := &s.detectors[i]
+		if detector.Precheck != nil && !detector.Precheck(value) {
+			continue
+		}
+		if !detector.Pattern.MatchString(value) {
+			continue
+		}
+		value = detector.Pattern.ReplaceAllStringFunc(value, func(match string) string {
+			if detector.Validate != nil && !detector.Validate(match) {
+				return match
+			}
+			s.counts[i].Add(1)
+			return "*redacted:" + detector.Name + "*"
+		})
+	}
+	return value
+}
+
+// Stats returns number of values masked by each detector
+func (s *PIIScrubber) Stats() map[string]uint64 {
+	if s == nil {
+		return map[string]uint64{}
+	}
+	stats := make(map[string]uint64, len(s.detectors))
+	for i, detector := range s.detectors {
+		stats[detector.Name] += s.counts[i].Load()
+	}
+	return stats
+}
+
+// Scrubbed returns total number of masked values
+func (s *PIIScrubber) Scrubbed() uint64 {
+	var total uint64
+	if s == nil {
+		return total
+	}
+	for i := range s.counts {
+		total += s.counts[i].Load()
+	}
+	return total
+}
+
+// scrubStringMap returns the values map or its scrubbed copy if any value was scrubbed
+func (s *PIIScrubber) scrubStringMap(values map[string]string) map[string]string {
+	var scrubbed map[string]string
+	for key, value := range values {
+		if scrubbedValue := s.Scrub(value); scrubbedValue != value {
+			if scrubbed == nil {
+				scrubbed = make(map[string]string, len(values))
+				for k, v := range values {
+					scrubbed[k] = v
+				}
+			}
+			scrubbed[key] = scrubbedValue
+		}
+	}
+	if scrubbed == nil {
+		return values
+	}
+	return scrubbed
+}
+
+// scrubStrings returns the values or their scrubbed copy if any value was scrubbed
+func (s *PIIScrubber) scrubStrings(values []string) []string {
+	var scrubbed []string
+	for i, value := range values {
+		if scrubbedValue := s.Scrub(value); scrubbedValue != value {
+			if scrubbed == nil {
+				scrubbed = append([]string{}, values...)
+			}
+			scrubbed[i] = scrubbedValue
+		}
+	}
+	if scrubbed == nil {
+		return values
+	}
+	return scrubbed
+}
+
+// scrubJSON scrubs string values of a JSON document, returns false if nothing was masked
+func (s *PIIScrubber) scrubJSON(data []byte) ([]byte, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return data, false
+	}
+	value, changed := s.scrubJSONValue(value)
+	if !changed {
+		return data, false
+	}
+	scrubbed, err := json.Marshal(value)
+	if err != nil {
+		return data, false
+	}
+	return scrubbed, true
+}
+
+func (s *PIIScrubber) scrubJSONValue(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case string:
+		scrubbed := s.Scrub(v)
+		return scrubbed, scrubbed != v
+	case map[string]interface{}:
+		changed := false
+		for key, item := range v {
+			if scrubbed, itemChanged := s.scrubJSONValue(item); itemChanged {
+				v[key] = scrubbed
+				changed = true
+			}
+		}
+		return v, changed
+	case []interface{}:
+		changed := false
+		for i, item := range v {
+			if scrubbed, itemChanged := s.scrubJSONValue(item); itemChanged {
+				v[i] = scrubbed
+				changed = true
+			}
+		}
+		return v, changed
+	default:
+		return value, false
+	}
+}
@@ -0,0 +1,398 @@
+package diag
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatingFileBackupTimeFormat is used in names of the rotated files, e.g. app-2024-01-02T15-04-05.000.log
+const rotatingFileBackupTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotatingFile is a log output that writes to a file and rotates it by size and/or time.
+// Rotated files are renamed to <name>-<timestamp><ext> and optionally gzip compressed in background,
+// a sequence suffix is added if a file rotated at the same time exists, e.g. app-<timestamp>-1.log.
+// Rotations are reported via the root context logger if the file is the root context output or sink.
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	interval   time.Duration
+	maxBackups int
+	compress   bool
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	closing  bool
+	now      func() time.Time
+
+	// background holds goroutines processing the rotated files, backupsMu serializes them
+	background sync.WaitGroup
+	backupsMu  sync.Mutex
+
+	// logger is used to report rotations, set by RootContext
+	logger LevelLogger
+}
+
+// RotatingFileOpt is a functional option for configuring the rotating file
+type RotatingFileOpt func(f *RotatingFile)
+
+// WithRotateMaxSize rotates the file before it grows over maxSize bytes
+func WithRotateMaxSize(maxSize int64) RotatingFileOpt {
+	return func(f *RotatingFile) {
+		if maxSize <= 0 {
+			panic(fmt.Errorf("rotate max size must be positive, got %d", maxSize))
+		}
+		f.maxSize = maxSize
+	}
+}
+
+// WithRotateInterval rotates the file if it was opened more than interval ago
+func WithRotateInterval(interval time.Duration) RotatingFileOpt {
+	return func(f *RotatingFile) {
+		if interval <= 0 {
+			panic(fmt.Errorf("rotate interval must be positive, got %s", interval))
+		}
+		f.interval = interval
+	}
+}
+
+// WithRotateMaxBackups keeps up to maxBackups most recent rotated files, all are kept by default
+func WithRotateMaxBackups(maxBackups int) RotatingFileOpt {
+	return func(f *RotatingFile) {
+		if maxBackups <= 0 {
+			panic(fmt.Errorf("rotate max backups must be positive, got %d", maxBackups))
+		}
+		f.maxBackups = maxBackups
+	}
+}
+
+// WithRotateCompress gzip compresses the rotated files in background
+func WithRotateCompress() RotatingFileOpt {
+	return func(f *RotatingFile) {
+		f.compress = true
+	}
+}
+
+// NewRotatingFile opens the file for appending, the file is created if it does not exist.
+// The file is not rotated if no rotation options are provided.
+func NewRotatingFile(path string, opts ...RotatingFileOpt) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, now: time.Now}
+	for _, opt := range opts {
+		opt(f)
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open must be called with the mu locked or before the file is shared
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		f.file = nil
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return errors.Join(fmt.Errorf("failed to stat log file: %w", err), file.Close())
+	}
+	f.file = file
+	f.size = info.Size()
+	f.openedAt = f.now()
+	return nil
+}
+
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if reason := f.rotateReason(len(p)); reason != "" {
+		if err := f.rotate(reason); err != nil {
+			fmt.Fprintf(os.Stderr, "diag: could not rotate log file: %v\n", err)
+		}
+	}
+	if f.file == nil {
+		return 0, fmt.Errorf("log file %s is not open", f.path)
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotateReason must be called with the mu locked, returns empty string if rotation is not needed
+func (f *RotatingFile) rotateReason(size int) string {
+	if f.file == nil || f.closing {
+		return ""
+	}
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(size) > f.maxSize {
+		return "size"
+	}
+	if f.interval > 0 && f.now().Sub(f.openedAt) >= f.interval {
+		return "interval"
+	}
+	return ""
+}
+
+// rotate must be called with the mu locked
+func (f *RotatingFile) rotate(reason string) error {
+	backup, err := f.backupPath(f.now())
+	if err != nil {
+		return err
+	}
+	closeErr := f.file.Close()
+	renameErr := os.Rename(f.path, backup)
+	if err := f.open(); err != nil {
+		return err
+	}
+	if err := errors.Join(closeErr, renameErr); err != nil {
+		return err
+	}
+	f.background.Add(1)
+	go f.processBackup(backup, reason)
+	return nil
+}
+
+func (f *RotatingFile) backupNameParts() (dir, prefix, ext string) {
+	dir, base := filepath.Split(f.path)
+	ext = filepath.Ext(base)
+	return dir, strings.TrimSuffix(base, ext) + "-", ext
+}
+
+// backupPath returns a path of the rotated file that does not exist yet, including the compressed one
+func (f *RotatingFile) backupPath(rotatedAt time.Time) (string, error) {
+	dir, prefix, ext := f.backupNameParts()
+	name := prefix + rotatedAt.UTC().Format(rotatingFileBackupTimeFormat)
+	for seq := 0; ; seq++ {
+		backup := filepath.Join(dir, name+ext)
+		if seq > 0 {
+			backup = filepath.Join(dir, name+"-"+strconv.Itoa(seq)+ext)
+		}
+		exists, err := fileExists(backup)
+		if err == nil && !exists {
+			exists, err = fileExists(backup + ".gz")
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to check rotated log file: %w", err)
+		}
+		if !exists {
+			return backup, nil
+		}
+	}
+}
+
+func fileExists(path string) (bool, error) {
+	_, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// parseBackupName returns the rotation time and sequence of the rotated file name without the extension
+func parseBackupName(name string) (time.Time, int, bool) {
+	if len(name) < len(rotatingFileBackupTimeFormat) {
+		return time.Time{}, 0, false
+	}
+	rotatedAt, err := time.Parse(rotatingFileBackupTimeFormat, name[:len(rotatingFileBackupTimeFormat)])
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	suffix := name[len(rotatingFileBackupTimeFormat):]
+	if suffix == "" {
+		return rotatedAt, 0, true
+	}
+	seq, err := strconv.Atoi(strings.TrimPrefix(suffix, "-"))
+	if err != nil || !strings.HasPrefix(suffix, "-") || seq <= 0 {
+		return time.Time{}, 0, false
+	}
+	return rotatedAt, seq, true
+}
+
+// processBackup compresses the rotated file, removes old backups and reports the rotation
+func (f *RotatingFile) processBackup(backup string, reason string) {
+	defer f.background.Done()
+	f.backupsMu.Lock()
+	if f.compress {
+		if compressed, err := compressFile(backup); err != nil {
+			fmt.Fprintf(os.Stderr, "diag: could not compress rotated log file: %v\n", err)
+		} else {
+			backup = compressed
+		}
+	}
+	f.removeOldBackups()
+	f.backupsMu.Unlock()
+
+	f.report(func(logger LevelLogger) {
+		logger.Info().
+			WithDataFn(func(data MsgData) {
+				data.Str("file", f.path).
+					Str("backup", backup).
+					Str("reason", reason)
+			}).
+			Msg("Log file rotated")
+	})
+}
+
+func compressFile(path string) (string, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	compressedPath := path + ".gz"
+	dst, err := os.OpenFile(compressedPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return "", errors.Join(err, src.Close())
+	}
+	gzipWriter := gzip.NewWriter(dst)
+	_, err = io.Copy(gzipWriter, src)
+	if err = errors.Join(err, gzipWriter.Close(), dst.Close(), src.Close()); err != nil {
+		return "", errors.Join(err, os.Remove(compressedPath))
+	}
+	return compressedPath, os.Remove(path)
+}
+
+// removeOldBackups must be called with the backupsMu locked
+func (f *RotatingFile) removeOldBackups() {
+	if f.maxBackups <= 0 {
+		return
+	}
+	dir, prefix, ext := f.backupNameParts()
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "diag: could not list rotated log files: %v\n", err)
+		return
+	}
+	type backupKey struct {
+		rotatedAt time.Time
+		seq       int
+	}
+	backups := map[backupKey][]string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		trimmed := strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ext)
+		if !strings.HasPrefix(trimmed, prefix) {
+			continue
+		}
+		rotatedAt, seq, ok := parseBackupName(strings.TrimPrefix(trimmed, prefix))
+		if !ok {
+			continue
+		}
+		key := backupKey{rotatedAt: rotatedAt, seq: seq}
+		backups[key] = append(backups[key], filepath.Join(dir, name))
+	}
+	if len(backups) <= f.maxBackups {
+		return
+	}
+	keys := make([]backupKey, 0, len(backups))
+	for key := range backups {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].rotatedAt.Equal(keys[j].rotatedAt) {
+			return keys[i].rotatedAt.After(keys[j].rotatedAt)
+		}
+		return keys[i].seq > keys[j].seq
+	})
+	for _, key := range keys[f.maxBackups:] {
+		for _, path := range backups[key] {
+			if err := os.Remove(path); err != nil {
+				fmt.Fprintf(os.Stderr, "diag: could not remove rotated log file: %v\n", err)
+			}
+		}
+	}
+}
+
+func (f *RotatingFile) report(write func(logger LevelLogger)) {
+	f.mu.Lock()
+	logger := f.logger
+	f.mu.Unlock()
+	if logger != nil {
+		write(logger)
+	}
+}
+
+func (f *RotatingFile) setLogger(logger LevelLogger) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.logger == nil {
+		f.logger = logger
+	}
+}
+
+// setOutputLogger makes the output report its events such as file rotations via the logger
+func setOutputLogger(out io.Writer, logger LevelLogger) {
+	switch output := out.(type) {
+	case *AsyncWriter:
+		setOutputLogger(output.out, logger)
+	case *RotatingFile:
+		output.setLogger(logger)
+	}
+}
+
+// Reopen closes and opens the file again, use it if the file was moved by external tools such as logrotate
+func (f *RotatingFile) Reopen() error {
+	f.mu.Lock()
+	var closeErr error
+	if f.file != nil {
+		closeErr = f.file.Close()
+	}
+	err := f.open()
+	f.mu.Unlock()
+	if err != nil {
+		return errors.Join(closeErr, err)
+	}
+
+	f.report(func(logger LevelLogger) {
+		logger.Info().
+			WithDataFn(func(data MsgData) {
+				data.Str("file", f.path)
+			}).
+			Msg("Log file reopened")
+	})
+	return closeErr
+}
+
+// Close waits for the rotated files processing and closes the file.
+// The file is closed even if ctx is done before the processing completes, ctx error is returned then.
+func (f *RotatingFile) Close(ctx context.Context) error {
+	f.mu.Lock()
+	f.closing = true
+	f.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		f.background.Wait()
+		close(done)
+	}()
+	var waitErr error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		waitErr = ctx.Err()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return waitErr
+	}
+	err := f.file.Close()
+	f.file = nil
+	return errors.Join(waitErr, err)
+}
+
+var _ OutputCloser = &RotatingFile{}
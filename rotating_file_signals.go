@@ -0,0 +1,34 @@
+//go:build !windows
+
+package diag
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// ReopenOnSignals reopens the file on SIGHUP, the way logrotate notifies about moved files.
+// Returned function stops listening for the signal.
+func (f *RotatingFile) ReopenOnSignals() (stop func()) {
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-signals:
+				if err := f.Reopen(); err != nil {
+					fmt.Fprintf(os.Stderr, "diag: could not reopen log file: %v\n", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
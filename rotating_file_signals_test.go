@@ -0,0 +1,31 @@
+//go:build !windows
+
+package diag
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingFile_ReopenOnSignals(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	file, err := NewRotatingFile(path)
+	require.NoError(t, err)
+	stop := file.ReopenOnSignals()
+	defer stop()
+
+	require.NoError(t, os.Rename(path, path+".1"))
+	assert.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGHUP))
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}, time.Second, time.Millisecond)
+	assert.NoError(t, file.Close(context.Background()))
+}
@@ -0,0 +1,8 @@
+//go:build windows
+
+package diag
+
+// ReopenOnSignals is a noop on windows since there is no SIGHUP signal
+func (f *RotatingFile) ReopenOnSignals() (stop func()) {
+	return func() {}
+}
@@ -0,0 +1,208 @@
+package diag
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingFile(t *testing.T) {
+	ctx := context.Background()
+
+	// newRotatingFile creates a file with a fake clock, returned advance function moves the clock
+	newRotatingFile := func(t *testing.T, opts ...RotatingFileOpt) (*RotatingFile, string, func(time.Duration)) {
+		path := filepath.Join(t.TempDir(), "app.log")
+		file, err := NewRotatingFile(path, opts...)
+		require.NoError(t, err)
+		now := time.Now()
+		file.now = func() time.Time { return now }
+		return file, path, func(d time.Duration) { now = now.Add(d) }
+	}
+	readFile := func(t *testing.T, path string) string {
+		data, err := os.ReadFile(path)
+		require.NoError(t, err)
+		if filepath.Ext(path) == ".gz" {
+			reader, err := gzip.NewReader(bytes.NewReader(data))
+			require.NoError(t, err)
+			data, err = io.ReadAll(reader)
+			require.NoError(t, err)
+		}
+		return string(data)
+	}
+	backups := func(t *testing.T, path string) []string {
+		matches, err := filepath.Glob(filepath.Join(filepath.Dir(path), "app-*.log*"))
+		require.NoError(t, err)
+		sort.Strings(matches)
+		return matches
+	}
+	write := func(t *testing.T, file *RotatingFile, entry string) {
+		_, err := file.Write([]byte(entry))
+		require.NoError(t, err)
+	}
+
+	t.Run("should append to existing file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.log")
+		require.NoError(t, os.WriteFile(path, []byte("existing\n"), 0o600))
+		file, err := NewRotatingFile(path)
+		require.NoError(t, err)
+		write(t, file, "entry\n")
+		assert.NoError(t, file.Close(ctx))
+		assert.Equal(t, "existing\nentry\n", readFile(t, path))
+	})
+
+	t.Run("should rotate by size", func(t *testing.T) {
+		file, path, advance := newRotatingFile(t, WithRotateMaxSize(12))
+		write(t, file, "entry 1\n")
+		advance(time.Millisecond)
+		write(t, file, "entry 2\n")
+		advance(time.Millisecond)
+		write(t, file, "entry 3\n")
+		assert.NoError(t, file.Close(ctx))
+
+		assert.Equal(t, "entry 3\n", readFile(t, path))
+		rotated := backups(t, path)
+		if assert.Len(t, rotated, 2) {
+			assert.Equal(t, "entry 1\n", readFile(t, rotated[0]))
+			assert.Equal(t, "entry 2\n", readFile(t, rotated[1]))
+		}
+	})
+
+	t.Run("should add sequence to backups rotated at the same time", func(t *testing.T) {
+		file, path, _ := newRotatingFile(t, WithRotateMaxSize(8), WithRotateMaxBackups(2))
+		for _, entry := range []string{"entry 1\n", "entry 2\n", "entry 3\n", "entry 4\n"} {
+			write(t, file, entry)
+		}
+		assert.NoError(t, file.Close(ctx))
+
+		assert.Equal(t, "entry 4\n", readFile(t, path))
+		rotated := backups(t, path)
+		if assert.Len(t, rotated, 2) {
+			assert.Equal(t, "entry 2\n", readFile(t, rotated[0]))
+			assert.Regexp(t, `-1\.log$`, rotated[0])
+			assert.Equal(t, "entry 3\n", readFile(t, rotated[1]))
+			assert.Regexp(t, `-2\.log$`, rotated[1])
+		}
+	})
+
+	t.Run("should rotate by interval, compress and keep max backups", func(t *testing.T) {
+		file, path, advance := newRotatingFile(t,
+			WithRotateInterval(time.Hour),
+			WithRotateMaxBackups(2),
+			WithRotateCompress(),
+		)
+		for _, entry := range []string{"entry 1\n", "entry 2\n", "entry 3\n", "entry 4\n"} {
+			write(t, file, entry)
+			advance(time.Hour)
+		}
+		write(t, file, "entry 5\n")
+		assert.NoError(t, file.Close(ctx))
+
+		assert.Equal(t, "entry 5\n", readFile(t, path))
+		rotated := backups(t, path)
+		if assert.Len(t, rotated, 2) {
+			assert.Equal(t, ".gz", filepath.Ext(rotated[0]))
+			assert.Equal(t, "entry 3\n", readFile(t, rotated[0]))
+			assert.Equal(t, "entry 4\n", readFile(t, rotated[1]))
+		}
+	})
+
+	t.Run("should reopen moved file", func(t *testing.T) {
+		file, path, _ := newRotatingFile(t)
+		write(t, file, "entry 1\n")
+		movedPath := path + ".1"
+		require.NoError(t, os.Rename(path, movedPath))
+		write(t, file, "entry 2\n")
+		require.NoError(t, file.Reopen())
+		write(t, file, "entry 3\n")
+		assert.NoError(t, file.Close(ctx))
+
+		assert.Equal(t, "entry 1\nentry 2\n", readFile(t, movedPath))
+		assert.Equal(t, "entry 3\n", readFile(t, path))
+	})
+
+	t.Run("should report rotations via root context logger", func(t *testing.T) {
+		file, path, advance := newRotatingFile(t, WithRotateInterval(time.Hour))
+		rootCtx, shutdown := RootContextWithShutdown(NewRootContextParams().
+			WithLogLevel(LogLevelInfoValue).
+			WithOutput(file))
+		Log(rootCtx).Info().Msg("before rotation")
+		advance(time.Hour)
+		Log(rootCtx).Info().Msg("after rotation")
+		file.background.Wait()
+		assert.NoError(t, file.Reopen())
+		assert.NoError(t, shutdown(ctx))
+
+		rotated := backups(t, path)
+		require.Len(t, rotated, 1)
+		assert.Equal(t, []interface{}{"before rotation"}, logMessages(readLogEntries(t, bytes.NewBufferString(readFile(t, rotated[0])))))
+		entries := readLogEntries(t, bytes.NewBufferString(readFile(t, path)))
+		messages := logMessages(entries)
+		assert.Equal(t, []interface{}{"after rotation", "Log file rotated", "Log file reopened"}, messages[:len(messages)-1])
+		assert.Equal(t, "info", entries[1]["level"])
+		assert.Equal(t, map[string]interface{}{
+			"file":   path,
+			"backup": rotated[0],
+			"reason": "interval",
+		}, entries[1]["data"])
+		_, err := file.Write([]byte("entry\n"))
+		assert.Error(t, err)
+	})
+
+	t.Run("should report rotations of a sink via root context logger", func(t *testing.T) {
+		file, path, advance := newRotatingFile(t, WithRotateInterval(time.Hour))
+		output := &bytes.Buffer{}
+		rootCtx, shutdown := RootContextWithShutdown(NewRootContextParams().
+			WithLogLevel(LogLevelInfoValue).
+			WithOutput(output).
+			WithSink(file))
+		Log(rootCtx).Info().Msg("before rotation")
+		advance(time.Hour)
+		Log(rootCtx).Info().Msg("after rotation")
+		file.background.Wait()
+		assert.NoError(t, shutdown(ctx))
+
+		messages := logMessages(readLogEntries(t, output))
+		assert.Equal(t, []interface{}{"before rotation", "after rotation", "Log file rotated"}, messages[:len(messages)-1])
+		messages = logMessages(readLogEntries(t, bytes.NewBufferString(readFile(t, path))))
+		assert.Equal(t, []interface{}{"after rotation", "Log file rotated"}, messages[:len(messages)-1])
+	})
+
+	t.Run("should close file if ctx is done before processing completes", func(t *testing.T) {
+		file, _, _ := newRotatingFile(t)
+		file.background.Add(1)
+		defer file.background.Done()
+		timeoutCtx, cancel := context.WithTimeout(ctx, time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, file.Close(timeoutCtx), context.DeadlineExceeded)
+		_, err := file.Write([]byte("entry\n"))
+		assert.Error(t, err)
+	})
+
+	t.Run("should fail to open file in missing dir", func(t *testing.T) {
+		_, err := NewRotatingFile(filepath.Join(t.TempDir(), "missing", "app.log"))
+		assert.Error(t, err)
+	})
+
+	t.Run("should panic on invalid options", func(t *testing.T) {
+		assert.Panics(t, func() { WithRotateMaxSize(0)(&RotatingFile{}) })
+		assert.Panics(t, func() { WithRotateInterval(0)(&RotatingFile{}) })
+		assert.Panics(t, func() { WithRotateMaxBackups(0)(&RotatingFile{}) })
+	})
+}
+
+func logMessages(entries []map[string]interface{}) []interface{} {
+	result := make([]interface{}, len(entries))
+	for i, entry := range entries {
+		result[i] = entry["msg"]
+	}
+	return result
+}
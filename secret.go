@@ -0,0 +1,82 @@
+package diag
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+)
+
+// Sensitive holds a value that is obfuscated whenever it is logged, formatted
+// with any fmt verb or marshaled to JSON or text. Use Value to get the real value.
+type Sensitive[T any] struct {
+	value T
+}
+
+// Secret is a sensitive string such as a password or an api key
+type Secret = Sensitive[string]
+
+// NewSensitive wraps the value so it never prints in clear
+func NewSensitive[T any](value T) Sensitive[T] {
+	return Sensitive[T]{value: value}
+}
+
+// NewSecret wraps the string so it never prints in clear
+func NewSecret(value string) Secret {
+	return Secret{value: value}
+}
+
+// obfuscate returns the value in the same format as headers obfuscated by the http middlewares
+func obfuscate(value string) string {
+	return fmt.Sprint("*obfuscated, length=", len(value), "*")
+}
+
+// Value returns the real value
+func (s Sensitive[T]) Value() T {
+	return s.value
+}
+
+// String returns the obfuscated value, length of non string values
+// is a length of their default format
+func (s Sensitive[T]) String() string {
+	switch v := any(s.value).(type) {
+	case string:
+		return obfuscate(v)
+	case []byte:
+		return obfuscate(string(v))
+	default:
+		return obfuscate(fmt.Sprint(v))
+	}
+}
+
+// GoString is used by the %#v verb
+func (s Sensitive[T]) GoString() string {
+	return s.String()
+}
+
+// Format writes the obfuscated value for all the fmt verbs
+func (s Sensitive[T]) Format(f fmt.State, verb rune) {
+	if verb == 'q' {
+		fmt.Fprintf(f, "%q", s.String())
+		return
+	}
+	fmt.Fprint(f, s.String())
+}
+
+func (s Sensitive[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+func (s Sensitive[T]) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// LogValue makes slog handlers write the obfuscated value
+func (s Sensitive[T]) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
+
+var (
+	_ fmt.Formatter  = Secret{}
+	_ json.Marshaler = Secret{}
+	_ slog.LogValuer = Secret{}
+)
@@ -0,0 +1,98 @@
+package diag
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSensitive(t *testing.T) {
+	password := "pwd-" + fake.Lorem().Word()
+	want := fmt.Sprintf("*obfuscated, length=%d*", len(password))
+
+	t.Run("should expose the real value", func(t *testing.T) {
+		assert.Equal(t, password, NewSecret(password).Value())
+		assert.Equal(t, 42, NewSensitive(42).Value())
+	})
+
+	t.Run("should obfuscate with fmt verbs", func(t *testing.T) {
+		secret := NewSecret(password)
+		for _, format := range []string{"%s", "%v", "%+v", "%#v", "%d", "%x", "%10s"} {
+			assert.Equal(t, want, fmt.Sprintf(format, secret), format)
+			assert.Equal(t, want, fmt.Sprintf(format, &secret), format)
+		}
+		assert.Equal(t, fmt.Sprintf("%q", want), fmt.Sprintf("%q", secret))
+		assert.Equal(t, want, fmt.Sprint(secret))
+		assert.Equal(t, "{"+want+"}", fmt.Sprintf("%v", struct{ Password Secret }{secret}))
+	})
+
+	t.Run("should obfuscate non string values", func(t *testing.T) {
+		assert.Equal(t, "*obfuscated, length=4*", NewSensitive(1234).String())
+		assert.Equal(t, "*obfuscated, length=3*", NewSensitive([]byte("abc")).String())
+	})
+
+	t.Run("should obfuscate when marshaled", func(t *testing.T) {
+		data, err := json.Marshal(map[string]interface{}{
+			"password": NewSecret(password),
+			"nested":   struct{ Key Secret }{NewSecret(password)},
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.JSONEq(t,
+			fmt.Sprintf(`{"password":%[1]q,"nested":{"Key":%[1]q}}`, want),
+			string(data),
+		)
+		text, err := NewSecret(password).MarshalText()
+		assert.NoError(t, err)
+		assert.Equal(t, want, string(text))
+	})
+
+	t.Run("should obfuscate in slog handlers", func(t *testing.T) {
+		var output bytes.Buffer
+		slog.New(slog.NewTextHandler(&output, nil)).Info("msg", "password", NewSecret(password))
+		assert.Contains(t, output.String(), fmt.Sprintf("password=%q", want))
+		assert.NotContains(t, output.String(), password)
+	})
+
+	t.Run("should obfuscate in log output", func(t *testing.T) {
+		factories := map[string]LoggerFactory{
+			"zerolog": zerologLoggerFactory{},
+			"slog":    NewSlogLoggerFactory(),
+		}
+		for name, factory := range factories {
+			factory := factory
+			t.Run(name, func(t *testing.T) {
+				var output bytes.Buffer
+				ctx := RootContext(NewRootContextParams().
+					WithLoggerFactory(factory).
+					WithOutput(&output))
+				Log(ctx).Info().
+					WithDataFn(func(data MsgData) {
+						data.Secret("secret", password).
+							Interface("interface", NewSecret(password)).
+							Interface("struct", struct{ Password Secret }{NewSecret(password)}).
+							Stringer("stringer", NewSecret(password))
+					}).
+					Msgf("login with %v", NewSecret(password))
+
+				assert.NotContains(t, output.String(), password)
+				entries := readLogEntries(t, &output)
+				if !assert.Len(t, entries, 1) {
+					return
+				}
+				assert.Equal(t, "login with "+want, entries[0]["msg"])
+				assert.Equal(t, map[string]interface{}{
+					"secret":    want,
+					"interface": want,
+					"struct":    map[string]interface{}{"Password": want},
+					"stringer":  want,
+				}, entries[0]["data"])
+			})
+		}
+	})
+}
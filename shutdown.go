@@ -0,0 +1,94 @@
+package diag
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// ShutdownFunc writes the pending log entries and releases resources of the root context.
+// Returns ctx error if ctx is done before the shutdown completes.
+type ShutdownFunc func(ctx context.Context) error
+
+// LoggerFactoryShutdowner may be implemented by logger factories that start background
+// goroutines or buffer entries. Shutdown is called by the root context shutdown function.
+type LoggerFactoryShutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// shutdownLoggerFactory shuts down the factory if it implements LoggerFactoryShutdowner
+func shutdownLoggerFactory(ctx context.Context, factory LoggerFactory) error {
+	if shutdowner, ok := factory.(LoggerFactoryShutdowner); ok {
+		return shutdowner.Shutdown(ctx)
+	}
+	return nil
+}
+
+// OutputCloser may be implemented by outputs that buffer entries or run background goroutines,
+// such as AsyncWriter and RotatingFile. Close is called by the root context shutdown function.
+type OutputCloser interface {
+	Close(ctx context.Context) error
+}
+
+// closeOutput closes the output if it implements OutputCloser,
+// buffered outputs such as bufio.Writer are flushed
+func closeOutput(ctx context.Context, out io.Writer) error {
+	switch closer := out.(type) {
+	case *AsyncWriter:
+		return errors.Join(closer.Close(ctx), closeOutput(ctx, closer.out))
+	case OutputCloser:
+		return closer.Close(ctx)
+	case interface{ Flush() error }:
+		return closer.Flush()
+	case interface{ Flush() }:
+		closer.Flush()
+	}
+	return nil
+}
+
+type rootShutdown struct {
+	once      sync.Once
+	err       error
+	startedAt time.Time
+	logger    LevelLogger
+	params    *rootContextParams
+}
+
+func (s *rootShutdown) shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.once.Do(func() {
+			s.err = s.run(ctx)
+		})
+	}()
+	select {
+	case <-done:
+		return s.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *rootShutdown) run(ctx context.Context) error {
+	logger := s.logger
+	if sampling, ok := logger.(*samplingLogger); ok {
+		// the shutdown entry should never be dropped by the sampler
+		logger = sampling.target
+	}
+	uptime := time.Since(s.startedAt)
+	logger.WithLevel(changeEntryLevel(s.params.levelControl.Level())).
+		WithDataFn(func(data MsgData) {
+			data.Float64("uptimeSec", uptime.Seconds())
+		}).
+		Msgf("Shutdown after %s uptime", uptime.Round(time.Millisecond))
+
+	errs := []error{shutdownLoggerFactory(ctx, s.params.LoggerFactory)}
+	errs = append(errs, closeOutput(ctx, s.params.Out))
+	for _, sink := range s.params.sinks {
+		errs = append(errs, closeOutput(ctx, sink.out))
+	}
+	return errors.Join(errs...)
+}
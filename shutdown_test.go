@@ -0,0 +1,93 @@
+package diag
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// shutdownRecordingFactory records the shutdown calls and blocks them until unblock is closed
+type shutdownRecordingFactory struct {
+	zerologLoggerFactory
+	calls   *int
+	unblock chan struct{}
+}
+
+func (f shutdownRecordingFactory) Shutdown(ctx context.Context) error {
+	*f.calls++
+	if f.unblock != nil {
+		<-f.unblock
+	}
+	return nil
+}
+
+func TestRootContextWithShutdown(t *testing.T) {
+	t.Run("should log shutdown entry and flush the output", func(t *testing.T) {
+		output := &bytes.Buffer{}
+		buffered := bufio.NewWriter(output)
+		ctx, shutdown := RootContextWithShutdown(NewRootContextParams().
+			WithLogLevel(LogLevelWarnValue).
+			WithOutput(buffered))
+		Log(ctx).Warn().Msg("warn")
+		assert.Empty(t, output.String())
+
+		assert.NoError(t, shutdown(context.Background()))
+		entries := readLogEntries(t, output)
+		if !assert.Len(t, entries, 2) {
+			return
+		}
+		assert.Equal(t, "warn", entries[1]["level"])
+		assert.Contains(t, entries[1]["msg"], "Shutdown after")
+		assert.Contains(t, entries[1]["data"], "uptimeSec")
+
+		assert.NoError(t, shutdown(context.Background()))
+		assert.Len(t, readLogEntries(t, output), 2)
+	})
+
+	t.Run("should close async writer and flush its output", func(t *testing.T) {
+		output := &bytes.Buffer{}
+		buffered := bufio.NewWriter(output)
+		asyncWriter := NewAsyncWriter(buffered)
+		ctx, shutdown := RootContextWithShutdown(NewRootContextParams().
+			WithLogSampler(NewLogSampler(WithSampleRatio(LogLevelInfoValue, 0))).
+			WithAsyncWriter(asyncWriter))
+		Log(ctx).Info().Msg("info")
+		Log(ctx).Warn().Msg("warn")
+
+		assert.NoError(t, shutdown(context.Background()))
+		entries := readLogEntries(t, output)
+		if assert.Len(t, entries, 2) {
+			assert.Equal(t, "warn", entries[0]["msg"])
+			assert.Equal(t, "info", entries[1]["level"])
+		}
+		_, err := asyncWriter.Write([]byte("{}\n"))
+		assert.ErrorIs(t, err, ErrAsyncWriterClosed)
+	})
+
+	t.Run("should shutdown wrapped logger factory", func(t *testing.T) {
+		calls := 0
+		_, shutdown := RootContextWithShutdown(NewRootContextParams().
+			WithLoggerFactory(shutdownRecordingFactory{calls: &calls}).
+			WithPIIScrubber(NewPIIScrubber(DefaultPIIDetectors()...)).
+			WithLogSampler(NewLogSampler()).
+			WithOutput(&bytes.Buffer{}))
+		assert.NoError(t, shutdown(context.Background()))
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("should respect ctx deadline", func(t *testing.T) {
+		calls := 0
+		unblock := make(chan struct{})
+		defer close(unblock)
+		_, shutdown := RootContextWithShutdown(NewRootContextParams().
+			WithLoggerFactory(shutdownRecordingFactory{calls: &calls, unblock: unblock}).
+			WithOutput(&bytes.Buffer{}))
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, shutdown(ctx), context.DeadlineExceeded)
+	})
+}
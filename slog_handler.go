@@ -0,0 +1,165 @@
+package diag
+
+import (
+	"context"
+	"log/slog"
+)
+
+// levelEnabledLogger is implemented by loggers that can tell
+// upfront if entries of a given level will be written
+type levelEnabledLogger interface {
+	enabled(level LogLevel) bool
+}
+
+// loggerEnabled returns true if the logger may write entries of a given level.
+// Loggers that can not tell upfront are assumed enabled.
+func loggerEnabled(logger LevelLogger, level LogLevel) bool {
+	if enabledLogger, ok := logger.(levelEnabledLogger); ok {
+		return enabledLogger.enabled(level)
+	}
+	return true
+}
+
+type slogHandlerFrame struct {
+	group string
+	attrs []slog.Attr
+}
+
+type slogHandler struct {
+	logger LevelLogger
+
+	// frames holds attributes added via WithAttrs, each WithGroup call starts a new frame
+	frames []slogHandlerFrame
+}
+
+// SlogHandler returns slog.Handler that writes entries via the logger of a given diag context.
+// If the context passed to the Handle method is a diag context (e.g. created with DiagifyContext),
+// its logger will be used instead so per request entries will include request diag data.
+// Attributes are written as the entry data, slog groups are written as nested dictionaries.
+// Entry time is set by the underlying logger and the record time is ignored.
+func SlogHandler(ctx context.Context) slog.Handler {
+	return &slogHandler{
+		logger: Log(ctx),
+		frames: []slogHandlerFrame{{}},
+	}
+}
+
+func (h *slogHandler) loggerFor(ctx context.Context) LevelLogger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(contextKeyLogger).(LevelLogger); ok {
+			return logger
+		}
+	}
+	return h.logger
+}
+
+func (h *slogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return loggerEnabled(h.loggerFor(ctx), LogLevelFromSlog(level))
+}
+
+func (h *slogHandler) Handle(ctx context.Context, record slog.Record) error {
+	logger := h.loggerFor(ctx)
+
+	innermost := h.frames[len(h.frames)-1]
+	attrs := make([]slog.Attr, 0, len(innermost.attrs)+record.NumAttrs())
+	attrs = append(attrs, innermost.attrs...)
+	record.Attrs(func(attr slog.Attr) bool {
+		attrs = append(attrs, attr)
+		return true
+	})
+	for i := len(h.frames) - 1; i > 0; i-- {
+		parentAttrs := append([]slog.Attr{}, h.frames[i-1].attrs...)
+		if len(attrs) > 0 {
+			parentAttrs = append(parentAttrs, slog.Attr{Key: h.frames[i].group, Value: slog.GroupValue(attrs...)})
+		}
+		attrs = parentAttrs
+	}
+
+	var err error
+	dataAttrs := attrs[:0:0]
+	for _, attr := range attrs {
+		if attrErr, ok := attr.Value.Resolve().Any().(error); ok && err == nil && (attr.Key == "error" || attr.Key == "err") {
+			err = attrErr
+			continue
+		}
+		dataAttrs = append(dataAttrs, attr)
+	}
+
+	evt := logger.WithLevel(LogLevelFromSlog(record.Level))
+	if err != nil {
+		evt = evt.WithError(err)
+	}
+	if len(dataAttrs) > 0 {
+		evt = evt.WithData(appendSlogAttrs(logger, logger.NewData(), dataAttrs))
+	}
+	evt.Msg(record.Message)
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	frames := append([]slogHandlerFrame{}, h.frames...)
+	last := &frames[len(frames)-1]
+	last.attrs = append(append([]slog.Attr{}, last.attrs...), attrs...)
+	return &slogHandler{logger: h.logger, frames: frames}
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	frames := append([]slogHandlerFrame{}, h.frames...)
+	frames = append(frames, slogHandlerFrame{group: name})
+	return &slogHandler{logger: h.logger, frames: frames}
+}
+
+var _ slog.Handler = &slogHandler{}
+
+func appendSlogAttrs(logger LevelLogger, data MsgData, attrs []slog.Attr) MsgData {
+	for _, attr := range attrs {
+		data = appendSlogAttr(logger, data, attr)
+	}
+	return data
+}
+
+func appendSlogAttr(logger LevelLogger, data MsgData, attr slog.Attr) MsgData {
+	value := attr.Value.Resolve()
+	key := attr.Key
+	switch value.Kind() {
+	case slog.KindString:
+		return data.Str(key, value.String())
+	case slog.KindInt64:
+		return data.Int64(key, value.Int64())
+	case slog.KindUint64:
+		return data.Uint64(key, value.Uint64())
+	case slog.KindFloat64:
+		return data.Float64(key, value.Float64())
+	case slog.KindBool:
+		return data.Bool(key, value.Bool())
+	case slog.KindDuration:
+		return data.Str(key, value.Duration().String())
+	case slog.KindTime:
+		return data.Time(key, value.Time())
+	case slog.KindGroup:
+		groupAttrs := value.Group()
+		if len(groupAttrs) == 0 {
+			return data
+		}
+
+		// Groups with empty key are inlined as per slog.Handler contract
+		if key == "" {
+			return appendSlogAttrs(logger, data, groupAttrs)
+		}
+		return data.Dict(key, appendSlogAttrs(logger, logger.NewData(), groupAttrs))
+	default:
+		if key == "" && value.Any() == nil {
+			return data
+		}
+		if err, ok := value.Any().(error); ok {
+			return data.Str(key, err.Error())
+		}
+		return data.Interface(key, value.Any())
+	}
+}
@@ -0,0 +1,168 @@
+package diag
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlogHandler(t *testing.T) {
+	newTestLogger := func(level LogLevel) (context.Context, func() map[string]interface{}) {
+		var output bytes.Buffer
+		outputWriter := bufio.NewWriter(&output)
+		ctx := RootContext(
+			NewRootContextParams().
+				WithLogLevel(level).
+				WithOutput(outputWriter),
+		)
+		readLine := func() map[string]interface{} {
+			outputWriter.Flush()
+			defer output.Reset()
+			if output.Len() == 0 {
+				return nil
+			}
+			var logMessage map[string]interface{}
+			assert.NoError(t, json.Unmarshal(output.Bytes(), &logMessage))
+			return logMessage
+		}
+		return ctx, readLine
+	}
+
+	t.Run("writes entries via diag logger", func(t *testing.T) {
+		ctx, readLine := newTestLogger(LogLevelTraceValue)
+		logger := slog.New(SlogHandler(ctx))
+
+		tests := []struct {
+			level     slog.Level
+			wantLevel string
+		}{
+			{level: SlogLevelTrace, wantLevel: "trace"},
+			{level: slog.LevelDebug, wantLevel: "debug"},
+			{level: slog.LevelInfo, wantLevel: "info"},
+			{level: slog.LevelWarn, wantLevel: "warn"},
+			{level: slog.LevelError, wantLevel: "error"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.wantLevel, func(t *testing.T) {
+				msg := fake.Lorem().Sentence(3)
+				logger.Log(context.Background(), tt.level, msg)
+				logMessage := readLine()
+				assert.Equal(t, tt.wantLevel, logMessage["level"])
+				assert.Equal(t, msg, logMessage["msg"])
+				assert.Equal(t, DiagData(ctx).CorrelationID, logMessage["context"].(map[string]interface{})["correlationId"])
+				assert.NotContains(t, logMessage, "data")
+			})
+		}
+	})
+
+	t.Run("respects logger level", func(t *testing.T) {
+		ctx, readLine := newTestLogger(LogLevelWarnValue)
+		handler := SlogHandler(ctx)
+		assert.False(t, handler.Enabled(context.Background(), slog.LevelInfo))
+		assert.True(t, handler.Enabled(context.Background(), slog.LevelWarn))
+
+		slog.New(handler).Info(fake.Lorem().Sentence(3))
+		assert.Nil(t, readLine())
+	})
+
+	t.Run("respects logger level of wrapped loggers", func(t *testing.T) {
+		params := map[string]*rootContextParams{
+			"pii scrubbing": NewRootContextParams().WithPIIScrubber(NewPIIScrubber()),
+			"sampling":      NewRootContextParams().WithLogSampler(NewLogSampler()),
+			"multi-sink":    NewRootContextParams().WithSink(io.Discard, WithSinkLevel(LogLevelErrorValue)),
+		}
+		for name, p := range params {
+			t.Run(name, func(t *testing.T) {
+				handler := SlogHandler(RootContext(p.WithLogLevel(LogLevelWarnValue).WithOutput(io.Discard)))
+				assert.False(t, handler.Enabled(context.Background(), slog.LevelInfo))
+				assert.True(t, handler.Enabled(context.Background(), slog.LevelWarn))
+			})
+		}
+	})
+
+	t.Run("uses logger of a diag context passed to Handle", func(t *testing.T) {
+		rootCtx, readLine := newTestLogger(LogLevelInfoValue)
+		logger := slog.New(SlogHandler(rootCtx))
+
+		wantCorrelationID := fake.UUID().V4()
+		reqCtx := DiagifyContext(context.Background(), rootCtx,
+			WithCorrelationID(wantCorrelationID),
+			WithLogLevel(LogLevelDebugValue),
+		)
+		assert.True(t, logger.Enabled(reqCtx, slog.LevelDebug))
+		assert.False(t, logger.Enabled(context.Background(), slog.LevelDebug))
+
+		logger.DebugContext(reqCtx, fake.Lorem().Sentence(3))
+		logMessage := readLine()
+		assert.Equal(t, wantCorrelationID, logMessage["context"].(map[string]interface{})["correlationId"])
+	})
+
+	t.Run("writes attributes and groups as data", func(t *testing.T) {
+		ctx, readLine := newTestLogger(LogLevelInfoValue)
+		now := time.Now()
+		wantErr := errors.New(fake.Lorem().Sentence(3))
+		logger := slog.New(SlogHandler(ctx)).
+			With("str", "value", "emptyGroup", slog.GroupValue()).
+			WithGroup("").
+			WithGroup("g1").
+			With(slog.Int("int", 10)).
+			WithGroup("g2")
+
+		logger.Info(fake.Lorem().Sentence(3),
+			slog.Uint64("uint", 20),
+			slog.Float64("float", 1.5),
+			slog.Bool("bool", true),
+			slog.Duration("duration", time.Second),
+			slog.Time("time", now),
+			slog.Group("nested", slog.String("key", "value")),
+			slog.Group("", slog.String("inlined", "value")),
+			slog.Any("anyErr", wantErr),
+			slog.Any("any", []int{1, 2}),
+			slog.Attr{},
+		)
+		logMessage := readLine()
+		assert.Equal(t, map[string]interface{}{
+			"str": "value",
+			"g1": map[string]interface{}{
+				"int": float64(10),
+				"g2": map[string]interface{}{
+					"uint":     float64(20),
+					"float":    1.5,
+					"bool":     true,
+					"duration": "1s",
+					"time":     now.Format(time.RFC3339Nano),
+					"nested": map[string]interface{}{
+						"key": "value",
+					},
+					"inlined": "value",
+					"anyErr":  wantErr.Error(),
+					"any":     []interface{}{float64(1), float64(2)},
+				},
+			},
+		}, logMessage["data"])
+	})
+
+	t.Run("omits empty groups", func(t *testing.T) {
+		ctx, readLine := newTestLogger(LogLevelInfoValue)
+		logger := slog.New(SlogHandler(ctx)).With().WithGroup("g1")
+		logger.Info(fake.Lorem().Sentence(3))
+		assert.NotContains(t, readLine(), "data")
+	})
+
+	t.Run("writes error attribute as entry error", func(t *testing.T) {
+		ctx, readLine := newTestLogger(LogLevelInfoValue)
+		wantErr := errors.New(fake.Lorem().Sentence(3))
+		slog.New(SlogHandler(ctx)).Error(fake.Lorem().Sentence(3), "err", wantErr)
+		logMessage := readLine()
+		assert.Equal(t, wantErr.Error(), logMessage["error"])
+		assert.NotContains(t, logMessage, "data")
+	})
+}
@@ -0,0 +1,129 @@
+package diag
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+type tailBufferState int
+
+const (
+	tailBufferBuffering tailBufferState = iota
+	tailBufferFlushed
+	tailBufferDiscarded
+)
+
+// LogTailBuffer holds log entries below the logger level in memory, so they can be
+// written if something goes wrong or discarded otherwise. Error entries flush the buffer.
+// Use with WithTailBuffer diag context option, usually one buffer per request.
+type LogTailBuffer struct {
+	level      LogLevel
+	maxEntries int
+
+	mu      sync.Mutex
+	state   tailBufferState
+	entries []func()
+	dropped int
+
+	// logger is used to report dropped entries, set by DiagifyContext
+	logger LevelLogger
+}
+
+// NewLogTailBuffer creates a buffer for the entries of a given or higher level
+// that are below the logger level. Up to maxEntries most recent entries are kept.
+func NewLogTailBuffer(level LogLevel, maxEntries int) *LogTailBuffer {
+	if _, ok := ParseLogLevel(level.String()); !ok {
+		panic(fmt.Errorf("invalid log level %s", level))
+	}
+	if maxEntries <= 0 {
+		panic(fmt.Errorf("tail buffer max entries must be positive, got %d", maxEntries))
+	}
+	return &LogTailBuffer{level: level, maxEntries: maxEntries}
+}
+
+// captures returns true if entries of the level disabled by the logger should be passed to the buffer
+func (b *LogTailBuffer) captures(level LogLevel) bool {
+	if b == nil || level.severity() < b.level.severity() {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state != tailBufferDiscarded
+}
+
+// write buffers the entry write function, the entry is written immediately
+// if the buffer was flushed and ignored if the buffer was discarded
+func (b *LogTailBuffer) write(entry func()) {
+	b.mu.Lock()
+	switch b.state {
+	case tailBufferBuffering:
+		if len(b.entries) == b.maxEntries {
+			b.entries = b.entries[1:]
+			b.dropped++
+		}
+		b.entries = append(b.entries, entry)
+		b.mu.Unlock()
+	case tailBufferFlushed:
+		b.mu.Unlock()
+		entry()
+	default:
+		b.mu.Unlock()
+	}
+}
+
+// Flush writes the buffered entries, entries logged after the flush are written immediately.
+// Does nothing if the buffer was already flushed or discarded.
+func (b *LogTailBuffer) Flush() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	if b.state != tailBufferBuffering {
+		b.mu.Unlock()
+		return
+	}
+	b.state = tailBufferFlushed
+	entries, dropped := b.entries, b.dropped
+	b.entries = nil
+	b.mu.Unlock()
+
+	if dropped > 0 && b.logger != nil {
+		b.logger.Warn().Msgf("Log tail buffer was full, %d oldest entries were dropped", dropped)
+	}
+	for _, entry := range entries {
+		entry()
+	}
+}
+
+// Discard drops the buffered entries, entries logged after the discard are ignored.
+// Does nothing if the buffer was already flushed or discarded.
+func (b *LogTailBuffer) Discard() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == tailBufferBuffering {
+		b.state = tailBufferDiscarded
+		b.entries = nil
+	}
+}
+
+// tailBufferWriter buffers entries serialized by the zerolog logger
+type tailBufferWriter struct {
+	buffer *LogTailBuffer
+	out    io.Writer
+}
+
+func (w tailBufferWriter) Write(p []byte) (int, error) {
+	entry := make([]byte, len(p))
+	copy(entry, p)
+	w.buffer.write(func() {
+		if _, err := w.out.Write(entry); err != nil {
+			fmt.Fprintf(os.Stderr, "diag: could not write buffered log entry: %v\n", err)
+		}
+	})
+	return len(p), nil
+}
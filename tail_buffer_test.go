@@ -0,0 +1,122 @@
+package diag
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogTailBuffer(t *testing.T) {
+	factories := map[string]LoggerFactory{
+		"zerolog": zerologLoggerFactory{},
+		"slog":    NewSlogLoggerFactory(),
+	}
+	messages := func(entries []map[string]interface{}) []interface{} {
+		result := make([]interface{}, len(entries))
+		for i, entry := range entries {
+			result[i] = entry["msg"]
+		}
+		return result
+	}
+
+	for name, factory := range factories {
+		factory := factory
+		newRequestContext := func(buffer *LogTailBuffer) (context.Context, *bytes.Buffer) {
+			output := &bytes.Buffer{}
+			rootCtx := RootContext(NewRootContextParams().
+				WithLoggerFactory(factory).
+				WithLogLevel(LogLevelInfoValue).
+				WithOutput(output))
+			return DiagifyContext(context.Background(), rootCtx, WithTailBuffer(buffer)), output
+		}
+
+		t.Run(name, func(t *testing.T) {
+			t.Run("should write buffered entries on flush", func(t *testing.T) {
+				buffer := NewLogTailBuffer(LogLevelDebugValue, 10)
+				ctx, output := newRequestContext(buffer)
+				log := Log(ctx)
+				log.Debug().WithDataFn(func(data MsgData) { data.Int("n", 1) }).Msg("debug 1")
+				log.Info().Msg("info")
+				log.Trace().Msg("trace")
+				log.Debug().Msgf("debug %d", 2)
+				assert.Equal(t, []interface{}{"info"}, messages(readLogEntries(t, output)))
+
+				buffer.Flush()
+				entries := readLogEntries(t, output)
+				assert.Equal(t, []interface{}{"info", "debug 1", "debug 2"}, messages(entries))
+				if assert.Len(t, entries, 3) {
+					assert.Equal(t, "debug", entries[1]["level"])
+					assert.Equal(t, map[string]interface{}{"n": float64(1)}, entries[1]["data"])
+				}
+
+				log.Debug().Msg("debug 3")
+				assert.Equal(t, []interface{}{"info", "debug 1", "debug 2", "debug 3"}, messages(readLogEntries(t, output)))
+			})
+
+			t.Run("should flush on error entries", func(t *testing.T) {
+				ctx, output := newRequestContext(NewLogTailBuffer(LogLevelDebugValue, 10))
+				log := Log(ctx)
+				log.Debug().Msg("debug")
+				log.Error().WithError(errors.New("failed")).Msg("error")
+				assert.Equal(t, []interface{}{"debug", "error"}, messages(readLogEntries(t, output)))
+			})
+
+			t.Run("should drop entries on discard", func(t *testing.T) {
+				buffer := NewLogTailBuffer(LogLevelDebugValue, 10)
+				ctx, output := newRequestContext(buffer)
+				log := Log(ctx)
+				log.Debug().Msg("debug 1")
+				buffer.Discard()
+				log.Debug().Msg("debug 2")
+				log.Info().Msg("info")
+				buffer.Flush()
+				log.Error().Msg("error")
+				assert.Equal(t, []interface{}{"info", "error"}, messages(readLogEntries(t, output)))
+			})
+
+			t.Run("should keep most recent entries", func(t *testing.T) {
+				buffer := NewLogTailBuffer(LogLevelDebugValue, 2)
+				ctx, output := newRequestContext(buffer)
+				log := Log(ctx)
+				for _, msg := range []string{"debug 1", "debug 2", "debug 3"} {
+					log.Debug().Msg(msg)
+				}
+				buffer.Flush()
+				assert.Equal(t,
+					[]interface{}{"Log tail buffer was full, 1 oldest entries were dropped", "debug 2", "debug 3"},
+					messages(readLogEntries(t, output)),
+				)
+			})
+
+			t.Run("should be shared with derived contexts", func(t *testing.T) {
+				buffer := NewLogTailBuffer(LogLevelTraceValue, 10)
+				ctx, output := newRequestContext(buffer)
+				childCtx := DiagifyContext(context.Background(), ctx, WithCorrelationID("child"))
+				gotBuffer, ok := TailBuffer(childCtx)
+				assert.True(t, ok)
+				assert.Same(t, buffer, gotBuffer)
+
+				Log(childCtx).Trace().Msg("trace")
+				Log(childCtx).Error().Msg("error")
+				entries := readLogEntries(t, output)
+				assert.Equal(t, []interface{}{"trace", "error"}, messages(entries))
+				if assert.Len(t, entries, 2) {
+					assert.Equal(t, "child", entries[0]["context"].(map[string]interface{})["correlationId"])
+				}
+			})
+		})
+	}
+
+	t.Run("TailBuffer should return false if not set", func(t *testing.T) {
+		_, ok := TailBuffer(RootContext(NewRootContextParams()))
+		assert.False(t, ok)
+	})
+
+	t.Run("should panic on invalid params", func(t *testing.T) {
+		assert.Panics(t, func() { NewLogTailBuffer("bad-"+LogLevel(fake.Lorem().Word()), 1) })
+		assert.Panics(t, func() { NewLogTailBuffer(LogLevelDebugValue, 0) })
+	})
+}
@@ -0,0 +1,232 @@
+package diag
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	traceParentVersion = "00"
+
+	traceIDLength      = 32
+	spanIDLength       = 16
+	traceParentLength  = 55
+	traceStateMaxItems = 32
+	traceStateMaxLen   = 512
+
+	traceFlagSampled = 0x01
+
+	hexDigits = "0123456789abcdef"
+)
+
+// TraceContext holds W3C Trace Context data of the current request
+// See https://www.w3.org/TR/trace-context/ for details
+type TraceContext struct {
+	// TraceID is a 32 hex characters id of the whole trace
+	TraceID string
+
+	// SpanID is a 16 hex characters id of the current span
+	SpanID string
+
+	// ParentSpanID is a 16 hex characters id of the caller span, empty if trace was started by us
+	ParentSpanID string
+
+	// Sampled reflects the sampled flag of the trace
+	Sampled bool
+
+	// TraceState is a vendor specific trace data that should be propagated as is
+	TraceState string
+}
+
+// IsValid returns true if the trace context holds a trace
+func (tc TraceContext) IsValid() bool {
+	return tc.TraceID != "" && tc.SpanID != ""
+}
+
+// TraceParent formats the trace context as a traceparent header value
+func (tc TraceContext) TraceParent() string {
+	flags := 0
+	if tc.Sampled {
+		flags |= traceFlagSampled
+	}
+	return fmt.Sprintf("%s-%s-%s-%02x", traceParentVersion, tc.TraceID, tc.SpanID, flags)
+}
+
+// ChildSpan returns a trace context of a new span that is a child of the current span
+func (tc TraceContext) ChildSpan() TraceContext {
+	return TraceContext{
+		TraceID:      tc.TraceID,
+		SpanID:       NewSpanID(),
+		ParentSpanID: tc.SpanID,
+		Sampled:      tc.Sampled,
+		TraceState:   tc.TraceState,
+	}
+}
+
+// NewTraceContext starts a new trace
+func NewTraceContext() TraceContext {
+	return TraceContext{
+		TraceID: NewTraceID(),
+		SpanID:  NewSpanID(),
+	}
+}
+
+// NewTraceID generates a new random trace id
+func NewTraceID() string {
+	return randomHex(traceIDLength / 2)
+}
+
+// NewSpanID generates a new random span id
+func NewSpanID() string {
+	return randomHex(spanIDLength / 2)
+}
+
+func randomHex(size int) string {
+	for {
+		b := make([]byte, size)
+		if _, err := rand.Read(b); err != nil {
+			panic(fmt.Errorf("failed to generate random id: %w", err))
+		}
+
+		// All zeros id is invalid
+		for _, v := range b {
+			if v != 0 {
+				return hex.EncodeToString(b)
+			}
+		}
+	}
+}
+
+func isLowerHex(value string) bool {
+	for _, c := range value {
+		if !strings.ContainsRune(hexDigits, c) {
+			return false
+		}
+	}
+	return true
+}
+
+func isAllZeros(value string) bool {
+	return strings.Trim(value, "0") == ""
+}
+
+// ParseTraceParent parses a traceparent header value.
+// Returned trace context will have parsed parent id as a SpanID,
+// use ChildSpan to start a span of the current request.
+func ParseTraceParent(value string) (TraceContext, error) {
+	parts := strings.Split(value, "-")
+	if len(parts) < 4 {
+		return TraceContext{}, fmt.Errorf("invalid traceparent %q: unexpected format", value)
+	}
+	version, traceID, parentID, flags := parts[0], parts[1], parts[2], parts[3]
+
+	if len(version) != 2 || !isLowerHex(version) || version == "ff" {
+		return TraceContext{}, fmt.Errorf("invalid traceparent %q: bad version", value)
+	}
+
+	// Future versions may append more fields, the version 00 must have exactly 4
+	if version == traceParentVersion && (len(parts) != 4 || len(value) != traceParentLength) {
+		return TraceContext{}, fmt.Errorf("invalid traceparent %q: unexpected format", value)
+	}
+
+	if len(traceID) != traceIDLength || !isLowerHex(traceID) || isAllZeros(traceID) {
+		return TraceContext{}, fmt.Errorf("invalid traceparent %q: bad trace id", value)
+	}
+	if len(parentID) != spanIDLength || !isLowerHex(parentID) || isAllZeros(parentID) {
+		return TraceContext{}, fmt.Errorf("invalid traceparent %q: bad parent id", value)
+	}
+	if len(flags) != 2 || !isLowerHex(flags) {
+		return TraceContext{}, fmt.Errorf("invalid traceparent %q: bad flags", value)
+	}
+	// sampled is the lowest bit so only the last hex digit matters
+	flagsValue := strings.IndexByte(hexDigits, flags[1])
+
+	return TraceContext{
+		TraceID: traceID,
+		SpanID:  parentID,
+		Sampled: flagsValue&traceFlagSampled == traceFlagSampled,
+	}, nil
+}
+
+// ParseCloudTraceContext parses a GCP X-Cloud-Trace-Context header value
+// of TRACE_ID/SPAN_ID;o=OPTIONS format, the span id is decimal.
+// Returned trace context will have parsed span id as a SpanID,
+// use ChildSpan to start a span of the current request.
+func ParseCloudTraceContext(value string) (TraceContext, error) {
+	traceID, rest, found := strings.Cut(value, "/")
+	if !found {
+		return TraceContext{}, fmt.Errorf("invalid cloud trace context %q: unexpected format", value)
+	}
+	traceID = strings.ToLower(traceID)
+	if len(traceID) != traceIDLength || !isLowerHex(traceID) || isAllZeros(traceID) {
+		return TraceContext{}, fmt.Errorf("invalid cloud trace context %q: bad trace id", value)
+	}
+	spanValue, options, _ := strings.Cut(rest, ";")
+	spanID, err := strconv.ParseUint(spanValue, 10, 64)
+	if err != nil || spanID == 0 {
+		return TraceContext{}, fmt.Errorf("invalid cloud trace context %q: bad span id", value)
+	}
+	return TraceContext{
+		TraceID: traceID,
+		SpanID:  fmt.Sprintf("%016x", spanID),
+		Sampled: options == "o=1",
+	}, nil
+}
+
+func isValidTraceStateKey(key string) bool {
+	if key == "" || len(key) > 256 {
+		return false
+	}
+	for _, c := range key {
+		isValid := (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '_' || c == '-' || c == '*' || c == '/' || c == '@'
+		if !isValid {
+			return false
+		}
+	}
+	return true
+}
+
+func isValidTraceStateValue(value string) bool {
+	if value == "" || len(value) > 256 || strings.HasSuffix(value, " ") {
+		return false
+	}
+	for _, c := range value {
+		if c < 0x20 || c > 0x7e || c == ',' || c == '=' {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseTraceState validates a tracestate header value and returns it normalized
+// (empty list members are removed). Returns an error if the value is malformed.
+func ParseTraceState(value string) (string, error) {
+	if len(value) > traceStateMaxLen {
+		return "", fmt.Errorf("invalid tracestate: longer than %d characters", traceStateMaxLen)
+	}
+	members := make([]string, 0, traceStateMaxItems)
+	seenKeys := make(map[string]struct{}, traceStateMaxItems)
+	for _, member := range strings.Split(value, ",") {
+		member = strings.Trim(member, " \t")
+		if member == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(member, "=")
+		if !ok || !isValidTraceStateKey(key) || !isValidTraceStateValue(val) {
+			return "", fmt.Errorf("invalid tracestate member %q", member)
+		}
+		if _, ok := seenKeys[key]; ok {
+			return "", fmt.Errorf("invalid tracestate: duplicate key %q", key)
+		}
+		seenKeys[key] = struct{}{}
+		members = append(members, member)
+	}
+	if len(members) > traceStateMaxItems {
+		return "", fmt.Errorf("invalid tracestate: more than %d members", traceStateMaxItems)
+	}
+	return strings.Join(members, ","), nil
+}
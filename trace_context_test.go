@@ -0,0 +1,231 @@
+package diag
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTraceContext(t *testing.T) {
+	t.Run("ParseTraceParent", func(t *testing.T) {
+		t.Run("valid", func(t *testing.T) {
+			tests := []struct {
+				name      string
+				value     string
+				wantTrace TraceContext
+			}{
+				{
+					name:  "sampled",
+					value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+					wantTrace: TraceContext{
+						TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+						SpanID:  "00f067aa0ba902b7",
+						Sampled: true,
+					},
+				},
+				{
+					name:  "not sampled",
+					value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+					wantTrace: TraceContext{
+						TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+						SpanID:  "00f067aa0ba902b7",
+					},
+				},
+				{
+					name:  "future version with extra fields",
+					value: "cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-03-what-the-future",
+					wantTrace: TraceContext{
+						TraceID: "4bf92f3577b34da6a3ce929d0e0e4736",
+						SpanID:  "00f067aa0ba902b7",
+						Sampled: true,
+					},
+				},
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					got, err := ParseTraceParent(tt.value)
+					if !assert.NoError(t, err) {
+						return
+					}
+					assert.Equal(t, tt.wantTrace, got)
+				})
+			}
+		})
+		t.Run("invalid", func(t *testing.T) {
+			tests := map[string]string{
+				"empty":              "",
+				"too few parts":      "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+				"bad version":        "0x-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+				"forbidden version":  "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+				"v00 extra fields":   "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+				"uppercase trace id": "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+				"zero trace id":      "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+				"short trace id":     "cc-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01",
+				"zero parent id":     "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+				"bad parent id":      "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902bz-01",
+				"bad flags":          "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-0x",
+			}
+			for name, value := range tests {
+				t.Run(name, func(t *testing.T) {
+					_, err := ParseTraceParent(value)
+					assert.Error(t, err)
+				})
+			}
+		})
+	})
+
+	t.Run("ParseTraceState", func(t *testing.T) {
+		t.Run("valid", func(t *testing.T) {
+			got, err := ParseTraceState("rojo=00f067aa0ba902b7, ,congo=t61rcWkgMzE,a@vendor/x*y=1 2")
+			assert.NoError(t, err)
+			assert.Equal(t, "rojo=00f067aa0ba902b7,congo=t61rcWkgMzE,a@vendor/x*y=1 2", got)
+		})
+		t.Run("invalid", func(t *testing.T) {
+			tooManyMembers := make([]string, 33)
+			for i := range tooManyMembers {
+				tooManyMembers[i] = "k" + strings.Repeat("a", i) + "=v"
+			}
+			tests := map[string]string{
+				"no value":          "rojo",
+				"empty key":         "=value",
+				"uppercase key":     "Rojo=value",
+				"long key":          strings.Repeat("a", 257) + "=1",
+				"bad value":         "rojo=a=b",
+				"control character": "rojo=value \x01",
+				"duplicate key":     "rojo=1,rojo=2",
+				"too long":          "rojo=" + strings.Repeat("a", 512),
+				"too many members":  strings.Join(tooManyMembers, ","),
+			}
+			for name, value := range tests {
+				t.Run(name, func(t *testing.T) {
+					_, err := ParseTraceState(value)
+					assert.Error(t, err)
+				})
+			}
+		})
+	})
+
+	t.Run("ParseCloudTraceContext", func(t *testing.T) {
+		t.Run("valid", func(t *testing.T) {
+			tests := map[string]TraceContext{
+				"105445AA7843BC8BF206B12000100000/1;o=1": {
+					TraceID: "105445aa7843bc8bf206b12000100000",
+					SpanID:  "0000000000000001",
+					Sampled: true,
+				},
+				"105445aa7843bc8bf206b12000100000/18446744073709551615;o=0": {
+					TraceID: "105445aa7843bc8bf206b12000100000",
+					SpanID:  "ffffffffffffffff",
+				},
+				"105445aa7843bc8bf206b12000100000/255": {
+					TraceID: "105445aa7843bc8bf206b12000100000",
+					SpanID:  "00000000000000ff",
+				},
+			}
+			for value, want := range tests {
+				t.Run(value, func(t *testing.T) {
+					got, err := ParseCloudTraceContext(value)
+					if assert.NoError(t, err) {
+						assert.Equal(t, want, got)
+					}
+				})
+			}
+		})
+		t.Run("invalid", func(t *testing.T) {
+			tests := map[string]string{
+				"empty":            "",
+				"no span id":       "105445aa7843bc8bf206b12000100000",
+				"short trace id":   "105445aa/1;o=1",
+				"zero trace id":    strings.Repeat("0", 32) + "/1;o=1",
+				"zero span id":     "105445aa7843bc8bf206b12000100000/0;o=1",
+				"hex span id":      "105445aa7843bc8bf206b12000100000/ff;o=1",
+				"span id overflow": "105445aa7843bc8bf206b12000100000/18446744073709551616",
+			}
+			for name, value := range tests {
+				t.Run(name, func(t *testing.T) {
+					_, err := ParseCloudTraceContext(value)
+					assert.Error(t, err)
+				})
+			}
+		})
+	})
+
+	t.Run("TraceParent", func(t *testing.T) {
+		trace := TraceContext{
+			TraceID: NewTraceID(),
+			SpanID:  NewSpanID(),
+		}
+		assert.Equal(t, "00-"+trace.TraceID+"-"+trace.SpanID+"-00", trace.TraceParent())
+		trace.Sampled = true
+		parsed, err := ParseTraceParent(trace.TraceParent())
+		assert.NoError(t, err)
+		assert.Equal(t, trace, parsed)
+	})
+
+	t.Run("ChildSpan", func(t *testing.T) {
+		parent := NewTraceContext()
+		parent.Sampled = true
+		parent.TraceState = "rojo=1"
+		child := parent.ChildSpan()
+		assert.True(t, child.IsValid())
+		assert.Equal(t, parent.TraceID, child.TraceID)
+		assert.Equal(t, parent.SpanID, child.ParentSpanID)
+		assert.NotEqual(t, parent.SpanID, child.SpanID)
+		assert.Equal(t, parent.Sampled, child.Sampled)
+		assert.Equal(t, parent.TraceState, child.TraceState)
+	})
+
+	t.Run("NewTraceContext", func(t *testing.T) {
+		trace := NewTraceContext()
+		assert.True(t, trace.IsValid())
+		assert.Len(t, trace.TraceID, 32)
+		assert.Len(t, trace.SpanID, 16)
+		assert.Empty(t, trace.ParentSpanID)
+		assert.False(t, TraceContext{}.IsValid())
+	})
+}
+
+func TestTraceContext_LogFields(t *testing.T) {
+	factories := map[string]LoggerFactory{
+		"zerolog": zerologLoggerFactory{},
+		"slog":    NewSlogLoggerFactory(),
+	}
+	for name, factory := range factories {
+		t.Run(name, func(t *testing.T) {
+			var output bytes.Buffer
+			outputWriter := bufio.NewWriter(&output)
+			rootCtx := RootContext(NewRootContextParams().
+				WithLoggerFactory(factory).
+				WithOutput(outputWriter))
+
+			trace := NewTraceContext().ChildSpan()
+			trace.Sampled = true
+			ctx := ForkContext(rootCtx, WithTraceContext(trace))
+			assert.Equal(t, trace, DiagData(ctx).Trace)
+
+			Log(rootCtx).Info().Msg(fake.Lorem().Sentence(3))
+			Log(ctx).Info().Msg(fake.Lorem().Sentence(3))
+			outputWriter.Flush()
+
+			lines := strings.Split(strings.Trim(output.String(), "\n"), "\n")
+			if !assert.Len(t, lines, 2) {
+				return
+			}
+
+			var rootMessage TestLogMessage[map[string]interface{}]
+			assert.NoError(t, json.Unmarshal([]byte(lines[0]), &rootMessage))
+			assert.NotContains(t, rootMessage.Context, "traceId")
+
+			var logMessage TestLogMessage[map[string]interface{}]
+			assert.NoError(t, json.Unmarshal([]byte(lines[1]), &logMessage))
+			assert.Equal(t, trace.TraceID, logMessage.Context["traceId"])
+			assert.Equal(t, trace.SpanID, logMessage.Context["spanId"])
+			assert.Equal(t, trace.ParentSpanID, logMessage.Context["parentSpanId"])
+			assert.Equal(t, true, logMessage.Context["traceSampled"])
+		})
+	}
+}